@@ -1,32 +1,127 @@
 // @title Subs-API
 // @version 1.0
 // @description API-service for managing users' subscriptions
+// @description Dates are returned as MM-YYYY, date_format=full query param opts in to YYYY-MM-DD.
+// @description Amounts are returned as JSON numbers, /v2 returns them as decimal strings and full dates
 // @schemes http
+// @BasePath /v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description API key or JWT in "Bearer <token>" format
 package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"testcase/internal/api"
+	"testcase/internal/auth"
+	"testcase/internal/changes"
+	"testcase/internal/notify"
+	"testcase/internal/ratelimit"
+	"testcase/internal/rates"
 	"testcase/internal/settings"
 	"testcase/internal/subs"
+	"testcase/internal/webhooks"
+	"testcase/models"
 	"time"
+
+	// Users' time zones are validated in images without tzdata
+	_ "time/tzdata"
 )
 
 func main() {
 	cfg := settings.GetConfig()
+	var logLevel slog.LevelVar
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: &logLevel,
+	})))
+	cfg.Subscribe(func(rt *settings.Runtime) {
+		logLevel.Set(rt.LogLevel)
+	})
 	sr := subs.New(&subs.DBConfig{
 		Address:  cfg.GetString("db_addr"),
 		User:     cfg.GetString("db_user"),
 		Password: cfg.GetString("db_pass"),
 		DBName:   cfg.GetString("db_name"),
 	})
+	if len(os.Args) > 1 && os.Args[1] == "create-admin-key" {
+		createAdminKey(sr, os.Args[2:])
+		return
+	}
+
+	if file := cfg.GetString("rates_file"); file != "" {
+		list, err := rates.ReadFile(file)
+		if err != nil {
+			log.Fatal("loading exchange rates error: " + err.Error())
+		}
+		if err = sr.SetRates(context.Background(), list); err != nil {
+			log.Fatal("saving exchange rates error: " + err.Error())
+		}
+		slog.Info("exchange rates loaded", slog.Int("rates", len(list)), slog.String("file", file))
+	}
+
+	au, err := auth.New(sr, &auth.JWTConfig{
+		HMACSecret: cfg.GetString("auth.jwt.hmac_secret"),
+		JWKSFile:   cfg.GetString("auth.jwt.jwks_file"),
+		Issuer:     cfg.GetString("auth.jwt.issuer"),
+		Audience:   cfg.GetString("auth.jwt.audience"),
+	})
+	if err != nil {
+		log.Fatal("auth setup error: " + err.Error())
+	}
+
+	var limiter ratelimit.Store
+	switch store := cfg.GetString("rate_limit_store"); store {
+	case "", "memory":
+		limiter = ratelimit.NewMemoryStore()
+	case "postgres":
+		limiter = ratelimit.StoreFunc(sr.TakeRateLimitToken)
+	default:
+		log.Fatal("unknown rate_limit_store " + store)
+	}
+
+	channels := make(map[string]notify.Notifier)
+	for _, channel := range cfg.GetStringSlice("reminder_channels") {
+		switch channel {
+		case "log":
+			channels[channel] = notify.LogNotifier{}
+		case "webhook":
+			channels[channel] = notify.NewWebhook(cfg.GetString("reminder_webhook.url"), cfg.GetDuration("reminder_webhook.timeout"))
+		case "smtp":
+			channels[channel] = notify.NewSMTP(&notify.SMTPConfig{
+				Addr:     cfg.GetString("reminder_smtp.addr"),
+				From:     cfg.GetString("reminder_smtp.from"),
+				User:     cfg.GetString("reminder_smtp.user"),
+				Password: cfg.GetString("reminder_smtp.pass"),
+			})
+		default:
+			log.Fatal("unknown reminder channel " + channel)
+		}
+	}
+	reminders := notify.NewScheduler(sr, channels)
+	dispatcher := webhooks.NewDispatcher(sr)
+
+	hub := changes.NewHub(sr)
+	serv := api.New(sr, au, limiter, hub)
+	cfg.Subscribe(sr.Reconfigure)
+	cfg.Subscribe(serv.Reconfigure)
+	cfg.Subscribe(reminders.Reconfigure)
+	cfg.Subscribe(dispatcher.Reconfigure)
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	cfg.Watch(bgCtx)
+	sr.RunPurge(bgCtx)
+	reminders.Run(bgCtx)
+	dispatcher.Run(bgCtx)
+	hub.Run(bgCtx)
 
-	serv := api.New(sr)
 	servError := make(chan error, 1)
 	go func() {
 		if err := serv.Run(cfg.GetString("api_address")); err != nil && err != http.ErrServerClosed {
@@ -51,3 +146,24 @@ func main() {
 		log.Println("Server stopped")
 	}
 }
+
+// Creates admin API key and prints it, so the first admin gets access:
+//
+//	main create-admin-key [name]
+func createAdminKey(sr *subs.Client, args []string) {
+	key := models.APIKey{
+		Name:  "admin",
+		Admin: true,
+	}
+	if len(args) > 0 {
+		key.Name = args[0]
+	}
+	plain, hash, err := auth.GenerateKey()
+	if err != nil {
+		log.Fatal("generating api key error: " + err.Error())
+	}
+	if err = sr.AddAPIKey(context.Background(), &key, hash); err != nil {
+		log.Fatal("saving api key error: " + err.Error())
+	}
+	fmt.Println(plain)
+}
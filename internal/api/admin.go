@@ -0,0 +1,166 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+)
+
+type apiKeyRequest struct {
+	Name  string    `json:"name" example:"billing-service"`
+	UID   uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Admin bool      `json:"admin" example:"false"`
+}
+
+// @Summary Creating API key
+// @Description Generates new API key for the user, plain key
+// @Description is returned only in this response
+// @Tags admin
+// @Router /admin/keys [post]
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body apiKeyRequest true "New key data"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	var req apiKeyRequest
+	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Name == "" {
+		slog.Error("invalid create api key request",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	plain, hash, err := auth.GenerateKey()
+	if err != nil {
+		slog.Error("error generating api key",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	key := models.APIKey{
+		Name:  req.Name,
+		UID:   req.UID,
+		Admin: req.Admin,
+	}
+	if err = s.keysRepo.AddAPIKey(r.Context(), &key, hash); err != nil {
+		slog.Error("error adding api key",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	key.Key = plain
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(key); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("api key created",
+		slog.Int("key_id", key.ID),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Listing API keys
+// @Description Returns all API keys including revoked ones,
+// @Description plain keys are never returned
+// @Tags admin
+// @Router /admin/keys [get]
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	keys, err := s.keysRepo.ListAPIKeys(r.Context())
+	if err != nil {
+		slog.Error("list api keys error",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(keys); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully listed api keys",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Revoking API key
+// @Description Revokes API key with given id
+// @Tags admin
+// @Router /admin/keys/{id} [delete]
+// @Security BearerAuth
+// @Param id path int true "Key ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		slog.Error("incoming request with invalid id",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	if err = s.keysRepo.RevokeAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("revoke request with unexisted key id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("error revoking api key",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("api key revoked",
+		slog.Int("key_id", id),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+	writeResponseMessage(w, http.StatusOK, "api key revoked")
+}
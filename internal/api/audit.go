@@ -0,0 +1,125 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testcase/internal/errvalues"
+	"testcase/models"
+	"time"
+)
+
+// Parses actor, op, from, to (RFC 3339), limit and offset query params
+func getAuditOptsFromQuery(r *http.Request) (*models.AuditOpts, error) {
+	query := r.URL.Query()
+	opts := &models.AuditOpts{
+		Actor: query.Get("actor"),
+		Op:    query.Get("op"),
+	}
+	if opts.Op != "" && !models.ValidOp(opts.Op) {
+		return nil, errvalues.ErrInvalidRequest
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if opts.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, err
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if opts.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, err
+		}
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if opts.Limit, err = strconv.Atoi(limitStr); err != nil {
+			return nil, err
+		}
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if opts.Offset, err = strconv.Atoi(offsetStr); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// @Summary Getting subscription history
+// @Description Returns audit records of the subscription, newest first
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/history [get]
+// @Param id path int true "Subscription ID"
+// @Param actor query string false "Principal made the change" Example(apikey:1)
+// @Param op query string false "Operation" Enums(create, update, delete, restore, pause, resume, cancel)
+// @Param from query string false "Start of time range, RFC 3339" Example(2025-07-01T00:00:00Z)
+// @Param to query string false "End of time range (exclusive), RFC 3339" Example(2025-08-01T00:00:00Z)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Produce json
+// @Success 200 {array} models.AuditRecord
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getSubscriptionHistory(w http.ResponseWriter, r *http.Request) {
+	s.listAudit(w, r, r.Context().Value("Sub-ID").(int))
+}
+
+// @Summary Getting audit log
+// @Description Returns audit records of subscription changes matching
+// @Description filters, newest first. Non-admin keys get records of
+// @Description their user's subscriptions only
+// @Tags audit
+// @Security BearerAuth
+// @Router /audit [get]
+// @Param actor query string false "Principal made the change" Example(apikey:1)
+// @Param op query string false "Operation" Enums(create, update, delete, restore, pause, resume, cancel)
+// @Param from query string false "Start of time range, RFC 3339" Example(2025-07-01T00:00:00Z)
+// @Param to query string false "End of time range (exclusive), RFC 3339" Example(2025-08-01T00:00:00Z)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Produce json
+// @Success 200 {array} models.AuditRecord
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	s.listAudit(w, r, 0)
+}
+
+func (s *Server) listAudit(w http.ResponseWriter, r *http.Request, subID int) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	opts, err := getAuditOptsFromQuery(r)
+	if err != nil {
+		slog.Error("incoming request with invalid query param",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	opts.SubID = subID
+	records, err := s.auditRepo.ListAudit(r.Context(), opts)
+	if err != nil {
+		slog.Error("list audit log error",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	err = writeJSON(w, r, records)
+	if err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("successfully listed audit log",
+		slog.Int("records", len(records)),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
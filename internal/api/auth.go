@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+)
+
+// Authenticates request and puts principal into its context,
+// responds with 401 if credentials are missing or invalid
+func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Context().Value("Request-ID").(string)
+		p, err := s.auth.Authenticate(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if errors.Is(err, auth.ErrNoCredentials) || errors.Is(err, auth.ErrInvalidCredentials) {
+				slog.Warn("unauthenticated request",
+					slog.String("error", err.Error()),
+					slog.String("req_id", reqID),
+					slog.String("from", r.RemoteAddr))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="subs"`)
+				writeErrorMessage(w, http.StatusUnauthorized, errvalues.ErrUnauthorized)
+				return
+			}
+			slog.Error("error authenticating request",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+			return
+		}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) adminOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.FromContext(r.Context()); !ok || !p.Admin {
+			reqID := r.Context().Value("Request-ID").(string)
+			slog.Warn("admin request from non-admin principal",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusForbidden, errvalues.ErrForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+)
+
+type batchRequest struct {
+	// If true, failed operations don't roll back the others
+	Partial    bool              `json:"partial" example:"false"`
+	Operations []*models.BatchOp `json:"operations"`
+}
+
+type batchResponse struct {
+	Error   string                `json:"error,omitempty"`
+	Results []*models.BatchResult `json:"results"`
+}
+
+// Checks that operation has fields required by its type
+func validateBatchOp(op *models.BatchOp) bool {
+	switch op.Op {
+	case models.BatchCreate:
+		return op.Sub != nil
+	case models.BatchUpdate:
+		return op.Sub != nil && op.ID > 0
+	case models.BatchDelete:
+		return op.ID > 0
+	}
+	return false
+}
+
+// @Summary Batch of subscription changes
+// @Description Executes list of create, update and delete operations in
+// @Description a single transaction. By default the batch is all-or-nothing,
+// @Description failure of any operation rolls back the whole batch and 422
+// @Description is returned. In partial mode failed operations are reported
+// @Description in per-item results and don't affect the others.
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/batch [post]
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
+// @Param request body batchRequest true "Batch operations"
+// @Success 200 {object} batchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} batchResponse
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) batchSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	var req batchRequest
+	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req)
+	if err != nil || len(req.Operations) == 0 {
+		slog.Error("invalid batch request",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	if maxSize := s.runtime.Load().BatchMaxSize; len(req.Operations) > maxSize {
+		slog.Error("batch request exceeds max size",
+			slog.Int("size", len(req.Operations)),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusRequestEntityTooLarge,
+			fmt.Errorf("%w: max %d", errvalues.ErrBatchTooLarge, maxSize))
+		return
+	}
+	for i, op := range req.Operations {
+		if op == nil || !validateBatchOp(op) {
+			slog.Error("batch request with invalid operation",
+				slog.Int("index", i),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest,
+				fmt.Errorf("%w: operation %d", errvalues.ErrInvalidRequest, i))
+			return
+		}
+	}
+	results, err := s.subsRepo.Batch(r.Context(), req.Operations, req.Partial)
+	resp := batchResponse{
+		Results: results,
+	}
+	status := http.StatusOK
+	if err != nil {
+		if !errors.Is(err, errvalues.ErrBatchRejected) {
+			slog.Error("error executing batch",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+			return
+		}
+		slog.Warn("batch rejected",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		status = http.StatusUnprocessableEntity
+		resp.Error = err.Error()
+	}
+	w.WriteHeader(status)
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("batch executed",
+		slog.Int("size", len(req.Operations)),
+		slog.Bool("partial", req.Partial),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testcase/internal/errvalues"
+	"testcase/internal/settings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          []string
+	wildcards        []wildcardOrigin
+	methods          []string
+	headers          []string
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// Origin like "https://*.example.com", matches any subdomain
+// of example.com with the same scheme
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+}
+
+func newCORSPolicy(cfg settings.CORS) *corsPolicy {
+	p := &corsPolicy{
+		allowMethods:     strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders:    strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+		maxAge:           strconv.Itoa(int(cfg.MaxAge.Seconds())),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			p.anyOrigin = true
+		} else if i := strings.Index(origin, "://*."); i != -1 {
+			p.wildcards = append(p.wildcards, wildcardOrigin{
+				prefix: origin[:i+3],
+				suffix: origin[i+4:],
+			})
+		} else {
+			p.origins = append(p.origins, origin)
+		}
+	}
+	for _, m := range cfg.AllowedMethods {
+		p.methods = append(p.methods, strings.ToUpper(m))
+	}
+	for _, h := range cfg.AllowedHeaders {
+		p.headers = append(p.headers, http.CanonicalHeaderKey(h))
+	}
+	return p
+}
+
+func (p *corsPolicy) originAllowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if slices.Contains(p.origins, origin) {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if strings.HasPrefix(origin, w.prefix) && strings.HasSuffix(origin, w.suffix) &&
+			len(origin) > len(w.prefix)+len(w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) headersAllowed(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" && !slices.Contains(p.headers, http.CanonicalHeaderKey(h)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sets Access-Control-Allow-Origin reflecting request origin, "*" is
+// used only when any origin is allowed and credentials are not
+func (p *corsPolicy) writeOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin && !p.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Applies CORS policy from settings. Preflight requests are answered
+// here: 404 for unknown routes, 403 for disallowed origin, method or
+// headers and 204 otherwise
+func (s *Server) CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := s.cors.Load()
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		reqMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || origin == "" || reqMethod == "" {
+			if origin != "" && policy.originAllowed(origin) {
+				policy.writeOrigin(w, origin)
+				if policy.exposeHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !s.mx.Match(chi.NewRouteContext(), reqMethod, r.URL.Path) {
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusNotFound, errvalues.ErrNotFound)
+			return
+		}
+		if !policy.originAllowed(origin) ||
+			!slices.Contains(policy.methods, reqMethod) ||
+			!policy.headersAllowed(r.Header.Get("Access-Control-Request-Headers")) {
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusForbidden, errvalues.ErrCORSRejected)
+			return
+		}
+		policy.writeOrigin(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", policy.allowMethods)
+		if policy.allowHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", policy.allowHeaders)
+		}
+		w.Header().Set("Access-Control-Max-Age", policy.maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
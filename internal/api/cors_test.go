@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testcase/internal/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+	handler := newTestServer(&fakeRepo{}, nil, nil, func(rt *settings.Runtime) {
+		rt.CORS = settings.CORS{
+			AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+			AllowedMethods:   []string{"GET", "POST", "PUT"},
+			AllowedHeaders:   []string{"Authorization", "Idempotency-Key"},
+			ExposedHeaders:   []string{"X-Request-ID"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		}
+	}).Handler()
+
+	t.Run("origin is reflected", func(t *testing.T) {
+		for _, origin := range []string{"https://app.example.com", "https://api.example.org", "https://a.b.example.org"} {
+			req := httptest.NewRequest(http.MethodGet, "/v1/subs/list", nil)
+			req.Header.Set("Origin", origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, origin, rec.Header().Get("Access-Control-Allow-Origin"), origin)
+			assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, "X-Request-ID", rec.Header().Get("Access-Control-Expose-Headers"))
+			assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+		}
+	})
+	t.Run("disallowed origin isn't reflected", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.com", "https://example.org", "http://api.example.org", "https://app.example.com.evil.com"} {
+			req := httptest.NewRequest(http.MethodGet, "/v1/subs/list", nil)
+			req.Header.Set("Origin", origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	})
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/subs/add", nil)
+		req.Header.Set("Origin", "https://api.example.org")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "authorization, idempotency-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://api.example.org", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, POST, PUT", rec.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Authorization, Idempotency-Key", rec.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	})
+	t.Run("preflight is rejected", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			origin  string
+			method  string
+			headers string
+		}{
+			{"origin", "https://evil.com", "POST", ""},
+			{"method", "https://app.example.com", "DELETE", ""},
+			{"headers", "https://app.example.com", "POST", "Authorization, X-Custom"},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodOptions, "/v1/subs/1", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusForbidden, rec.Code, tt.name)
+			assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), tt.name)
+		}
+	})
+	t.Run("preflight of unknown route", func(t *testing.T) {
+		for _, path := range []string{"/v1/unknown", "/v3/subs/list"} {
+			req := httptest.NewRequest(http.MethodOptions, path, nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Access-Control-Request-Method", "GET")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotFound, rec.Code, path)
+			assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), path)
+		}
+	})
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	t.Parallel()
+	handler := newTestServer(&fakeRepo{}, nil, nil, nil).Handler()
+	req := httptest.NewRequest(http.MethodGet, "/v1/subs/list", nil)
+	req.Header.Set("Origin", "https://any.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+}
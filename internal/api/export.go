@@ -0,0 +1,267 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"testcase/internal/errvalues"
+	"testcase/models"
+)
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	// Rows written between flushes of streamed response
+	flushEvery = 500
+)
+
+var formatMediaTypes = map[string]string{
+	"application/json":     formatJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+	"application/jsonl":    formatNDJSON,
+}
+
+var formatContentTypes = map[string]string{
+	formatCSV:    "text/csv; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// Picks the offered format most preferred by Accept header, the first
+// offered one is used if there is no header. Returns empty string if
+// none of offered formats is acceptable
+func negotiateFormat(r *http.Request, offered ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qStr, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qStr, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, format := range offered {
+			if mediaMatches(mediaType, format) {
+				best, bestQ = format, q
+				break
+			}
+		}
+	}
+	return best
+}
+
+func mediaMatches(mediaType, format string) bool {
+	if mediaType == "*/*" {
+		return true
+	}
+	for mt, f := range formatMediaTypes {
+		if f != format {
+			continue
+		}
+		if mt == mediaType {
+			return true
+		}
+		if typ, ok := strings.CutSuffix(mediaType, "/*"); ok && strings.HasPrefix(mt, typ+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func subscriptionRecord(sub *models.Subscription, f models.Format) []string {
+	expires := ""
+	if sub.Expires != nil {
+		expires = f.Date(*sub.Expires)
+	}
+	trialEnd, trialPrice := "", ""
+	if sub.TrialEnd != nil {
+		trialEnd = f.Date(*sub.TrialEnd)
+	}
+	if sub.TrialPrice != nil {
+		trialPrice = sub.TrialPrice.String()
+	}
+	return []string{
+		strconv.Itoa(sub.ID),
+		sub.UID.String(),
+		sub.Name,
+		sub.Price.String(),
+		f.Date(sub.Start),
+		expires,
+		sub.BillingPeriod,
+		sub.Currency,
+		trialEnd,
+		trialPrice,
+		sub.Status,
+	}
+}
+
+// Writes subscriptions in CSV or NDJSON format as they are read from db.
+// Once the first row is sent status can't be changed anymore, so
+// failure in the middle of stream aborts the connection
+func (s *Server) streamSubscriptions(w http.ResponseWriter, r *http.Request, format string, opts *models.ListOpts) {
+	reqID := r.Context().Value("Request-ID").(string)
+	f := outputFormat(r)
+	rc := http.NewResponseController(w)
+	// Nil sub only makes sure that CSV header is written for empty result
+	var write func(sub *models.Subscription) error
+	flush := rc.Flush
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		header := []string{"id", "uid", "name", "price", "start_date", "expires", "billing_period", "currency", "trial_end", "trial_price", "status"}
+		write = func(sub *models.Subscription) error {
+			if header != nil {
+				if err := cw.Write(header); err != nil {
+					return err
+				}
+				header = nil
+			}
+			if sub == nil {
+				return nil
+			}
+			return cw.Write(subscriptionRecord(sub, f))
+		}
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+	case formatNDJSON:
+		write = func(sub *models.Subscription) error {
+			if sub == nil {
+				return nil
+			}
+			data, err := sub.MarshalFormat(f)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(data, '\n'))
+			return err
+		}
+	}
+	started := false
+	count := 0
+	fail := func(err error) {
+		slog.Error("error streaming subscriptions",
+			slog.String("error", err.Error()),
+			slog.Int("rows", count),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		if !started {
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+	start := func() {
+		w.Header().Set("Content-Type", formatContentTypes[format])
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+	for sub, err := range s.subsRepo.StreamSubs(r.Context(), opts) {
+		if err != nil {
+			fail(err)
+			return
+		}
+		if !started {
+			start()
+		}
+		if err = write(sub); err != nil {
+			fail(err)
+			return
+		}
+		if count++; count%flushEvery == 0 {
+			if err = flush(); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+	if !started {
+		start()
+	}
+	if err := write(nil); err != nil {
+		fail(err)
+		return
+	}
+	if err := flush(); err != nil {
+		fail(err)
+		return
+	}
+	slog.Info("successfully streamed subscriptions",
+		slog.Int("rows", count),
+		slog.String("format", format),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Exporting subscriptions
+// @Description Streams subscriptions matching filters as CSV or JSON Lines
+// @Description chosen by Accept header (JSON Lines by default). Rows are
+// @Description sent as they are read, so export size isn't limited
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/export [get]
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Param order query string false "Field to sort by: id, name, uid, price, created_at or expires, optionally followed by asc or desc" Example(price desc)
+// @Param include_deleted query bool false "Include deleted subscriptions, admins only"
+// @Param status query string false "Comma separated statuses" Example(active,trial)
+// @Produce application/x-ndjson,text/csv
+// @Success 200 {array} models.Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) exportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value("Request-ID").(string)
+	format := negotiateFormat(r, formatNDJSON, formatCSV)
+	if format == "" {
+		slog.Error("export request with unacceptable format",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		w.Header().Set("Content-Type", "application/json")
+		writeErrorMessage(w, http.StatusNotAcceptable, errvalues.ErrNotAcceptable)
+		return
+	}
+	opts, err := getListOptsFromQuery(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, errvalues.ErrForbidden) {
+			slog.Warn("non-admin request for deleted subscriptions",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusForbidden, err)
+			return
+		}
+		slog.Error("incoming request with invalid query param",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	s.streamSubscriptions(w, r, format, opts)
+}
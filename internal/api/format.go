@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testcase/internal/errvalues"
+	"testcase/models"
+)
+
+// Values of date_format param, overriding format of dates of API version
+const (
+	dateFormatMonth = "month"
+	dateFormatFull  = "full"
+)
+
+// Rejects requests with unknown date_format param
+func dateFormatMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("date_format") {
+		case "", dateFormatMonth, dateFormatFull:
+			next.ServeHTTP(w, r)
+		default:
+			slog.Error("incoming request with invalid date format",
+				slog.String("req_id", r.Context().Value("Request-ID").(string)),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		}
+	})
+}
+
+// Sets format responses of API version are written in,
+// zero Format of v1 is used if there is none
+func versionFormatMiddleware(f models.Format) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), "Output-Format", f)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Returns format of dates and amounts in response to r
+func outputFormat(r *http.Request) models.Format {
+	f, _ := r.Context().Value("Output-Format").(models.Format)
+	switch r.URL.Query().Get("date_format") {
+	case dateFormatMonth:
+		f.FullDates = false
+	case dateFormatFull:
+		f.FullDates = true
+	}
+	return f
+}
+
+// Writes v as JSON in format of response to r
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	data, err := models.MarshalFormat(v, outputFormat(r))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
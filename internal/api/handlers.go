@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -13,23 +14,10 @@ import (
 	"github.com/google/uuid"
 )
 
-func (s *Server) CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, POST, DELETE, PUT")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
 func (s *Server) RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqID := uuid.New()
+		w.Header().Set("X-Request-ID", reqID.String())
 		ctx := context.WithValue(r.Context(), "Request-ID", reqID.String())
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
@@ -58,12 +46,19 @@ func (s *Server) subIDMiddleware(next http.Handler) http.Handler {
 // @Description Recieves new subscription info and
 // @Description saves it in DB
 // @Tags subs
+// @Security BearerAuth
 // @Router /subs/add [post]
+// @Deprecated
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
 // @Param request body models.Subscription true "New subscription data"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (s *Server) addSubscription(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -78,7 +73,10 @@ func (s *Server) addSubscription(w http.ResponseWriter, r *http.Request) {
 		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
 		return
 	}
-	err = s.subsRepo.AddSub(&sub)
+	if uid, ok := pathUserID(r); ok {
+		sub.UID = uid
+	}
+	err = s.subsRepo.AddSub(r.Context(), &sub)
 	if err != nil {
 		slog.Error("error adding subscription",
 			slog.String("error", err.Error()),
@@ -96,23 +94,31 @@ func (s *Server) addSubscription(w http.ResponseWriter, r *http.Request) {
 // @Summary Getting subcription info
 // @Description Provides subscription data by ID in path
 // @Tags subs
+// @Security BearerAuth
 // @Router /subs/{id} [get]
+// @Deprecated
 // @Param id path int true "Subscription ID"
 // @Produce json
 // @Success 200 {object} models.Subscription
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (s *Server) getSubscription(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	reqID := r.Context().Value("Request-ID").(string)
 	subID := r.Context().Value("Sub-ID").(int)
-	sub, err := s.subsRepo.GetSub(subID)
+	sub, err := s.subsRepo.GetSub(r.Context(), subID)
+	if uid, ok := pathUserID(r); ok && err == nil && sub.UID != uid {
+		err = errvalues.ErrNoSuchRow
+	}
 	if err != nil {
 		if errors.Is(err, errvalues.ErrNoSuchRow) {
 			slog.Error("get sub request with unexisted id",
 				slog.String("req_id", reqID),
 				slog.String("from", r.RemoteAddr))
-			writeErrorMessage(w, http.StatusBadRequest, err)
+			writeErrorMessage(w, http.StatusNotFound, err)
 			return
 		}
 		slog.Error("error getting subscription",
@@ -122,7 +128,7 @@ func (s *Server) getSubscription(w http.ResponseWriter, r *http.Request) {
 		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
 		return
 	}
-	err = sonic.ConfigDefault.NewEncoder(w).Encode(sub)
+	err = writeJSON(w, r, sub)
 	if err != nil {
 		slog.Error("error providing result",
 			slog.String("error", err.Error()),
@@ -140,12 +146,16 @@ func (s *Server) getSubscription(w http.ResponseWriter, r *http.Request) {
 // @Description Recieves new subscription info for update
 // @Description by provided id in path
 // @Tags subs
+// @Security BearerAuth
 // @Router /subs/{id} [put]
 // @Accept json
 // @Produce json
 // @Param request body models.Subscription true "New subscription data"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (s *Server) updateSubscription(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -161,13 +171,13 @@ func (s *Server) updateSubscription(w http.ResponseWriter, r *http.Request) {
 		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
 		return
 	}
-	err = s.subsRepo.UpdateSub(subID, &sub)
+	err = s.subsRepo.UpdateSub(r.Context(), subID, &sub)
 	if err != nil {
 		if errors.Is(err, errvalues.ErrNoSuchRow) {
 			slog.Error("update sub request with unexisted id",
 				slog.String("req_id", reqID),
 				slog.String("from", r.RemoteAddr))
-			writeErrorMessage(w, http.StatusBadRequest, err)
+			writeErrorMessage(w, http.StatusNotFound, err)
 			return
 		}
 		slog.Error("error updating subscription",
@@ -184,25 +194,30 @@ func (s *Server) updateSubscription(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Deleting subcription
-// @Description Deletes subscription with given id
+// @Description Deletes subscription with given id, it can be
+// @Description restored until retention period is over
 // @Tags subs
+// @Security BearerAuth
 // @Router /subs/{id} [delete]
 // @Param id path int true "Subscription ID"
 // @Produce json
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (s *Server) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	reqID := r.Context().Value("Request-ID").(string)
 	subID := r.Context().Value("Sub-ID").(int)
-	err := s.subsRepo.DeleteSub(subID)
+	err := s.subsRepo.DeleteSub(r.Context(), subID)
 	if err != nil {
 		if errors.Is(err, errvalues.ErrNoSuchRow) {
 			slog.Error("delete sub request with unexisted id",
 				slog.String("req_id", reqID),
 				slog.String("from", r.RemoteAddr))
-			writeErrorMessage(w, http.StatusBadRequest, err)
+			writeErrorMessage(w, http.StatusNotFound, err)
 			return
 		}
 		slog.Error("error deleting subscription",
@@ -218,52 +233,101 @@ func (s *Server) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 	writeResponseMessage(w, http.StatusOK, "subscription deleted")
 }
 
+// @Summary Restoring subscription
+// @Description Restores deleted subscription with given id
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/restore [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) restoreSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	err := s.subsRepo.RestoreSub(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("restore request with unexisted or not deleted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("error restoring subscription",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("subscription successfully restored",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+	writeResponseMessage(w, http.StatusOK, "subscription restored")
+}
+
 // @Summary Listing subscriptions
 // @Description Returns list of subscriptions with given
-// @Description unnecessary filters
+// @Description unnecessary filters. With Accept: text/csv or
+// @Description application/x-ndjson rows are streamed in that format
 // @Tags subs
+// @Security BearerAuth
 // @Router /subs/list [get]
+// @Deprecated
 // @Param name query string false "Sub's service name" Example(Spotify)
 // @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
 // @Param limit query int false "Returned rows limit"
 // @Param offset query int false "Offset for paginations"
-// @Param order query string false "Filed name for sorting by" Example(name, uid, id, created_at, expires, price)
-// @Produce json
+// @Param order query string false "Field to sort by: id, name, uid, price, created_at or expires, optionally followed by asc or desc" Example(price desc)
+// @Param include_deleted query bool false "Include deleted subscriptions, admins only"
+// @Param status query string false "Comma separated statuses" Example(active,trial)
+// @Produce json,text/csv,application/x-ndjson
 // @Success 200 {array} models.Subscription
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	reqID := r.Context().Value("Request-ID").(string)
-	filter := getFilterFromQuery(r)
-	var limit, offset int
-	var err error
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			slog.Error("incoming request with invalid query param",
-				slog.String("req_id", reqID),
-				slog.String("from", r.RemoteAddr))
-			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
-			return
-		}
+	format := negotiateFormat(r, formatJSON, formatCSV, formatNDJSON)
+	if format == "" {
+		slog.Error("list request with unacceptable format",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		w.Header().Set("Content-Type", "application/json")
+		writeErrorMessage(w, http.StatusNotAcceptable, errvalues.ErrNotAcceptable)
+		return
 	}
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		offset, err = strconv.Atoi(offsetStr)
-		if err != nil {
-			slog.Error("incoming request with invalid query param",
+	opts, err := getListOptsFromQuery(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, errvalues.ErrForbidden) {
+			slog.Warn("non-admin request for deleted subscriptions",
 				slog.String("req_id", reqID),
 				slog.String("from", r.RemoteAddr))
-			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+			writeErrorMessage(w, http.StatusForbidden, err)
 			return
 		}
+		slog.Error("incoming request with invalid query param",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
 	}
-	list, err := s.subsRepo.ListSubs(&models.ListOpts{
-		Limit:  limit,
-		Offset: offset,
-		Filter: filter,
-		Order:  r.URL.Query().Get("order"),
-	})
+	if format != formatJSON {
+		s.streamSubscriptions(w, r, format, opts)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	list, err := s.subsRepo.ListSubs(r.Context(), opts)
 	if err != nil {
 		slog.Error("list subscriptions error",
 			slog.String("error", err.Error()),
@@ -272,7 +336,7 @@ func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
 		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
 		return
 	}
-	err = sonic.ConfigDefault.NewEncoder(w).Encode(list)
+	err = writeJSON(w, r, list)
 	if err != nil {
 		slog.Error("error providing result",
 			slog.String("error", err.Error()),
@@ -287,21 +351,63 @@ func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
 }
 
 type sumResponse struct {
-	Sum int `json:"sum" example:"1000"`
+	Sum models.Money `json:"sum" swaggertype:"number" example:"1000.00"`
+	// Currency sum is converted to, prices are summed as is if empty
+	Currency string `json:"currency,omitempty" example:"RUB"`
+	// Spend per original currency, returned with per_currency=true
+	Totals map[string]models.Money `json:"totals,omitempty" swaggertype:"object,number"`
+}
+
+func (resp sumResponse) MarshalFormat(f models.Format) ([]byte, error) {
+	dst := struct {
+		Sum      json.RawMessage            `json:"sum"`
+		Currency string                     `json:"currency,omitempty"`
+		Totals   map[string]json.RawMessage `json:"totals,omitempty"`
+	}{
+		Currency: resp.Currency,
+	}
+	var err error
+	if dst.Sum, err = resp.Sum.MarshalFormat(f); err != nil {
+		return nil, err
+	}
+	if resp.Totals != nil {
+		dst.Totals = make(map[string]json.RawMessage, len(resp.Totals))
+		for currency, total := range resp.Totals {
+			if dst.Totals[currency], err = total.MarshalFormat(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sonic.Marshal(dst)
 }
 
 // @Summary Getting price sum
 // @Description Recieving summary subscriptions price with
 // @Description provided filters and period values (start, end),
 // @Description if start or end undefined, returns sum for all the time.
+// @Description Price is charged on billing dates of subscription with
+// @Description the price effective in the month of the charge. With currency
+// @Description param every charge is converted at the rate of its month.
+// @Description Period ends are months (MM-YYYY) or days (YYYY-MM-DD), without
+// @Description period spend is counted up to the end of user's current month
 // @Tags subs
+// @Security BearerAuth
 // @Router /subs/sum [get]
+// @Deprecated
 // @Param name query string false "Sub's service name" Example(Spotify)
 // @Param start query string false "Start period" Example(01-2015)
-// @Param end query string false "End period" Example(03-2016)
+// @Param end query string false "End period, inclusive" Example(2016-03-17)
 // @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param monthly_equivalent query bool false "Charge every active month with monthly-equivalent price"
+// @Param prorate query bool false "Count only days of billing cycles within the period"
+// @Param currency query string false "ISO 4217 code of currency to convert to" Example(USD)
+// @Param per_currency query bool false "Also return spend per original currency"
 // @Produce json
 // @Success 200 {object} sumResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (s *Server) getPriceSum(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -315,8 +421,34 @@ func (s *Server) getPriceSum(w http.ResponseWriter, r *http.Request) {
 		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
 		return
 	}
-	sum, err := s.subsRepo.PriceSum(filter, period)
+	opts, err := getSumOptsFromQuery(r)
+	perCurrency := false
+	if perStr := r.URL.Query().Get("per_currency"); err == nil && perStr != "" {
+		perCurrency, err = strconv.ParseBool(perStr)
+	}
+	if err != nil {
+		slog.Error("incoming request with invalid query param",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	resp := sumResponse{
+		Currency: opts.Currency,
+	}
+	resp.Sum, err = s.subsRepo.PriceSum(r.Context(), filter, period, opts)
+	if err == nil && perCurrency {
+		resp.Totals, err = s.subsRepo.PriceTotals(r.Context(), filter, period, opts)
+	}
 	if err != nil {
+		if errors.Is(err, errvalues.ErrNoRate) {
+			slog.Error("sum request without exchange rates",
+				slog.String("currency", opts.Currency),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusUnprocessableEntity, err)
+			return
+		}
 		slog.Error("getting subs sum error",
 			slog.String("error", err.Error()),
 			slog.String("req_id", reqID),
@@ -324,9 +456,7 @@ func (s *Server) getPriceSum(w http.ResponseWriter, r *http.Request) {
 		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
 		return
 	}
-	err = sonic.ConfigFastest.NewEncoder(w).Encode(sumResponse{
-		Sum: sum,
-	})
+	err = writeJSON(w, r, resp)
 	if err != nil {
 		slog.Error("error providing result",
 			slog.String("error", err.Error()),
@@ -0,0 +1,24 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListInvalidOrder(t *testing.T) {
+	t.Parallel()
+	handler := newTestServer(&fakeRepo{}, nil, nil, nil).Handler()
+	for _, path := range []string{"/v1/subs/list", "/v1/subs/export", "/v1/users/" + testUID.String() + "/subscriptions/"} {
+		for _, order := range []string{"cost", "name sideways", "(SELECT name FROM subscriptions WHERE uid = '00000000-0000-0000-0000-000000000000')"} {
+			req := httptest.NewRequest(http.MethodGet, path+"?order="+url.QueryEscape(order), nil)
+			req.Header.Set("X-API-Key", "first")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, path+" "+order)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"time"
+)
+
+const (
+	maxIdempotencyKeyLen = 255
+	maxIdempotentBody    = 1 << 20
+	// Added to aggregate timeout bounding idempotent requests, so
+	// reservation of the request in progress isn't taken over
+	idempotencyLeaseMargin = 30 * time.Second
+)
+
+// Captures status and body of the response while writing it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Honours Idempotency-Key header: the first request's response is
+// stored, repeated requests with the same key and body get it replayed,
+// the same key with another body is rejected with 422. Keys are scoped
+// by principal. Responses with 5xx status aren't stored, so such
+// requests may be retried. Key of the request in progress is reserved
+// for its timeout only, so the key of the request which didn't finish
+// is freed soon
+func (s *Server) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reqID := r.Context().Value("Request-ID").(string)
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil || len(key) > maxIdempotencyKeyLen || len(body) > maxIdempotentBody {
+			slog.Error("invalid idempotent request",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+		reqHash := hex.EncodeToString(hash.Sum(nil))
+		var scope string
+		if p, ok := auth.FromContext(r.Context()); ok {
+			scope = p.Subject
+		}
+
+		rt := s.runtime.Load()
+		record, reserved, err := s.idemRepo.ReserveIdempotencyKey(r.Context(), scope, key, reqHash, rt.AggregateTimeout+idempotencyLeaseMargin)
+		if err != nil && !errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("error reserving idempotency key",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+			return
+		}
+		if !reserved {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case record != nil && record.RequestHash != reqHash:
+				slog.Warn("idempotency key reused with another request",
+					slog.String("req_id", reqID),
+					slog.String("from", r.RemoteAddr))
+				writeErrorMessage(w, http.StatusUnprocessableEntity, errvalues.ErrKeyReused)
+			case record == nil || record.Status == nil:
+				// Record was released between reserving and reading
+				// or the first request is still in progress
+				writeErrorMessage(w, http.StatusConflict, errvalues.ErrKeyInProgress)
+			default:
+				slog.Info("replaying idempotent response",
+					slog.String("req_id", reqID),
+					slog.String("from", r.RemoteAddr))
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(*record.Status)
+				_, _ = w.Write(record.Response)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		// Request context may be already cancelled by the client
+		ctx := context.WithoutCancel(r.Context())
+		if rec.status >= http.StatusInternalServerError || rec.status == 0 {
+			err = s.idemRepo.ReleaseIdempotencyKey(ctx, scope, key)
+		} else {
+			err = s.idemRepo.SaveIdempotentResponse(ctx, scope, key, rec.status, rec.body.Bytes(), rt.IdempotencyTTL)
+		}
+		if err != nil {
+			slog.Error("error storing idempotency result",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+		}
+	})
+}
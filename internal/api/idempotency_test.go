@@ -0,0 +1,166 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Keeps idempotency records in memory. If entered is set, AddSub
+// signals it and waits until block is closed
+type idemRepo struct {
+	fakeRepo
+	mu      sync.Mutex
+	records map[string]*models.IdempotencyRecord
+	added   int
+	fail    bool
+	// Durations the key was last reserved and saved for
+	lease, ttl time.Duration
+	entered    chan struct{}
+	block      chan struct{}
+}
+
+func newIdemRepo() *idemRepo {
+	return &idemRepo{records: make(map[string]*models.IdempotencyRecord)}
+}
+
+func (f *idemRepo) ReserveIdempotencyKey(ctx context.Context, scope, key, hash string, lease time.Duration) (*models.IdempotencyRecord, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if record, ok := f.records[scope+":"+key]; ok {
+		copied := *record
+		return &copied, false, nil
+	}
+	f.records[scope+":"+key] = &models.IdempotencyRecord{RequestHash: hash}
+	f.lease = lease
+	return nil, true, nil
+}
+
+func (f *idemRepo) SaveIdempotentResponse(ctx context.Context, scope, key string, status int, response []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := f.records[scope+":"+key]
+	record.Status, record.Response = &status, response
+	f.ttl = ttl
+	return nil
+}
+
+func (f *idemRepo) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.records, scope+":"+key)
+	return nil
+}
+
+func (f *idemRepo) AddSub(ctx context.Context, s *models.Subscription) error {
+	if f.entered != nil {
+		f.entered <- struct{}{}
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("db is down")
+	}
+	f.added++
+	return nil
+}
+
+const idemBody = `{"name":"yandex","price":400,"uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"07-2025"}`
+
+func idempotentRequest(handler http.Handler, principal, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/subs/add", strings.NewReader(body))
+	req.Header.Set("X-API-Key", principal)
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+	repo := newIdemRepo()
+	handler := newTestServer(repo, nil, nil, nil).Handler()
+
+	t.Run("first request", func(t *testing.T) {
+		rec := idempotentRequest(handler, "first", "key-1", idemBody)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 1, repo.added)
+		// Reserved for request timeout, response is kept for TTL
+		assert.Equal(t, 45*time.Second, repo.lease)
+		assert.Equal(t, 24*time.Hour, repo.ttl)
+	})
+	t.Run("replay", func(t *testing.T) {
+		first := idempotentRequest(handler, "first", "key-1", idemBody)
+		rec := idempotentRequest(handler, "first", "key-1", idemBody)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Body.String(), rec.Body.String())
+		assert.JSONEq(t, `{"cod":200,"msg":"sub added"}`, rec.Body.String())
+		assert.Equal(t, 1, repo.added)
+	})
+	t.Run("mismatched body", func(t *testing.T) {
+		rec := idempotentRequest(handler, "first", "key-1", strings.Replace(idemBody, "400", "500", 1))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.JSONEq(t, `{"cod":422,"error":"idempotency key was used with another request"}`, rec.Body.String())
+		assert.Equal(t, 1, repo.added)
+	})
+	t.Run("keys are scoped by principal", func(t *testing.T) {
+		rec := idempotentRequest(handler, "second", "key-1", idemBody)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 2, repo.added)
+	})
+	t.Run("without key", func(t *testing.T) {
+		rec := idempotentRequest(handler, "first", "", idemBody)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 3, repo.added)
+	})
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	t.Parallel()
+	repo := newIdemRepo()
+	repo.entered, repo.block = make(chan struct{}), make(chan struct{})
+	handler := newTestServer(repo, nil, nil, nil).Handler()
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- idempotentRequest(handler, "first", "key-1", idemBody)
+	}()
+	<-repo.entered
+	rec := idempotentRequest(handler, "first", "key-1", idemBody)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"cod":409,"error":"request with this idempotency key is in progress"}`, rec.Body.String())
+
+	close(repo.block)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, 1, repo.added)
+}
+
+func TestIdempotencyServerError(t *testing.T) {
+	t.Parallel()
+	repo := newIdemRepo()
+	repo.fail = true
+	handler := newTestServer(repo, nil, nil, nil).Handler()
+
+	rec := idempotentRequest(handler, "first", "key-1", idemBody)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, repo.records)
+
+	// Key is released, so the request may be retried
+	repo.fail = false
+	rec = idempotentRequest(handler, "first", "key-1", idemBody)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, repo.added)
+}
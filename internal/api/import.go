@@ -0,0 +1,165 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"testcase/internal/importer"
+
+	"github.com/bytedance/sonic"
+)
+
+type importResponse struct {
+	DryRun bool `json:"dry_run" example:"false"`
+	// Number of inserted rows, or valid ones in dry-run mode
+	Imported      int64               `json:"imported" example:"28"`
+	RejectedCount int                 `json:"rejected_count" example:"2"`
+	Rejected      []importer.Rejected `json:"rejected"`
+}
+
+// Returns import format from format query param or Content-Type
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return "jsonl"
+	}
+	return ""
+}
+
+// @Summary Importing subscriptions
+// @Description Imports subscriptions from CSV with header row or from JSON Lines
+// @Description in subscription format. Format is taken from format param or
+// @Description Content-Type (text/csv, application/x-ndjson). Invalid rows are
+// @Description skipped and reported with line numbers, valid ones are inserted
+// @Description at once. In dry-run mode nothing is inserted. Rows without uid
+// @Description belong to the requesting user.
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/import [post]
+// @Accept plain
+// @Produce json
+// @Param format query string false "Input format" Enums(csv, jsonl)
+// @Param columns query string false "CSV column mapping overriding configured one" Example(name:service,price:cost)
+// @Param dry_run query bool false "Only validate input"
+// @Param request body string true "CSV or JSON Lines data"
+// @Success 200 {object} importResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) importSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	var dryRun bool
+	if dryRunStr := r.URL.Query().Get("dry_run"); dryRunStr != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(dryRunStr); err != nil {
+			slog.Error("incoming request with invalid query param",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+			return
+		}
+	}
+	var parser *importer.Parser
+	switch importFormat(r) {
+	case "csv":
+		mapping := importer.Mapping{}
+		maps.Copy(mapping, s.runtime.Load().CSVColumns)
+		if columns := r.URL.Query().Get("columns"); columns != "" {
+			override, err := importer.ParseMapping(columns)
+			if err != nil {
+				slog.Error("import request with invalid column mapping",
+					slog.String("error", err.Error()),
+					slog.String("req_id", reqID),
+					slog.String("from", r.RemoteAddr))
+				writeErrorMessage(w, http.StatusBadRequest, err)
+				return
+			}
+			maps.Copy(mapping, override)
+		}
+		var err error
+		if parser, err = importer.NewCSV(r.Body, mapping); err != nil {
+			slog.Error("import request with invalid csv header",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest, err)
+			return
+		}
+	case "jsonl":
+		parser = importer.NewJSONL(r.Body)
+	default:
+		slog.Error("import request with unsupported format",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusUnsupportedMediaType, errvalues.ErrUnsupported)
+		return
+	}
+	if uid, ok := pathUserID(r); ok {
+		parser.DefaultUID = uid
+	} else if p, ok := auth.FromContext(r.Context()); ok {
+		parser.DefaultUID = p.UID
+	}
+
+	var imported int64
+	var err error
+	if dryRun {
+		for parser.Next() {
+			imported++
+		}
+		err = parser.Err()
+	} else {
+		imported, err = s.subsRepo.ImportSubs(r.Context(), parser.All())
+	}
+	if err != nil {
+		if inputErr := parser.Err(); inputErr != nil {
+			slog.Error("error reading import input",
+				slog.String("error", inputErr.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest, fmt.Errorf("%w: %s", errvalues.ErrInvalidRequest, inputErr))
+			return
+		}
+		slog.Error("error importing subscriptions",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	resp := importResponse{
+		DryRun:        dryRun,
+		Imported:      imported,
+		RejectedCount: parser.RejectedCount,
+		Rejected:      parser.Rejected,
+	}
+	if resp.Rejected == nil {
+		resp.Rejected = []importer.Rejected{}
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("subscriptions imported",
+		slog.Int64("imported", imported),
+		slog.Int("rejected", parser.RejectedCount),
+		slog.Bool("dry_run", dryRun),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
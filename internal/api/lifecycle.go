@@ -0,0 +1,227 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"testcase/internal/errvalues"
+)
+
+// @Summary Pausing subscription
+// @Description Pauses the subscription from the current day in time zone
+// @Description of its user, charges falling on paused days are skipped
+// @Description until it is resumed
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/pause [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} models.Pause
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) pauseSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	pause, err := s.subsRepo.PauseSub(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("pause request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, errvalues.ErrAlreadyPaused) {
+			slog.Error("pause request for paused subscription",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, errvalues.ErrCancelled) {
+			slog.Error("pause request for cancelled subscription",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusConflict, err)
+			return
+		}
+		slog.Error("error pausing subscription",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = writeJSON(w, r, pause); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("subscription successfully paused",
+		slog.Int("sub_id", subID),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Resuming subscription
+// @Description Resumes paused subscription from the current day in time
+// @Description zone of its user and returns the closed pause
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/resume [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} models.Pause
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) resumeSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	pause, err := s.subsRepo.ResumeSub(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("resume request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, errvalues.ErrNotPaused) {
+			slog.Error("resume request for not paused subscription",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusConflict, err)
+			return
+		}
+		slog.Error("error resuming subscription",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = writeJSON(w, r, pause); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("subscription successfully resumed",
+		slog.Int("sub_id", subID),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Listing pauses
+// @Description Returns pause intervals of the subscription ordered by start
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/pauses [get]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {array} models.Pause
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listPauses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	pauses, err := s.subsRepo.ListPauses(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("pauses request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("error listing pauses",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = writeJSON(w, r, pauses); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully listed pauses",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Cancelling subscription
+// @Description Cancels the subscription at the end of its billing period
+// @Description containing the current day in time zone of its user, so
+// @Description the paid period isn't cut. Open pause is closed
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/cancel [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} models.Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) cancelSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	sub, err := s.subsRepo.CancelSub(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("cancel request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, errvalues.ErrCancelled) {
+			slog.Error("cancel request for cancelled subscription",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusConflict, err)
+			return
+		}
+		slog.Error("error cancelling subscription",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = writeJSON(w, r, sub); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("subscription successfully cancelled",
+		slog.Int("sub_id", subID),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+)
+
+// @Summary Scheduling price change
+// @Description Sets price of the subscription effective from the month,
+// @Description spend of earlier months isn't affected. Price set for
+// @Description the same month before is replaced
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/prices [post]
+// @Param id path int true "Subscription ID"
+// @Accept json
+// @Produce json
+// @Param request body models.PriceChange true "New price and its effective month"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) schedulePrice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	var change models.PriceChange
+	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&change)
+	if err != nil || change.Price.Sign() < 0 {
+		slog.Error("invalid price change request",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	err = s.subsRepo.SchedulePrice(r.Context(), subID, &change)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("price change request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, errvalues.ErrInvalidPrice) {
+			slog.Error("price change request with invalid price",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest, err)
+			return
+		}
+		slog.Error("error scheduling price change",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("price change successfully scheduled",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+	writeResponseMessage(w, http.StatusOK, "price change scheduled")
+}
+
+// @Summary Getting price history
+// @Description Returns prices of the subscription ordered by
+// @Description effective month, including scheduled ones
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/prices [get]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {array} models.PriceChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listPrices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	prices, err := s.subsRepo.ListPrices(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("price history request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("error listing prices",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	err = writeJSON(w, r, prices)
+	if err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("successfully listed prices",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
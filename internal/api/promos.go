@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+)
+
+// @Summary Adding promo discount
+// @Description Adds discount of subscription price for number of months,
+// @Description percent off the price or fixed amount off it. Of
+// @Description overlapping promos the last added one is applied
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/promos [post]
+// @Param id path int true "Subscription ID"
+// @Accept json
+// @Produce json
+// @Param request body models.Promo true "Promo discount"
+// @Success 200 {object} models.Promo
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) addPromo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	var promo models.Promo
+	if err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&promo); err != nil {
+		slog.Error("invalid promo request",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	err := s.subsRepo.AddPromo(r.Context(), subID, &promo)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("promo request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, errvalues.ErrInvalidPrice) {
+			slog.Error("promo request with invalid amount",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest, err)
+			return
+		}
+		slog.Error("error adding promo",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = writeJSON(w, r, promo); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("promo successfully added",
+		slog.Int("promo_id", promo.ID),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Listing promo discounts
+// @Description Returns promo discounts of the subscription ordered by start month
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/promos [get]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {array} models.Promo
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listPromos(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	subID := r.Context().Value("Sub-ID").(int)
+	promos, err := s.subsRepo.ListPromos(r.Context(), subID)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("promos request with unexisted id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("error listing promos",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = writeJSON(w, r, promos); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully listed promos",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"testcase/internal/ratelimit"
+)
+
+// Returns middleware limiting requests with the limit configured for
+// route, "default" stands for the default limit and "ip" for the per IP
+// one. Clients are identified by principal if request is authenticated,
+// otherwise by IP. If limit isn't configured or limiting is disabled,
+// requests pass through
+func (s *Server) rateLimit(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := s.runtime.Load().RateLimits
+			limit, ok := cfg.Routes[route]
+			switch route {
+			case "default":
+				limit, ok = cfg.Default, true
+			case "ip":
+				limit, ok = cfg.PerIP, true
+			}
+			if !cfg.Enabled || !ok || s.limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			reqID := r.Context().Value("Request-ID").(string)
+			res, err := s.limiter.Take(r.Context(), route+":"+clientKey(r), ratelimit.Limit{
+				Rate:  limit.Rate,
+				Burst: limit.Burst,
+			})
+			if err != nil {
+				// Limiter failure shouldn't make the service unavailable
+				slog.Error("rate limiter error",
+					slog.String("error", err.Error()),
+					slog.String("req_id", reqID),
+					slog.String("from", r.RemoteAddr))
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+			if !res.Allowed {
+				slog.Warn("rate limit exceeded",
+					slog.String("route", route),
+					slog.String("req_id", reqID),
+					slog.String("from", r.RemoteAddr))
+				w.Header().Set("Retry-After", strconv.Itoa(int(res.RetryAfter.Seconds())))
+				w.Header().Set("Content-Type", "application/json")
+				writeErrorMessage(w, http.StatusTooManyRequests, errvalues.ErrRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,112 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testcase/internal/ratelimit"
+	"testcase/internal/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func limitsConfig(perIP, burst int) func(rt *settings.Runtime) {
+	return func(rt *settings.Runtime) {
+		rt.RateLimits = settings.RateLimits{
+			Enabled: true,
+			Default: settings.RateLimit{Rate: 0.1, Burst: burst},
+			PerIP:   settings.RateLimit{Rate: 0.1, Burst: perIP},
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	handler := newTestServer(&fakeRepo{}, ratelimit.NewMemoryStore(), nil, limitsConfig(100, 2)).Handler()
+	request := func(key string) *httptest.ResponseRecorder {
+		// Admin route is rejected for non-admins after limiting
+		req := httptest.NewRequest(http.MethodGet, "/v1/admin/keys/", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("headers", func(t *testing.T) {
+		for _, remaining := range []string{"1", "0"} {
+			rec := request("first")
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, remaining, rec.Header().Get("RateLimit-Remaining"))
+			assert.NotEmpty(t, rec.Header().Get("RateLimit-Reset"))
+			assert.Empty(t, rec.Header().Get("Retry-After"))
+		}
+	})
+	t.Run("exceeded", func(t *testing.T) {
+		rec := request("first")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "20", rec.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"cod":429,"error":"too many requests"}`, rec.Body.String())
+	})
+	t.Run("principals are limited separately", func(t *testing.T) {
+		rec := request("second")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	})
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	t.Parallel()
+	handler := newTestServer(&fakeRepo{}, ratelimit.NewMemoryStore(), nil, limitsConfig(2, 100)).Handler()
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/subs/list", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Unauthenticated requests are limited before authentication
+	for range 2 {
+		assert.Equal(t, http.StatusUnauthorized, request("192.0.2.1:1234").Code)
+	}
+	rec := request("192.0.2.1:4321")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+	assert.Empty(t, rec.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(t, http.StatusUnauthorized, request("192.0.2.2:1234").Code)
+}
+
+func TestRateLimitPassThrough(t *testing.T) {
+	t.Parallel()
+	failing := ratelimit.StoreFunc(func(ctx context.Context, key string, limit ratelimit.Limit) (*ratelimit.Result, error) {
+		return nil, errors.New("store is down")
+	})
+	tests := []struct {
+		name      string
+		limiter   ratelimit.Store
+		configure func(rt *settings.Runtime)
+	}{
+		{"disabled", ratelimit.NewMemoryStore(), func(rt *settings.Runtime) {
+			limitsConfig(1, 1)(rt)
+			rt.RateLimits.Enabled = false
+		}},
+		{"limiter failure", failing, limitsConfig(1, 1)},
+	}
+	for _, tt := range tests {
+		handler := newTestServer(&fakeRepo{}, tt.limiter, nil, tt.configure).Handler()
+		for range 3 {
+			req := httptest.NewRequest(http.MethodGet, "/v1/subs/list", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, tt.name)
+			assert.Empty(t, rec.Header().Get("RateLimit-Limit"), tt.name)
+		}
+	}
+}
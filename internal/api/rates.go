@@ -0,0 +1,117 @@
+package api
+
+import (
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"testcase/internal/errvalues"
+	"testcase/internal/rates"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+)
+
+// @Summary Setting exchange rates
+// @Description Saves exchange rates to BaseCurrency (RUB) as JSON array or
+// @Description CSV with currency,effective_from,rate header, chosen by
+// @Description Content-Type. Rate set for the same currency and month is replaced
+// @Tags admin
+// @Router /admin/rates [put]
+// @Security BearerAuth
+// @Accept json,text/csv
+// @Produce json
+// @Param request body []models.ExchangeRate true "Exchange rates"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) setRates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	var list []*models.ExchangeRate
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "application/json":
+		err = sonic.ConfigDefault.NewDecoder(r.Body).Decode(&list)
+	case "text/csv":
+		list, err = rates.ParseCSV(r.Body)
+	default:
+		slog.Error("rates request with unsupported content type",
+			slog.String("content_type", mediaType),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusUnsupportedMediaType, errvalues.ErrUnsupported)
+		return
+	}
+	if err != nil || len(list) == 0 {
+		slog.Error("invalid set rates request",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	if err = s.ratesRepo.SetRates(r.Context(), list); err != nil {
+		slog.Error("error setting exchange rates",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("exchange rates set",
+		slog.Int("rates", len(list)),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+	writeResponseMessage(w, http.StatusOK, "rates set")
+}
+
+// @Summary Listing exchange rates
+// @Description Returns exchange rates to BaseCurrency (RUB)
+// @Description ordered by currency and effective month
+// @Tags admin
+// @Router /admin/rates [get]
+// @Security BearerAuth
+// @Param currency query string false "ISO 4217 currency code" Example(USD)
+// @Produce json
+// @Success 200 {array} models.ExchangeRate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listRates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency != "" && !models.ValidCurrency(currency) {
+		slog.Error("incoming request with invalid query param",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	list, err := s.ratesRepo.ListRates(r.Context(), currency)
+	if err != nil {
+		slog.Error("list exchange rates error",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(list); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully listed exchange rates",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
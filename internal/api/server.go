@@ -2,62 +2,326 @@ package api
 
 import (
 	"context"
+	"iter"
 	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"testcase/internal/auth"
+	"testcase/internal/ratelimit"
+	"testcase/internal/settings"
 	"testcase/models"
+	"time"
 
-	_ "testcase/docs"
+	_ "testcase/docs/v1"
+	_ "testcase/docs/v2"
+	v2 "testcase/internal/api/v2"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// Implementations must restrict every query to the user of
+// non-admin principal from ctx
 type SubsRepository interface {
-	AddSub(s *models.Subscription) error
-	GetSub(id int) (*models.Subscription, error)
-	UpdateSub(id int, s *models.Subscription) error
-	DeleteSub(id int) error
-	ListSubs(opts *models.ListOpts) ([]*models.Subscription, error)
-	PriceSum(filter map[string]interface{}, period *models.RangeOpts) (int, error)
+	AddSub(ctx context.Context, s *models.Subscription) error
+	GetSub(ctx context.Context, id int) (*models.Subscription, error)
+	UpdateSub(ctx context.Context, id int, s *models.Subscription) error
+	DeleteSub(ctx context.Context, id int) error
+	RestoreSub(ctx context.Context, id int) error
+	ListSubs(ctx context.Context, opts *models.ListOpts) ([]*models.Subscription, error)
+	PriceSum(ctx context.Context, filter map[string]interface{}, period *models.RangeOpts, opts *models.SumOpts) (models.Money, error)
+	PriceTotals(ctx context.Context, filter map[string]interface{}, period *models.RangeOpts, opts *models.SumOpts) (map[string]models.Money, error)
+	SchedulePrice(ctx context.Context, id int, p *models.PriceChange) error
+	ListPrices(ctx context.Context, id int) ([]*models.PriceChange, error)
+	AddPromo(ctx context.Context, id int, p *models.Promo) error
+	ListPromos(ctx context.Context, id int) ([]*models.Promo, error)
+	PauseSub(ctx context.Context, id int) (*models.Pause, error)
+	ResumeSub(ctx context.Context, id int) (*models.Pause, error)
+	ListPauses(ctx context.Context, id int) ([]*models.Pause, error)
+	CancelSub(ctx context.Context, id int) (*models.Subscription, error)
+	UpcomingCharges(ctx context.Context, filter map[string]interface{}, days int) ([]*models.Charge, error)
+	Batch(ctx context.Context, ops []*models.BatchOp, partial bool) ([]*models.BatchResult, error)
+	ImportSubs(ctx context.Context, seq iter.Seq2[*models.Subscription, error]) (int64, error)
+	StreamSubs(ctx context.Context, opts *models.ListOpts) iter.Seq2[*models.Subscription, error]
+}
+
+type KeysRepository interface {
+	AddAPIKey(ctx context.Context, key *models.APIKey, hash string) error
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+}
+
+type WebhooksRepository interface {
+	AddWebhook(ctx context.Context, e *models.WebhookEndpoint) error
+	ListWebhooks(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	ListDeliveries(ctx context.Context, id int, opts *models.DeliveryOpts) ([]*models.Delivery, error)
+}
+
+type RatesRepository interface {
+	SetRates(ctx context.Context, rates []*models.ExchangeRate) error
+	ListRates(ctx context.Context, currency string) ([]*models.ExchangeRate, error)
+}
+
+type UsersRepository interface {
+	GetUserSettings(ctx context.Context, uid uuid.UUID) (*models.UserSettings, error)
+	SetUserSettings(ctx context.Context, settings *models.UserSettings) error
+}
+
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(ctx context.Context, scope, key, hash string, lease time.Duration) (*models.IdempotencyRecord, bool, error)
+	SaveIdempotentResponse(ctx context.Context, scope, key string, status int, response []byte, ttl time.Duration) error
+	ReleaseIdempotencyKey(ctx context.Context, scope, key string) error
+}
+
+// Implementations must restrict records to the user of
+// non-admin principal from ctx
+type AuditRepository interface {
+	ListAudit(ctx context.Context, opts *models.AuditOpts) ([]*models.AuditRecord, error)
+	LastChangeID(ctx context.Context) (int64, error)
+	Changes(ctx context.Context, filter map[string]interface{}, after int64, limit int) ([]*models.AuditRecord, error)
+}
+
+// Wakes up change streams after changes of user uid are committed,
+// streams of all users subscribe with uuid.Nil
+type ChangeFeed interface {
+	Subscribe(uid uuid.UUID) (<-chan struct{}, func())
+}
+
+type Repository interface {
+	SubsRepository
+	KeysRepository
+	IdempotencyRepository
+	AuditRepository
+	RatesRepository
+	UsersRepository
+	WebhooksRepository
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*auth.Principal, error)
 }
 
 type Server struct {
-	mx        *chi.Mux
-	subsRepo  SubsRepository
-	servEntry *http.Server
+	mx           *chi.Mux
+	subsRepo     SubsRepository
+	keysRepo     KeysRepository
+	idemRepo     IdempotencyRepository
+	auditRepo    AuditRepository
+	ratesRepo    RatesRepository
+	usersRepo    UsersRepository
+	webhooksRepo WebhooksRepository
+	auth         Authenticator
+	limiter      ratelimit.Store
+	changes      ChangeFeed
+	servEntry    *http.Server
+	runtime      atomic.Pointer[settings.Runtime]
+	cors         atomic.Pointer[corsPolicy]
+	// Closed and replaced on reconfiguration, so streams apply new settings
+	reloaded atomic.Pointer[chan struct{}]
+	// Done on shutdown, so change streams are closed instead of
+	// being waited for
+	streams      context.Context
+	closeStreams context.CancelFunc
+	versions     []apiVersion
+	mount        sync.Once
 }
 
-func New(sr SubsRepository) *Server {
-	return &Server{
-		mx:       chi.NewMux(),
-		subsRepo: sr,
+func New(repo Repository, au Authenticator, limiter ratelimit.Store, changes ChangeFeed) *Server {
+	s := &Server{
+		mx:           chi.NewMux(),
+		subsRepo:     repo,
+		keysRepo:     repo,
+		idemRepo:     repo,
+		auditRepo:    repo,
+		ratesRepo:    repo,
+		usersRepo:    repo,
+		webhooksRepo: repo,
+		auth:         au,
+		limiter:      limiter,
+		changes:      changes,
 	}
+	s.streams, s.closeStreams = context.WithCancel(context.Background())
+	s.Reconfigure(settings.DefaultRuntime())
+	s.RegisterVersion("v1", s.v1Routes)
+	s.RegisterVersion("v2", s.v2Routes)
+	return s
+}
+
+// Applies runtime configuration, used as settings subscriber
+func (s *Server) Reconfigure(rt *settings.Runtime) {
+	s.runtime.Store(rt)
+	s.cors.Store(newCORSPolicy(rt.CORS))
+	reloaded := make(chan struct{})
+	if old := s.reloaded.Swap(&reloaded); old != nil {
+		close(*old)
+	}
+}
+
+// Registers router of API version name, mounted under "/"+name.
+// Versions may use their own routes and DTOs while sharing server's
+// handlers and middlewares. Swagger docs registered by swag with
+// instance name equal to name are served under "/swagger/"+name+"/"
+func (s *Server) RegisterVersion(name string, mount func(r chi.Router)) {
+	s.versions = append(s.versions, apiVersion{
+		name:  name,
+		mount: mount,
+	})
+}
+
+type apiVersion struct {
+	name  string
+	mount func(r chi.Router)
 }
 
 func (s *Server) mountEndpoints() {
-	s.mx.Use(s.CORSMiddleware, s.RequestIDMiddleware)
-	s.mx.Route("/subs", func(r chi.Router) {
-		r.Post("/add", s.addSubscription)
+	s.mx.Use(s.CORSMiddleware, s.RequestIDMiddleware, dateFormatMiddleware)
+	for _, v := range s.versions {
+		s.mx.Route("/"+v.name, v.mount)
+		s.mx.Get("/swagger/"+v.name+"/*", httpSwagger.Handler(
+			httpSwagger.URL("/swagger/"+v.name+"/doc.json"),
+			httpSwagger.InstanceName(v.name),
+		))
+	}
+	// Unversioned routes are kept for clients which existed before /v1
+	s.mx.Group(func(r chi.Router) {
+		r.Use(deprecatedMiddleware)
+		s.v1Routes(r)
+	})
+	s.mx.Get("/swagger/*", httpSwagger.Handler(
+		httpSwagger.URL("/swagger/v1/doc.json"),
+		httpSwagger.InstanceName("v1"),
+	))
+}
+
+func (s *Server) v1Routes(r chi.Router) {
+	r.Route("/subs", func(r chi.Router) {
+		r.Use(s.rateLimit("ip"), s.AuthMiddleware, s.rateLimit("default"))
+		r.With(deprecatedMiddleware, s.idempotencyMiddleware).Post("/add", s.addSubscription)
+		r.With(s.idempotencyMiddleware).Post("/batch", s.batchSubscriptions)
+		r.Post("/import", s.importSubscriptions)
 		r.Route("/{id}", func(r chi.Router) {
 			r.Use(s.subIDMiddleware)
-			r.Get("/", s.getSubscription)
+			r.With(deprecatedMiddleware).Get("/", s.getSubscription)
 			r.Put("/", s.updateSubscription)
 			r.Delete("/", s.deleteSubscription)
+			r.Post("/restore", s.restoreSubscription)
+			r.With(s.rateLimit("list")).Get("/history", s.getSubscriptionHistory)
+			r.Post("/prices", s.schedulePrice)
+			r.Get("/prices", s.listPrices)
+			r.Post("/promos", s.addPromo)
+			r.Get("/promos", s.listPromos)
+			r.Post("/pause", s.pauseSubscription)
+			r.Post("/resume", s.resumeSubscription)
+			r.Get("/pauses", s.listPauses)
+			r.Post("/cancel", s.cancelSubscription)
 		})
-		r.Get("/list", s.listSubscriptions)
-		r.Get("/sum", s.getPriceSum)
+		r.With(deprecatedMiddleware, s.rateLimit("list")).Get("/list", s.listSubscriptions)
+		r.With(s.rateLimit("list")).Get("/export", s.exportSubscriptions)
+		r.With(deprecatedMiddleware, s.rateLimit("sum")).Get("/sum", s.getPriceSum)
+		r.With(s.rateLimit("list")).Get("/upcoming", s.getUpcomingCharges)
+		r.Get("/stream", s.streamChanges)
 	})
-	s.mx.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("/swagger/doc.json"),
-	))
+	r.Route("/users/{uid}", func(r chi.Router) {
+		r.Use(s.rateLimit("ip"), s.AuthMiddleware, s.rateLimit("default"), s.userIDMiddleware)
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.With(s.rateLimit("list")).Get("/", s.listUserSubscriptions)
+			r.With(s.idempotencyMiddleware).Post("/", s.addUserSubscription)
+			r.With(s.subIDMiddleware).Get("/{id}", s.getUserSubscription)
+		})
+		r.With(s.rateLimit("sum")).Get("/spend", s.getUserSpend)
+		r.With(s.rateLimit("list")).Get("/upcoming", s.getUserUpcomingCharges)
+		r.Get("/settings", s.getUserSettings)
+		r.Put("/settings", s.setUserSettings)
+	})
+	r.Route("/audit", func(r chi.Router) {
+		r.Use(s.rateLimit("ip"), s.AuthMiddleware, s.rateLimit("default"))
+		r.With(s.rateLimit("list")).Get("/", s.getAuditLog)
+	})
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(s.rateLimit("ip"), s.AuthMiddleware, s.rateLimit("default"), s.adminOnlyMiddleware)
+		r.Route("/keys", func(r chi.Router) {
+			r.Post("/", s.createAPIKey)
+			r.Get("/", s.listAPIKeys)
+			r.Delete("/{id}", s.revokeAPIKey)
+		})
+		r.Route("/rates", func(r chi.Router) {
+			r.Put("/", s.setRates)
+			r.Get("/", s.listRates)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", s.createWebhook)
+			r.Get("/", s.listWebhooks)
+			r.Delete("/{id}", s.deleteWebhook)
+			r.With(s.rateLimit("list")).Get("/{id}/deliveries", s.listDeliveries)
+		})
+	})
+}
+
+// Routes of v2 write full dates and amounts as decimal strings
+func (s *Server) v2Routes(r chi.Router) {
+	r.Use(versionFormatMiddleware(models.FullFormat))
+	v2.Mount(r, &v2.Handlers{
+		Middlewares: v2.Middlewares{
+			RateLimit:   s.rateLimit,
+			Auth:        s.AuthMiddleware,
+			Idempotency: s.idempotencyMiddleware,
+			SubID:       s.subIDMiddleware,
+			UserID:      s.userIDMiddleware,
+			AdminOnly:   s.adminOnlyMiddleware,
+		},
+		BatchSubscriptions:     s.batchSubscriptions,
+		ImportSubscriptions:    s.importSubscriptions,
+		UpdateSubscription:     s.updateSubscription,
+		DeleteSubscription:     s.deleteSubscription,
+		RestoreSubscription:    s.restoreSubscription,
+		GetSubscriptionHistory: s.getSubscriptionHistory,
+		SchedulePrice:          s.schedulePrice,
+		ListPrices:             s.listPrices,
+		AddPromo:               s.addPromo,
+		ListPromos:             s.listPromos,
+		PauseSubscription:      s.pauseSubscription,
+		ResumeSubscription:     s.resumeSubscription,
+		ListPauses:             s.listPauses,
+		CancelSubscription:     s.cancelSubscription,
+		ExportSubscriptions:    s.exportSubscriptions,
+		GetUpcomingCharges:     s.getUpcomingCharges,
+		StreamChanges:          s.streamChanges,
+		ListUserSubscriptions:  s.listUserSubscriptions,
+		AddUserSubscription:    s.addUserSubscription,
+		GetUserSubscription:    s.getUserSubscription,
+		GetUserSpend:           s.getUserSpend,
+		GetUserUpcomingCharges: s.getUserUpcomingCharges,
+		GetUserSettings:        s.getUserSettings,
+		SetUserSettings:        s.setUserSettings,
+		GetAuditLog:            s.getAuditLog,
+		CreateAPIKey:           s.createAPIKey,
+		ListAPIKeys:            s.listAPIKeys,
+		RevokeAPIKey:           s.revokeAPIKey,
+		SetRates:               s.setRates,
+		ListRates:              s.listRates,
+		CreateWebhook:          s.createWebhook,
+		ListWebhooks:           s.listWebhooks,
+		DeleteWebhook:          s.deleteWebhook,
+		ListDeliveries:         s.listDeliveries,
+	})
+}
+
+// Returns handler of the server's endpoints. Versions registered
+// after the first call aren't mounted
+func (s *Server) Handler() http.Handler {
+	s.mount.Do(s.mountEndpoints)
+	return s.mx
 }
 
 func (s *Server) Run(address string) error {
-	s.mountEndpoints()
 	s.servEntry = &http.Server{
 		Addr:    address,
-		Handler: s.mx,
+		Handler: s.Handler(),
 	}
+	s.servEntry.RegisterOnShutdown(s.closeStreams)
 	slog.Info("server is running on " + address)
 	return s.servEntry.ListenAndServe()
 }
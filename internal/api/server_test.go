@@ -0,0 +1,84 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testcase/internal/api"
+	"testcase/internal/auth"
+	"testcase/internal/ratelimit"
+	"testcase/internal/settings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUID = uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba")
+
+// Repository methods not overridden by tests panic
+type fakeRepo struct {
+	api.Repository
+}
+
+// Authenticates requests with X-API-Key header as principal of testUID
+// with the key as subject
+type fakeAuth struct{}
+
+func (fakeAuth) Authenticate(r *http.Request) (*auth.Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	return &auth.Principal{Subject: key, UID: testUID}, nil
+}
+
+// Returns server with default runtime settings changed by configure,
+// nil limiter disables rate limiting
+func newTestServer(repo api.Repository, limiter ratelimit.Store, feed api.ChangeFeed, configure func(rt *settings.Runtime)) *api.Server {
+	s := api.New(repo, fakeAuth{}, limiter, feed)
+	if configure != nil {
+		rt := settings.DefaultRuntime()
+		configure(rt)
+		s.Reconfigure(rt)
+	}
+	return s
+}
+
+func TestVersionRoutes(t *testing.T) {
+	t.Parallel()
+	handler := newTestServer(&fakeRepo{}, nil, nil, nil).Handler()
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", "first")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, prefix := range []string{"", "/v1"} {
+		rec := request(http.MethodGet, prefix+"/subs/list?order=cost")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, prefix)
+		assert.Equal(t, "true", rec.Header().Get("Deprecation"), prefix)
+	}
+	t.Run("v2 doesn't serve deprecated routes", func(t *testing.T) {
+		// Segments after /subs are taken for invalid subscription ids
+		for _, route := range []struct {
+			method, path string
+			code         int
+		}{
+			{http.MethodPost, "/v2/subs/add", http.StatusBadRequest},
+			{http.MethodGet, "/v2/subs/1", http.StatusMethodNotAllowed},
+			{http.MethodGet, "/v2/subs/list", http.StatusBadRequest},
+			{http.MethodGet, "/v2/subs/sum", http.StatusBadRequest},
+		} {
+			rec := request(route.method, route.path)
+			assert.Equal(t, route.code, rec.Code, route.path)
+			assert.Empty(t, rec.Header().Get("Deprecation"), route.path)
+		}
+	})
+	t.Run("v2 routes aren't deprecated", func(t *testing.T) {
+		rec := request(http.MethodGet, "/v2/users/"+testUID.String()+"/subscriptions/?order=cost")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+	})
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Changes read from the log at once
+	streamBatch = 500
+	// Changes committed while older transactions are running are read
+	// once those finish, so the log is read again after notification
+	streamRecheck = time.Second
+)
+
+// @Summary Streaming subscription changes
+// @Description Pushes changes of subscriptions as Server-Sent Events once
+// @Description they are committed. Event id is id of audit record, event
+// @Description type is its op and data is the record. Comment lines are
+// @Description sent as heartbeat. Stream is resumed after event from
+// @Description Last-Event-ID header or last_event_id param, otherwise it
+// @Description starts with the next change. Users get changes of their
+// @Description own subscriptions only, admins may filter them by uid.
+// @Description Streams are closed on server shutdown, so clients reconnect
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/stream [get]
+// @Param Last-Event-ID header int false "Id of the last received event"
+// @Param last_event_id query int false "Id of the last received event, if header can't be set"
+// @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Produce text/event-stream
+// @Success 200 {object} models.AuditRecord
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) streamChanges(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value("Request-ID").(string)
+	var uid uuid.UUID
+	var after int64
+	var err error
+	if uidStr := r.URL.Query().Get("uid"); uidStr != "" {
+		uid, err = uuid.Parse(uidStr)
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if err == nil && lastID != "" {
+		after, err = strconv.ParseInt(lastID, 10, 64)
+	}
+	if err != nil || after < 0 {
+		slog.Error("stream request with invalid uid or last event id",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		w.Header().Set("Content-Type", "application/json")
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	// Repository scopes changes itself, this only spares wakeups
+	if p, ok := auth.FromContext(r.Context()); ok && !p.Admin {
+		uid = p.UID
+	}
+	var filter map[string]interface{}
+	if uid != uuid.Nil {
+		filter = map[string]interface{}{"uid": uid}
+	}
+
+	// Subscribed before the position is taken, so no change is missed
+	wake, unsubscribe := s.changes.Subscribe(uid)
+	defer unsubscribe()
+	if lastID == "" {
+		if after, err = s.auditRepo.LastChangeID(r.Context()); err != nil {
+			slog.Error("getting last change error",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+			return
+		}
+	}
+
+	format := outputFormat(r)
+	rc := http.NewResponseController(w)
+	send := func() error {
+		for {
+			changes, err := s.auditRepo.Changes(r.Context(), filter, after, streamBatch)
+			if err != nil {
+				return err
+			}
+			for _, change := range changes {
+				data, err := change.MarshalFormat(format)
+				if err != nil {
+					return err
+				}
+				_, err = io.WriteString(w, "id: "+strconv.FormatInt(change.ID, 10)+"\nevent: "+change.Op+"\ndata: "+string(data)+"\n\n")
+				if err != nil {
+					return err
+				}
+				after = change.ID
+			}
+			if len(changes) != 0 {
+				if err = rc.Flush(); err != nil {
+					return err
+				}
+			}
+			if len(changes) < streamBatch {
+				return nil
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err = rc.Flush(); err == nil {
+		err = send()
+	}
+	slog.Info("change stream started",
+		slog.Int64("after", after),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+
+	reloaded := *s.reloaded.Load()
+	heartbeat := time.NewTicker(s.runtime.Load().StreamHeartbeat)
+	defer heartbeat.Stop()
+	var recheck <-chan time.Time
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+		case <-s.streams.Done():
+			// Client reconnects to another instance and resumes
+			slog.Info("change stream closed on shutdown",
+				slog.Int64("after", after),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			return
+		case <-reloaded:
+			reloaded = *s.reloaded.Load()
+			heartbeat.Reset(s.runtime.Load().StreamHeartbeat)
+		case <-wake:
+			recheck = time.After(streamRecheck)
+		case <-recheck:
+			recheck = nil
+		case <-heartbeat.C:
+			// Log is read on heartbeat too, in case recheck was too early
+			if _, err = io.WriteString(w, ": heartbeat\n\n"); err == nil {
+				err = rc.Flush()
+			}
+		}
+		if r.Context().Err() != nil {
+			slog.Info("change stream closed",
+				slog.Int64("after", after),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			return
+		}
+		if err == nil {
+			err = send()
+		}
+	}
+	// Client reconnects and resumes after the last sent event
+	slog.Error("error streaming changes",
+		slog.String("error", err.Error()),
+		slog.Int64("after", after),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
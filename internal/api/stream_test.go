@@ -0,0 +1,184 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testcase/internal/changes"
+	"testcase/internal/settings"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Keeps change log in memory
+type changesRepo struct {
+	fakeRepo
+	mu  sync.Mutex
+	log []*models.AuditRecord
+}
+
+func (f *changesRepo) add(op string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, &models.AuditRecord{
+		ID:    int64(len(f.log) + 1),
+		SubID: 1,
+		UID:   testUID,
+		Actor: "apikey:1",
+		Op:    op,
+		After: []byte(`{"name":"yandex","price":"400.00","start_date":"2025-07-01"}`),
+	})
+}
+
+func (f *changesRepo) LastChangeID(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.log)), nil
+}
+
+func (f *changesRepo) Changes(ctx context.Context, filter map[string]interface{}, after int64, limit int) ([]*models.AuditRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if after >= int64(len(f.log)) {
+		return nil, nil
+	}
+	return f.log[after:min(int(after)+limit, len(f.log))], nil
+}
+
+type event struct {
+	id, op, data string
+}
+
+// Reads the next event, skipping comment lines
+func readEvent(t *testing.T, r *bufio.Reader) event {
+	var e event
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && e.id != "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.op = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// Opens stream, which is closed when test is done
+func openStream(t *testing.T, url string, header http.Header) *bufio.Reader {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header = header
+	req.Header.Set("X-API-Key", "first")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+func TestStreamResume(t *testing.T) {
+	t.Parallel()
+	repo := &changesRepo{}
+	for _, op := range []string{models.OpCreate, models.OpUpdate, models.OpPause} {
+		repo.add(op)
+	}
+	hub := changes.NewHub(nil)
+	srv := httptest.NewServer(newTestServer(repo, nil, hub, nil).Handler())
+	// Closed after streams of subtests
+	t.Cleanup(srv.Close)
+
+	t.Run("after Last-Event-ID", func(t *testing.T) {
+		stream := openStream(t, srv.URL+"/v1/subs/stream", http.Header{"Last-Event-Id": {"1"}})
+		e := readEvent(t, stream)
+		assert.Equal(t, "2", e.id)
+		assert.Equal(t, models.OpUpdate, e.op)
+		assert.JSONEq(t, `{"id":2,"sub_id":1,"uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","actor":"apikey:1","op":"update",`+
+			`"after":{"name":"yandex","price":400.00,"start_date":"07-2025"},"created_at":"0001-01-01T00:00:00Z"}`, e.data)
+		e = readEvent(t, stream)
+		assert.Equal(t, "3", e.id)
+		assert.Equal(t, models.OpPause, e.op)
+	})
+	t.Run("after last_event_id param", func(t *testing.T) {
+		stream := openStream(t, srv.URL+"/v2/subs/stream?last_event_id=2", http.Header{})
+		e := readEvent(t, stream)
+		assert.Equal(t, "3", e.id)
+		assert.Contains(t, e.data, `"start_date":"2025-07-01"`)
+	})
+	t.Run("new changes", func(t *testing.T) {
+		stream := openStream(t, srv.URL+"/v1/subs/stream", http.Header{})
+		repo.add(models.OpResume)
+		hub.Publish(testUID)
+		e := readEvent(t, stream)
+		assert.Equal(t, "4", e.id)
+		assert.Equal(t, models.OpResume, e.op)
+	})
+	t.Run("invalid Last-Event-ID", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/subs/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", "first")
+		req.Header.Set("Last-Event-ID", "-1")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestStreamHeartbeatReload(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(&changesRepo{}, nil, changes.NewHub(nil), func(rt *settings.Runtime) {
+		rt.StreamHeartbeat = time.Hour
+	})
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	stream := openStream(t, srv.URL+"/v1/subs/stream", http.Header{})
+	rt := settings.DefaultRuntime()
+	rt.StreamHeartbeat = 10 * time.Millisecond
+	s.Reconfigure(rt)
+	line, err := stream.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": heartbeat\n", line)
+}
+
+func TestStreamShutdown(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+	s := newTestServer(&changesRepo{}, nil, changes.NewHub(nil), nil)
+	go s.Run(address)
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	stream := openStream(t, "http://"+address+"/v1/subs/stream", http.Header{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+	_, err = io.ReadAll(stream)
+	assert.NoError(t, err)
+}
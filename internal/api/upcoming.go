@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testcase/internal/errvalues"
+)
+
+const (
+	defaultUpcomingDays = 30
+	maxUpcomingDays     = 366
+)
+
+// @Summary Getting upcoming charges
+// @Description Returns charges of subscriptions due in provided number
+// @Description of days starting from the current day of their users,
+// @Description ordered by date. Trial, promo and pauses are respected,
+// @Description amounts are in currency of subscription
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/upcoming [get]
+// @Param days query int false "Number of days, 30 by default" minimum(1) maximum(366)
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Produce json
+// @Success 200 {array} models.Charge
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getUpcomingCharges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	days := defaultUpcomingDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err == nil && (days < 1 || days > maxUpcomingDays) {
+			err = errors.New("days out of range")
+		}
+		if err != nil {
+			slog.Error("upcoming charges request with invalid days",
+				slog.String("error", err.Error()),
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+			return
+		}
+	}
+	result, err := s.subsRepo.UpcomingCharges(r.Context(), getFilterFromQuery(r), days)
+	if err != nil {
+		slog.Error("getting upcoming charges error",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	err = writeJSON(w, r, result)
+	if err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("successfully provided upcoming charges",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Getting user's upcoming charges
+// @Description Returns charges of user's subscriptions due in provided
+// @Description number of days starting from the user's current day,
+// @Description ordered by date. Trial, promo and pauses are respected,
+// @Description amounts are in currency of subscription
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/upcoming [get]
+// @Param uid path string true "User ID"
+// @Param days query int false "Number of days, 30 by default" minimum(1) maximum(366)
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Produce json
+// @Success 200 {array} models.Charge
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getUserUpcomingCharges(w http.ResponseWriter, r *http.Request) {
+	s.getUpcomingCharges(w, r)
+}
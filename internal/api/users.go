@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+)
+
+// Parses user ID from path and puts it into request context,
+// handlers use it instead of uid query param or body field.
+// Non-admin principals may access only their own user
+func (s *Server) userIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Context().Value("Request-ID").(string)
+		uid, err := uuid.Parse(r.PathValue("uid"))
+		if err != nil {
+			slog.Error("incoming request with invalid uid",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+			return
+		}
+		if p, ok := auth.FromContext(r.Context()); ok && !p.Admin && p.UID != uid {
+			slog.Warn("request to another user's resources",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorMessage(w, http.StatusForbidden, errvalues.ErrForbidden)
+			return
+		}
+		ctx := context.WithValue(r.Context(), "User-ID", uid)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Marks legacy routes which have replacement under /users
+func deprecatedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Returns user ID from path if request came through user-scoped route
+func pathUserID(r *http.Request) (uuid.UUID, bool) {
+	uid, ok := r.Context().Value("User-ID").(uuid.UUID)
+	return uid, ok
+}
+
+// @Summary Listing user's subscriptions
+// @Description Returns list of user's subscriptions with given
+// @Description unnecessary filters. With Accept: text/csv or
+// @Description application/x-ndjson rows are streamed in that format
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/subscriptions [get]
+// @Param uid path string true "User ID"
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Param order query string false "Field to sort by: id, name, uid, price, created_at or expires, optionally followed by asc or desc" Example(price desc)
+// @Param include_deleted query bool false "Include deleted subscriptions, admins only"
+// @Param status query string false "Comma separated statuses" Example(active,trial)
+// @Produce json,text/csv,application/x-ndjson
+// @Success 200 {array} models.Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	s.listSubscriptions(w, r)
+}
+
+// @Summary Registering user's subscription
+// @Description Recieves new subscription info and saves it
+// @Description in DB for the user from path
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/subscriptions [post]
+// @Param uid path string true "User ID"
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
+// @Param request body models.Subscription true "New subscription data"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) addUserSubscription(w http.ResponseWriter, r *http.Request) {
+	s.addSubscription(w, r)
+}
+
+// @Summary Getting user's subscription info
+// @Description Provides subscription data by ID in path,
+// @Description if it belongs to the user from path
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/subscriptions/{id} [get]
+// @Param uid path string true "User ID"
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} models.Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getUserSubscription(w http.ResponseWriter, r *http.Request) {
+	s.getSubscription(w, r)
+}
+
+// @Summary Getting user's spend
+// @Description Recieving summary price of user's subscriptions with
+// @Description provided filters and period values (start, end),
+// @Description if start or end undefined, returns sum for all the time.
+// @Description Price is charged on billing dates of subscription with
+// @Description the price effective in the month of the charge. With currency
+// @Description param every charge is converted at the rate of its month.
+// @Description Period ends are months (MM-YYYY) or days (YYYY-MM-DD), without
+// @Description period spend is counted up to the end of user's current month
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/spend [get]
+// @Param uid path string true "User ID"
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param start query string false "Start period" Example(01-2015)
+// @Param end query string false "End period, inclusive" Example(2016-03-17)
+// @Param monthly_equivalent query bool false "Charge every active month with monthly-equivalent price"
+// @Param prorate query bool false "Count only days of billing cycles within the period"
+// @Param currency query string false "ISO 4217 code of currency to convert to" Example(USD)
+// @Param per_currency query bool false "Also return spend per original currency"
+// @Produce json
+// @Success 200 {object} sumResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getUserSpend(w http.ResponseWriter, r *http.Request) {
+	s.getPriceSum(w, r)
+}
+
+// @Summary Getting user's settings
+// @Description Returns settings of the user, defaults are
+// @Description returned if they were never saved
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/settings [get]
+// @Param uid path string true "User ID"
+// @Produce json
+// @Success 200 {object} models.UserSettings
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) getUserSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	uid, _ := pathUserID(r)
+	result, err := s.usersRepo.GetUserSettings(r.Context(), uid)
+	if err != nil {
+		slog.Error("error getting user settings",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully provided user settings",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Saving user's settings
+// @Description Replaces settings of the user. Time zone is used
+// @Description to determine user's current month in spend calculations,
+// @Description reminders are mailed to email if it is set
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/settings [put]
+// @Param uid path string true "User ID"
+// @Accept json
+// @Produce json
+// @Param request body models.UserSettings true "New settings, uid is taken from path"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) setUserSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	var settings models.UserSettings
+	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&settings)
+	if err == nil {
+		err = settings.Validate()
+	}
+	if err != nil {
+		slog.Error("invalid user settings request",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	settings.UID, _ = pathUserID(r)
+	if err = s.usersRepo.SetUserSettings(r.Context(), &settings); err != nil {
+		slog.Error("error saving user settings",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("user settings saved",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+	writeResponseMessage(w, http.StatusOK, "settings saved")
+}
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
 	"testcase/models"
-	"time"
 
 	"github.com/bytedance/sonic"
 )
@@ -30,7 +34,9 @@ func getFilterFromQuery(r *http.Request) map[string]interface{} {
 	if name := r.URL.Query().Get("name"); name != "" {
 		filter["name"] = name
 	}
-	if uid := r.URL.Query().Get("uid"); uid != "" {
+	if uid, ok := pathUserID(r); ok {
+		filter["uid"] = uid
+	} else if uid := r.URL.Query().Get("uid"); uid != "" {
 		filter["uid"] = uid
 	}
 	if len(filter) == 0 {
@@ -39,25 +45,90 @@ func getFilterFromQuery(r *http.Request) map[string]interface{} {
 	return filter
 }
 
+// Parses limit, offset, order, status and filter query params. Returns
+// ErrForbidden if non-admin principal asks for deleted rows
+func getListOptsFromQuery(r *http.Request) (*models.ListOpts, error) {
+	opts := &models.ListOpts{
+		Filter: getFilterFromQuery(r),
+		Order:  r.URL.Query().Get("order"),
+	}
+	var err error
+	if opts.Order != "" {
+		if _, _, err = models.ParseOrder(opts.Order); err != nil {
+			return nil, err
+		}
+	}
+	if includeStr := r.URL.Query().Get("include_deleted"); includeStr != "" {
+		if opts.IncludeDeleted, err = strconv.ParseBool(includeStr); err != nil {
+			return nil, err
+		}
+		if p, ok := auth.FromContext(r.Context()); opts.IncludeDeleted && (!ok || !p.Admin) {
+			return nil, errvalues.ErrForbidden
+		}
+	}
+	if statusStr := r.URL.Query().Get("status"); statusStr != "" {
+		for _, status := range strings.Split(statusStr, ",") {
+			if !models.ValidStatus(status) {
+				return nil, errors.New("invalid status: " + status)
+			}
+			opts.Status = append(opts.Status, status)
+		}
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if opts.Limit, err = strconv.Atoi(limitStr); err != nil {
+			return nil, err
+		}
+	}
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if opts.Offset, err = strconv.Atoi(offsetStr); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
 func getPeriodFromQuery(r *http.Request) (*models.RangeOpts, error) {
 	var period models.RangeOpts
 	var start, end string
 	if start = r.URL.Query().Get("start"); start != "" {
-		parsed, err := time.Parse("01-2006", start)
+		parsed, err := models.ParseDate(start)
 		if err != nil {
 			return nil, err
 		}
 		period.Start = parsed
 	}
 	if end = r.URL.Query().Get("end"); end != "" {
-		parsed, err := time.Parse("01-2006", end)
+		parsed, err := models.ParseDate(end)
 		if err != nil {
 			return nil, err
 		}
-		period.End = parsed
+		period.End, period.DayPrecision = parsed, models.IsFullDate(end)
 	}
 	if start == "" || end == "" {
 		return nil, nil
 	}
 	return &period, nil
 }
+
+func getSumOptsFromQuery(r *http.Request) (*models.SumOpts, error) {
+	opts := &models.SumOpts{}
+	if equivalent := r.URL.Query().Get("monthly_equivalent"); equivalent != "" {
+		var err error
+		if opts.MonthlyEquivalent, err = strconv.ParseBool(equivalent); err != nil {
+			return nil, err
+		}
+	}
+	if prorate := r.URL.Query().Get("prorate"); prorate != "" {
+		var err error
+		if opts.Prorate, err = strconv.ParseBool(prorate); err != nil {
+			return nil, err
+		}
+	}
+	if currency := r.URL.Query().Get("currency"); currency != "" {
+		opts.Currency = strings.ToUpper(currency)
+		if !models.ValidCurrency(opts.Currency) {
+			return nil, errvalues.ErrInvalidRequest
+		}
+	}
+	return opts, nil
+}
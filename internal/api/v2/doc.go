@@ -0,0 +1,16 @@
+// @title Subs-API
+// @version 2.0
+// @description API-service for managing users' subscriptions
+// @description Dates are returned as YYYY-MM-DD, date_format=month query param returns them as MM-YYYY.
+// @description Amounts are returned as decimal strings, numbers are accepted on input as well
+// @schemes http
+// @BasePath /v2
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description API key or JWT in "Bearer <token>" format
+
+// Package v2 defines routes of API v2 and DTOs of its docs. Handlers
+// are the ones of v1 writing full dates and amounts as decimal strings,
+// deprecated routes of v1 aren't served
+package v2
@@ -0,0 +1,130 @@
+package v2
+
+import (
+	"testcase/internal/importer"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DTOs of models having amounts or dates, v2 writes amounts as
+// decimal strings and dates as YYYY-MM-DD. Other models are
+// written as in v1
+
+type Subscription struct {
+	ID    int       `json:"id,omitempty" example:"1"`
+	Name  string    `json:"name" example:"yandex"`
+	Price string    `json:"price" example:"399.99"`
+	UID   uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	// Dates are accepted as MM-YYYY (first day of the month) or YYYY-MM-DD
+	Start   string `json:"start_date" example:"2025-01-01"`
+	Expires string `json:"expires,omitempty" example:"2026-02-17"`
+	// How often Price is charged, monthly by default
+	BillingPeriod string `json:"billing_period" enums:"weekly,monthly,quarterly,yearly,one-time" example:"monthly"`
+	// ISO 4217 code of Price currency, BaseCurrency by default
+	Currency string `json:"currency" example:"RUB"`
+	// Charges before the date are made with TrialPrice, free if it is empty
+	TrialEnd   string `json:"trial_end,omitempty" example:"2025-02-01"`
+	TrialPrice string `json:"trial_price,omitempty" example:"99.00"`
+	// Computed on reads for the current day of the user, ignored on writes
+	Status string `json:"status,omitempty" enums:"upcoming,active,trial,paused,cancelled,expired" example:"active"`
+	// Computed on reads, see Schedule
+	AutoRenew        *bool  `json:"auto_renew,omitempty" example:"true"`
+	NextChargeDate   string `json:"next_charge_date,omitempty" example:"2025-09-17"`
+	RemainingCharges *int   `json:"remaining_charges,omitempty" example:"3"`
+}
+
+type PriceChange struct {
+	Price         string `json:"price" example:"499.99"`
+	EffectiveFrom string `json:"effective_from" example:"2025-09-01"`
+}
+
+type Promo struct {
+	ID     int    `json:"id,omitempty" example:"1"`
+	Kind   string `json:"kind" enums:"percent,fixed" example:"percent"`
+	Value  string `json:"value" example:"50.00"`
+	Start  string `json:"start" example:"2025-09-01"`
+	Months int    `json:"months" example:"3"`
+}
+
+type Pause struct {
+	Start string `json:"start" example:"2025-09-17"`
+	End   string `json:"end,omitempty" example:"2025-12-01"`
+}
+
+type Charge struct {
+	SubID    int       `json:"sub_id" example:"1"`
+	UID      uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Name     string    `json:"name" example:"Yandex Plus"`
+	Date     string    `json:"date" example:"2025-09-17"`
+	Amount   string    `json:"amount" example:"399.99"`
+	Currency string    `json:"currency" example:"RUB"`
+}
+
+type AuditRecord struct {
+	ID        int64     `json:"id" example:"1"`
+	SubID     int       `json:"sub_id" example:"1"`
+	UID       uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Actor     string    `json:"actor" example:"apikey:1"`
+	RequestID *string   `json:"request_id,omitempty"`
+	Op        string    `json:"op" enums:"create,update,delete,restore,pause,resume,cancel" example:"update"`
+	// Snapshots of the subscription before and after the change
+	Before    *Subscription `json:"before,omitempty"`
+	After     *Subscription `json:"after,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type BatchOp struct {
+	Op  string        `json:"op" enums:"create,update,delete" example:"create"`
+	ID  int           `json:"id,omitempty" example:"1"`
+	Sub *Subscription `json:"subscription,omitempty"`
+}
+
+type BatchRequest struct {
+	// If true, failed operations don't roll back the others
+	Partial    bool       `json:"partial" example:"false"`
+	Operations []*BatchOp `json:"operations"`
+}
+
+type BatchResult struct {
+	Index  int    `json:"index" example:"0"`
+	Op     string `json:"op" example:"create"`
+	ID     int    `json:"id,omitempty" example:"1"`
+	Status string `json:"status" enums:"ok,error,rolled_back" example:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Error   string         `json:"error,omitempty"`
+	Results []*BatchResult `json:"results"`
+}
+
+type SumResponse struct {
+	Sum string `json:"sum" example:"1000.00"`
+	// Currency sum is converted to, prices are summed as is if empty
+	Currency string `json:"currency,omitempty" example:"RUB"`
+	// Spend per original currency, returned with per_currency=true
+	Totals map[string]string `json:"totals,omitempty"`
+}
+
+type ImportResponse struct {
+	DryRun bool `json:"dry_run" example:"false"`
+	// Number of inserted rows, or valid ones in dry-run mode
+	Imported      int64               `json:"imported" example:"28"`
+	RejectedCount int                 `json:"rejected_count" example:"2"`
+	Rejected      []importer.Rejected `json:"rejected"`
+}
+
+type APIKeyRequest struct {
+	Name  string    `json:"name" example:"billing-service"`
+	UID   uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Admin bool      `json:"admin" example:"false"`
+}
+
+type WebhookRequest struct {
+	URL string `json:"url" example:"https://billing.example.com/hooks/subs"`
+	// Operations changes are posted on, all if empty
+	Events []string `json:"events" example:"create,cancel"`
+	// Changes of the user's subscriptions only, all if empty
+	UID *uuid.UUID `json:"uid,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+}
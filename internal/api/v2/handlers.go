@@ -0,0 +1,709 @@
+package v2
+
+import "net/http"
+
+// @Summary Batch of subscription changes
+// @Description Executes list of create, update and delete operations in
+// @Description a single transaction. By default the batch is all-or-nothing,
+// @Description failure of any operation rolls back the whole batch and 422
+// @Description is returned. In partial mode failed operations are reported
+// @Description in per-item results and don't affect the others.
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/batch [post]
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
+// @Param request body BatchRequest true "Batch operations"
+// @Success 200 {object} BatchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} BatchResponse
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) batchSubscriptions(w http.ResponseWriter, r *http.Request) {
+	h.BatchSubscriptions(w, r)
+}
+
+// @Summary Importing subscriptions
+// @Description Imports subscriptions from CSV with header row or from JSON Lines
+// @Description in subscription format. Format is taken from format param or
+// @Description Content-Type (text/csv, application/x-ndjson). Invalid rows are
+// @Description skipped and reported with line numbers, valid ones are inserted
+// @Description at once. In dry-run mode nothing is inserted. Rows without uid
+// @Description belong to the requesting user.
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/import [post]
+// @Accept plain
+// @Produce json
+// @Param format query string false "Input format" Enums(csv, jsonl)
+// @Param columns query string false "CSV column mapping overriding configured one" Example(name:service,price:cost)
+// @Param dry_run query bool false "Only validate input"
+// @Param request body string true "CSV or JSON Lines data"
+// @Success 200 {object} ImportResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) importSubscriptions(w http.ResponseWriter, r *http.Request) {
+	h.ImportSubscriptions(w, r)
+}
+
+// @Summary Updating subscription
+// @Description Recieves new subscription info for update
+// @Description by provided id in path
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id} [put]
+// @Accept json
+// @Produce json
+// @Param request body Subscription true "New subscription data"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) updateSubscription(w http.ResponseWriter, r *http.Request) {
+	h.UpdateSubscription(w, r)
+}
+
+// @Summary Deleting subcription
+// @Description Deletes subscription with given id, it can be
+// @Description restored until retention period is over
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id} [delete]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	h.DeleteSubscription(w, r)
+}
+
+// @Summary Restoring subscription
+// @Description Restores deleted subscription with given id
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/restore [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) restoreSubscription(w http.ResponseWriter, r *http.Request) {
+	h.RestoreSubscription(w, r)
+}
+
+// @Summary Getting subscription history
+// @Description Returns audit records of the subscription, newest first
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/history [get]
+// @Param id path int true "Subscription ID"
+// @Param actor query string false "Principal made the change" Example(apikey:1)
+// @Param op query string false "Operation" Enums(create, update, delete, restore, pause, resume, cancel)
+// @Param from query string false "Start of time range, RFC 3339" Example(2025-07-01T00:00:00Z)
+// @Param to query string false "End of time range (exclusive), RFC 3339" Example(2025-08-01T00:00:00Z)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Produce json
+// @Success 200 {array} AuditRecord
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getSubscriptionHistory(w http.ResponseWriter, r *http.Request) {
+	h.GetSubscriptionHistory(w, r)
+}
+
+// @Summary Scheduling price change
+// @Description Sets price of the subscription effective from the month,
+// @Description spend of earlier months isn't affected. Price set for
+// @Description the same month before is replaced
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/prices [post]
+// @Param id path int true "Subscription ID"
+// @Accept json
+// @Produce json
+// @Param request body PriceChange true "New price and its effective month"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) schedulePrice(w http.ResponseWriter, r *http.Request) {
+	h.SchedulePrice(w, r)
+}
+
+// @Summary Getting price history
+// @Description Returns prices of the subscription ordered by
+// @Description effective month, including scheduled ones
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/prices [get]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {array} PriceChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listPrices(w http.ResponseWriter, r *http.Request) {
+	h.ListPrices(w, r)
+}
+
+// @Summary Adding promo discount
+// @Description Adds discount of subscription price for number of months,
+// @Description percent off the price or fixed amount off it. Of
+// @Description overlapping promos the last added one is applied
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/promos [post]
+// @Param id path int true "Subscription ID"
+// @Accept json
+// @Produce json
+// @Param request body Promo true "Promo discount"
+// @Success 200 {object} Promo
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) addPromo(w http.ResponseWriter, r *http.Request) {
+	h.AddPromo(w, r)
+}
+
+// @Summary Listing promo discounts
+// @Description Returns promo discounts of the subscription ordered by start month
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/promos [get]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {array} Promo
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listPromos(w http.ResponseWriter, r *http.Request) {
+	h.ListPromos(w, r)
+}
+
+// @Summary Pausing subscription
+// @Description Pauses the subscription from the current day in time zone
+// @Description of its user, charges falling on paused days are skipped
+// @Description until it is resumed
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/pause [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} Pause
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) pauseSubscription(w http.ResponseWriter, r *http.Request) {
+	h.PauseSubscription(w, r)
+}
+
+// @Summary Resuming subscription
+// @Description Resumes paused subscription from the current day in time
+// @Description zone of its user and returns the closed pause
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/resume [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} Pause
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) resumeSubscription(w http.ResponseWriter, r *http.Request) {
+	h.ResumeSubscription(w, r)
+}
+
+// @Summary Listing pauses
+// @Description Returns pause intervals of the subscription ordered by start
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/pauses [get]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {array} Pause
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listPauses(w http.ResponseWriter, r *http.Request) {
+	h.ListPauses(w, r)
+}
+
+// @Summary Cancelling subscription
+// @Description Cancels the subscription at the end of its billing period
+// @Description containing the current day in time zone of its user, so
+// @Description the paid period isn't cut. Open pause is closed
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/{id}/cancel [post]
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) cancelSubscription(w http.ResponseWriter, r *http.Request) {
+	h.CancelSubscription(w, r)
+}
+
+// @Summary Exporting subscriptions
+// @Description Streams subscriptions matching filters as CSV or JSON Lines
+// @Description chosen by Accept header (JSON Lines by default). Rows are
+// @Description sent as they are read, so export size isn't limited
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/export [get]
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Param order query string false "Field to sort by: id, name, uid, price, created_at or expires, optionally followed by asc or desc" Example(price desc)
+// @Param include_deleted query bool false "Include deleted subscriptions, admins only"
+// @Param status query string false "Comma separated statuses" Example(active,trial)
+// @Produce application/x-ndjson,text/csv
+// @Success 200 {array} Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) exportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	h.ExportSubscriptions(w, r)
+}
+
+// @Summary Getting upcoming charges
+// @Description Returns charges of subscriptions due in provided number
+// @Description of days starting from the current day of their users,
+// @Description ordered by date. Trial, promo and pauses are respected,
+// @Description amounts are in currency of subscription
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/upcoming [get]
+// @Param days query int false "Number of days, 30 by default" minimum(1) maximum(366)
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Produce json
+// @Success 200 {array} Charge
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getUpcomingCharges(w http.ResponseWriter, r *http.Request) {
+	h.GetUpcomingCharges(w, r)
+}
+
+// @Summary Streaming subscription changes
+// @Description Pushes changes of subscriptions as Server-Sent Events once
+// @Description they are committed. Event id is id of audit record, event
+// @Description type is its op and data is the record. Comment lines are
+// @Description sent as heartbeat. Stream is resumed after event from
+// @Description Last-Event-ID header or last_event_id param, otherwise it
+// @Description starts with the next change. Users get changes of their
+// @Description own subscriptions only, admins may filter them by uid.
+// @Description Streams are closed on server shutdown, so clients reconnect
+// @Tags subs
+// @Security BearerAuth
+// @Router /subs/stream [get]
+// @Param Last-Event-ID header int false "Id of the last received event"
+// @Param last_event_id query int false "Id of the last received event, if header can't be set"
+// @Param uid query string false "User ID" Example(60601fee-2bf1-4721-ae6f-7636e79a0cba)
+// @Produce text/event-stream
+// @Success 200 {object} AuditRecord
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) streamChanges(w http.ResponseWriter, r *http.Request) {
+	h.StreamChanges(w, r)
+}
+
+// @Summary Listing user's subscriptions
+// @Description Returns list of user's subscriptions with given
+// @Description unnecessary filters. With Accept: text/csv or
+// @Description application/x-ndjson rows are streamed in that format
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/subscriptions [get]
+// @Param uid path string true "User ID"
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Param order query string false "Field to sort by: id, name, uid, price, created_at or expires, optionally followed by asc or desc" Example(price desc)
+// @Param include_deleted query bool false "Include deleted subscriptions, admins only"
+// @Param status query string false "Comma separated statuses" Example(active,trial)
+// @Produce json,text/csv,application/x-ndjson
+// @Success 200 {array} Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	h.ListUserSubscriptions(w, r)
+}
+
+// @Summary Registering user's subscription
+// @Description Recieves new subscription info and saves it
+// @Description in DB for the user from path
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/subscriptions [post]
+// @Param uid path string true "User ID"
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
+// @Param request body Subscription true "New subscription data"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) addUserSubscription(w http.ResponseWriter, r *http.Request) {
+	h.AddUserSubscription(w, r)
+}
+
+// @Summary Getting user's subscription info
+// @Description Provides subscription data by ID in path,
+// @Description if it belongs to the user from path
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/subscriptions/{id} [get]
+// @Param uid path string true "User ID"
+// @Param id path int true "Subscription ID"
+// @Produce json
+// @Success 200 {object} Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getUserSubscription(w http.ResponseWriter, r *http.Request) {
+	h.GetUserSubscription(w, r)
+}
+
+// @Summary Getting user's spend
+// @Description Recieving summary price of user's subscriptions with
+// @Description provided filters and period values (start, end),
+// @Description if start or end undefined, returns sum for all the time.
+// @Description Price is charged on billing dates of subscription with
+// @Description the price effective in the month of the charge. With currency
+// @Description param every charge is converted at the rate of its month.
+// @Description Period ends are months (MM-YYYY) or days (YYYY-MM-DD), without
+// @Description period spend is counted up to the end of user's current month
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/spend [get]
+// @Param uid path string true "User ID"
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Param start query string false "Start period" Example(01-2015)
+// @Param end query string false "End period, inclusive" Example(2016-03-17)
+// @Param monthly_equivalent query bool false "Charge every active month with monthly-equivalent price"
+// @Param prorate query bool false "Count only days of billing cycles within the period"
+// @Param currency query string false "ISO 4217 code of currency to convert to" Example(USD)
+// @Param per_currency query bool false "Also return spend per original currency"
+// @Produce json
+// @Success 200 {object} SumResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getUserSpend(w http.ResponseWriter, r *http.Request) {
+	h.GetUserSpend(w, r)
+}
+
+// @Summary Getting user's upcoming charges
+// @Description Returns charges of user's subscriptions due in provided
+// @Description number of days starting from the user's current day,
+// @Description ordered by date. Trial, promo and pauses are respected,
+// @Description amounts are in currency of subscription
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/upcoming [get]
+// @Param uid path string true "User ID"
+// @Param days query int false "Number of days, 30 by default" minimum(1) maximum(366)
+// @Param name query string false "Sub's service name" Example(Spotify)
+// @Produce json
+// @Success 200 {array} Charge
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getUserUpcomingCharges(w http.ResponseWriter, r *http.Request) {
+	h.GetUserUpcomingCharges(w, r)
+}
+
+// @Summary Getting user's settings
+// @Description Returns settings of the user, defaults are
+// @Description returned if they were never saved
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/settings [get]
+// @Param uid path string true "User ID"
+// @Produce json
+// @Success 200 {object} models.UserSettings
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getUserSettings(w http.ResponseWriter, r *http.Request) {
+	h.GetUserSettings(w, r)
+}
+
+// @Summary Saving user's settings
+// @Description Replaces settings of the user. Time zone is used
+// @Description to determine user's current month in spend calculations,
+// @Description reminders are mailed to email if it is set
+// @Tags users
+// @Security BearerAuth
+// @Router /users/{uid}/settings [put]
+// @Param uid path string true "User ID"
+// @Accept json
+// @Produce json
+// @Param request body models.UserSettings true "New settings, uid is taken from path"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) setUserSettings(w http.ResponseWriter, r *http.Request) {
+	h.SetUserSettings(w, r)
+}
+
+// @Summary Getting audit log
+// @Description Returns audit records of subscription changes matching
+// @Description filters, newest first. Non-admin keys get records of
+// @Description their user's subscriptions only
+// @Tags audit
+// @Security BearerAuth
+// @Router /audit [get]
+// @Param actor query string false "Principal made the change" Example(apikey:1)
+// @Param op query string false "Operation" Enums(create, update, delete, restore, pause, resume, cancel)
+// @Param from query string false "Start of time range, RFC 3339" Example(2025-07-01T00:00:00Z)
+// @Param to query string false "End of time range (exclusive), RFC 3339" Example(2025-08-01T00:00:00Z)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Produce json
+// @Success 200 {array} AuditRecord
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	h.GetAuditLog(w, r)
+}
+
+// @Summary Creating API key
+// @Description Generates new API key for the user, plain key
+// @Description is returned only in this response
+// @Tags admin
+// @Router /admin/keys [post]
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body APIKeyRequest true "New key data"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.CreateAPIKey(w, r)
+}
+
+// @Summary Listing API keys
+// @Description Returns all API keys including revoked ones,
+// @Description plain keys are never returned
+// @Tags admin
+// @Router /admin/keys [get]
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	h.ListAPIKeys(w, r)
+}
+
+// @Summary Revoking API key
+// @Description Revokes API key with given id
+// @Tags admin
+// @Router /admin/keys/{id} [delete]
+// @Security BearerAuth
+// @Param id path int true "Key ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.RevokeAPIKey(w, r)
+}
+
+// @Summary Setting exchange rates
+// @Description Saves exchange rates to BaseCurrency (RUB) as JSON array or
+// @Description CSV with currency,effective_from,rate header, chosen by
+// @Description Content-Type. Rate set for the same currency and month is replaced
+// @Tags admin
+// @Router /admin/rates [put]
+// @Security BearerAuth
+// @Accept json,text/csv
+// @Produce json
+// @Param request body []models.ExchangeRate true "Exchange rates"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) setRates(w http.ResponseWriter, r *http.Request) {
+	h.SetRates(w, r)
+}
+
+// @Summary Listing exchange rates
+// @Description Returns exchange rates to BaseCurrency (RUB)
+// @Description ordered by currency and effective month
+// @Tags admin
+// @Router /admin/rates [get]
+// @Security BearerAuth
+// @Param currency query string false "ISO 4217 currency code" Example(USD)
+// @Produce json
+// @Success 200 {array} models.ExchangeRate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listRates(w http.ResponseWriter, r *http.Request) {
+	h.ListRates(w, r)
+}
+
+// @Summary Registering webhook endpoint
+// @Description Registers endpoint subscription changes are posted to
+// @Description as audit records. Requests are signed with HMAC-SHA256
+// @Description of the secret, which is returned only in this response:
+// @Description X-Webhook-Signature is "sha256=" and hex of HMAC of
+// @Description X-Webhook-Timestamp, "." and body
+// @Tags admin
+// @Router /admin/webhooks [post]
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body WebhookRequest true "New endpoint data"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) createWebhook(w http.ResponseWriter, r *http.Request) {
+	h.CreateWebhook(w, r)
+}
+
+// @Summary Listing webhook endpoints
+// @Description Returns all webhook endpoints, secrets are never returned
+// @Tags admin
+// @Router /admin/webhooks [get]
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.WebhookEndpoint
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	h.ListWebhooks(w, r)
+}
+
+// @Summary Deleting webhook endpoint
+// @Description Deletes webhook endpoint with given id and its delivery log
+// @Tags admin
+// @Router /admin/webhooks/{id} [delete]
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	h.DeleteWebhook(w, r)
+}
+
+// @Summary Getting webhook delivery log
+// @Description Returns deliveries of changes to webhook endpoint, newest
+// @Description first. Dead deliveries ran out of attempts and aren't retried
+// @Tags admin
+// @Router /admin/webhooks/{id}/deliveries [get]
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Produce json
+// @Success 200 {array} models.Delivery
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (h *Handlers) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	h.ListDeliveries(w, r)
+}
@@ -0,0 +1,125 @@
+package v2
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Middleware = func(http.Handler) http.Handler
+
+// Middlewares of the server routes are wrapped in
+type Middlewares struct {
+	// Limits requests by bucket of the route configured in rate_limits
+	RateLimit   func(route string) Middleware
+	Auth        Middleware
+	Idempotency Middleware
+	SubID       Middleware
+	UserID      Middleware
+	AdminOnly   Middleware
+}
+
+// Handlers of v2 endpoints, set by the server
+type Handlers struct {
+	Middlewares
+
+	BatchSubscriptions     http.HandlerFunc
+	ImportSubscriptions    http.HandlerFunc
+	UpdateSubscription     http.HandlerFunc
+	DeleteSubscription     http.HandlerFunc
+	RestoreSubscription    http.HandlerFunc
+	GetSubscriptionHistory http.HandlerFunc
+	SchedulePrice          http.HandlerFunc
+	ListPrices             http.HandlerFunc
+	AddPromo               http.HandlerFunc
+	ListPromos             http.HandlerFunc
+	PauseSubscription      http.HandlerFunc
+	ResumeSubscription     http.HandlerFunc
+	ListPauses             http.HandlerFunc
+	CancelSubscription     http.HandlerFunc
+	ExportSubscriptions    http.HandlerFunc
+	GetUpcomingCharges     http.HandlerFunc
+	StreamChanges          http.HandlerFunc
+
+	ListUserSubscriptions  http.HandlerFunc
+	AddUserSubscription    http.HandlerFunc
+	GetUserSubscription    http.HandlerFunc
+	GetUserSpend           http.HandlerFunc
+	GetUserUpcomingCharges http.HandlerFunc
+	GetUserSettings        http.HandlerFunc
+	SetUserSettings        http.HandlerFunc
+
+	GetAuditLog http.HandlerFunc
+
+	CreateAPIKey   http.HandlerFunc
+	ListAPIKeys    http.HandlerFunc
+	RevokeAPIKey   http.HandlerFunc
+	SetRates       http.HandlerFunc
+	ListRates      http.HandlerFunc
+	CreateWebhook  http.HandlerFunc
+	ListWebhooks   http.HandlerFunc
+	DeleteWebhook  http.HandlerFunc
+	ListDeliveries http.HandlerFunc
+}
+
+// Mounts v2 routes on r. Routes deprecated in v1 aren't
+// served, subscriptions are managed under /users/{uid}
+func Mount(r chi.Router, h *Handlers) {
+	r.Route("/subs", func(r chi.Router) {
+		r.Use(h.RateLimit("ip"), h.Auth, h.RateLimit("default"))
+		r.With(h.Idempotency).Post("/batch", h.batchSubscriptions)
+		r.Post("/import", h.importSubscriptions)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(h.SubID)
+			r.Put("/", h.updateSubscription)
+			r.Delete("/", h.deleteSubscription)
+			r.Post("/restore", h.restoreSubscription)
+			r.With(h.RateLimit("list")).Get("/history", h.getSubscriptionHistory)
+			r.Post("/prices", h.schedulePrice)
+			r.Get("/prices", h.listPrices)
+			r.Post("/promos", h.addPromo)
+			r.Get("/promos", h.listPromos)
+			r.Post("/pause", h.pauseSubscription)
+			r.Post("/resume", h.resumeSubscription)
+			r.Get("/pauses", h.listPauses)
+			r.Post("/cancel", h.cancelSubscription)
+		})
+		r.With(h.RateLimit("list")).Get("/export", h.exportSubscriptions)
+		r.With(h.RateLimit("list")).Get("/upcoming", h.getUpcomingCharges)
+		r.Get("/stream", h.streamChanges)
+	})
+	r.Route("/users/{uid}", func(r chi.Router) {
+		r.Use(h.RateLimit("ip"), h.Auth, h.RateLimit("default"), h.UserID)
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.With(h.RateLimit("list")).Get("/", h.listUserSubscriptions)
+			r.With(h.Idempotency).Post("/", h.addUserSubscription)
+			r.With(h.SubID).Get("/{id}", h.getUserSubscription)
+		})
+		r.With(h.RateLimit("sum")).Get("/spend", h.getUserSpend)
+		r.With(h.RateLimit("list")).Get("/upcoming", h.getUserUpcomingCharges)
+		r.Get("/settings", h.getUserSettings)
+		r.Put("/settings", h.setUserSettings)
+	})
+	r.Route("/audit", func(r chi.Router) {
+		r.Use(h.RateLimit("ip"), h.Auth, h.RateLimit("default"))
+		r.With(h.RateLimit("list")).Get("/", h.getAuditLog)
+	})
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(h.RateLimit("ip"), h.Auth, h.RateLimit("default"), h.AdminOnly)
+		r.Route("/keys", func(r chi.Router) {
+			r.Post("/", h.createAPIKey)
+			r.Get("/", h.listAPIKeys)
+			r.Delete("/{id}", h.revokeAPIKey)
+		})
+		r.Route("/rates", func(r chi.Router) {
+			r.Put("/", h.setRates)
+			r.Get("/", h.listRates)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", h.createWebhook)
+			r.Get("/", h.listWebhooks)
+			r.Delete("/{id}", h.deleteWebhook)
+			r.With(h.RateLimit("list")).Get("/{id}/deliveries", h.listDeliveries)
+		})
+	})
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testcase/internal/errvalues"
+	"testcase/internal/webhooks"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+)
+
+type webhookRequest struct {
+	URL string `json:"url" example:"https://billing.example.com/hooks/subs"`
+	// Operations changes are posted on, all if empty
+	Events []string `json:"events" example:"create,cancel"`
+	// Changes of the user's subscriptions only, all if empty
+	UID *uuid.UUID `json:"uid,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+}
+
+// @Summary Registering webhook endpoint
+// @Description Registers endpoint subscription changes are posted to
+// @Description as audit records. Requests are signed with HMAC-SHA256
+// @Description of the secret, which is returned only in this response:
+// @Description X-Webhook-Signature is "sha256=" and hex of HMAC of
+// @Description X-Webhook-Timestamp, "." and body
+// @Tags admin
+// @Router /admin/webhooks [post]
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body webhookRequest true "New endpoint data"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	var req webhookRequest
+	err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(&req)
+	endpoint := models.WebhookEndpoint{
+		URL:    req.URL,
+		Events: req.Events,
+		UID:    req.UID,
+	}
+	if err == nil {
+		err = endpoint.Validate()
+	}
+	if err != nil {
+		slog.Error("invalid create webhook request",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	if endpoint.Secret, err = webhooks.GenerateSecret(); err != nil {
+		slog.Error("error generating webhook secret",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = s.webhooksRepo.AddWebhook(r.Context(), &endpoint); err != nil {
+		slog.Error("error adding webhook",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(endpoint); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("webhook created",
+		slog.Int("webhook_id", endpoint.ID),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Listing webhook endpoints
+// @Description Returns all webhook endpoints, secrets are never returned
+// @Tags admin
+// @Router /admin/webhooks [get]
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.WebhookEndpoint
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	endpoints, err := s.webhooksRepo.ListWebhooks(r.Context())
+	if err != nil {
+		slog.Error("list webhooks error",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(endpoints); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully listed webhooks",
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
+
+// @Summary Deleting webhook endpoint
+// @Description Deletes webhook endpoint with given id and its delivery log
+// @Tags admin
+// @Router /admin/webhooks/{id} [delete]
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		slog.Error("incoming request with invalid id",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	if err = s.webhooksRepo.DeleteWebhook(r.Context(), id); err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("delete request with unexisted webhook id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("error deleting webhook",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	slog.Info("webhook deleted",
+		slog.Int("webhook_id", id),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+	writeResponseMessage(w, http.StatusOK, "webhook deleted")
+}
+
+// Parses status, limit and offset query params
+func getDeliveryOptsFromQuery(r *http.Request) (*models.DeliveryOpts, error) {
+	query := r.URL.Query()
+	opts := &models.DeliveryOpts{
+		Status: query.Get("status"),
+	}
+	switch opts.Status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, errors.New("invalid status: " + opts.Status)
+	}
+	var err error
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if opts.Limit, err = strconv.Atoi(limitStr); err != nil {
+			return nil, err
+		}
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if opts.Offset, err = strconv.Atoi(offsetStr); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// @Summary Getting webhook delivery log
+// @Description Returns deliveries of changes to webhook endpoint, newest
+// @Description first. Dead deliveries ran out of attempts and aren't retried
+// @Tags admin
+// @Router /admin/webhooks/{id}/deliveries [get]
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param limit query int false "Returned rows limit"
+// @Param offset query int false "Offset for paginations"
+// @Produce json
+// @Success 200 {array} models.Delivery
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+func (s *Server) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reqID := r.Context().Value("Request-ID").(string)
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var opts *models.DeliveryOpts
+	if err == nil {
+		opts, err = getDeliveryOptsFromQuery(r)
+	}
+	if err != nil {
+		slog.Error("incoming request with invalid id or query param",
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusBadRequest, errvalues.ErrInvalidRequest)
+		return
+	}
+	deliveries, err := s.webhooksRepo.ListDeliveries(r.Context(), id, opts)
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			slog.Error("deliveries request with unexisted webhook id",
+				slog.String("req_id", reqID),
+				slog.String("from", r.RemoteAddr))
+			writeErrorMessage(w, http.StatusNotFound, err)
+			return
+		}
+		slog.Error("list deliveries error",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		writeErrorMessage(w, http.StatusInternalServerError, errvalues.ErrInternal)
+		return
+	}
+	if err = sonic.ConfigDefault.NewEncoder(w).Encode(deliveries); err != nil {
+		slog.Error("error providing result",
+			slog.String("error", err.Error()),
+			slog.String("req_id", reqID),
+			slog.String("from", r.RemoteAddr))
+		return
+	}
+	slog.Info("successfully listed webhook deliveries",
+		slog.Int("deliveries", len(deliveries)),
+		slog.String("req_id", reqID),
+		slog.String("from", r.RemoteAddr))
+}
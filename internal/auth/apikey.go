@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const keyPrefix = "sk_"
+
+// Generates new random API key, returns key itself, which is shown
+// to the user once, and its hash for storing
+func GenerateKey() (key string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, HashKey(key), nil
+}
+
+// Keys have enough entropy, so plain SHA-256 is sufficient
+// and allows lookup by hash
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func looksLikeAPIKey(token string) bool {
+	return strings.HasPrefix(token, keyPrefix)
+}
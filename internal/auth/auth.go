@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testcase/internal/errvalues"
+	"testcase/models"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type KeyStore interface {
+	FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+// Authenticates requests by API key (Authorization: Bearer sk_...
+// or X-API-Key header) or by JWT bearer token
+type Authenticator struct {
+	keys KeyStore
+	jwt  *jwtVerifier
+}
+
+func New(keys KeyStore, cfg *JWTConfig) (*Authenticator, error) {
+	v, err := newJWTVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		keys: keys,
+		jwt:  v,
+	}, nil
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.Header.Get("X-API-Key")
+	if token == "" {
+		authHeader := r.Header.Get("Authorization")
+		scheme, value, ok := strings.Cut(authHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, ErrNoCredentials
+		}
+		token = strings.TrimSpace(value)
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	if looksLikeAPIKey(token) {
+		return a.authenticateKey(r.Context(), token)
+	}
+	p, err := a.jwt.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+	return p, nil
+}
+
+func (a *Authenticator) authenticateKey(ctx context.Context, token string) (*Principal, error) {
+	key, err := a.keys.FindAPIKey(ctx, HashKey(token))
+	if err != nil {
+		if errors.Is(err, errvalues.ErrNoSuchRow) {
+			return nil, fmt.Errorf("%w: unknown or revoked api key", ErrInvalidCredentials)
+		}
+		return nil, err
+	}
+	return &Principal{
+		Subject: "apikey:" + strconv.Itoa(key.ID),
+		UID:     key.UID,
+		Admin:   key.Admin,
+		KeyID:   key.ID,
+	}, nil
+}
@@ -0,0 +1,177 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testcase/internal/auth"
+	"testcase/internal/errvalues"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type keyStoreMock map[string]*models.APIKey
+
+func (m keyStoreMock) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	if key, ok := m[hash]; ok {
+		return key, nil
+	}
+	return nil, errvalues.ErrNoSuchRow
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func hsToken(t *testing.T, secret string, claims map[string]any) string {
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func authenticate(a *auth.Authenticator, header, value string) (*auth.Principal, error) {
+	r := httptest.NewRequest("GET", "/subs/list", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return a.Authenticate(r)
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	t.Parallel()
+	key, hash, err := auth.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := uuid.New()
+	a, err := auth.New(keyStoreMock{
+		hash: {ID: 7, UID: uid, Admin: true},
+	}, &auth.JWTConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("bearer header", func(t *testing.T) {
+		p, err := authenticate(a, "Authorization", "Bearer "+key)
+		assert.NoError(t, err)
+		assert.Equal(t, &auth.Principal{Subject: "apikey:7", UID: uid, Admin: true, KeyID: 7}, p)
+	})
+	t.Run("x-api-key header", func(t *testing.T) {
+		p, err := authenticate(a, "X-API-Key", key)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, p.KeyID)
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := authenticate(a, "X-API-Key", "sk_unknown")
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+	t.Run("no credentials", func(t *testing.T) {
+		_, err := authenticate(a, "", "")
+		assert.ErrorIs(t, err, auth.ErrNoCredentials)
+	})
+}
+
+func TestAuthenticateHMACToken(t *testing.T) {
+	t.Parallel()
+	a, err := auth.New(keyStoreMock{}, &auth.JWTConfig{
+		HMACSecret: "secret",
+		Audience:   "subs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := uuid.New()
+	exp := time.Now().Add(time.Hour).Unix()
+	t.Run("successful", func(t *testing.T) {
+		token := hsToken(t, "secret", map[string]any{"sub": uid.String(), "exp": exp, "aud": []string{"subs"}})
+		p, err := authenticate(a, "Authorization", "Bearer "+token)
+		assert.NoError(t, err)
+		assert.Equal(t, &auth.Principal{Subject: uid.String(), UID: uid}, p)
+	})
+	t.Run("admin role", func(t *testing.T) {
+		token := hsToken(t, "secret", map[string]any{"sub": uid.String(), "exp": exp, "aud": "subs", "role": "admin"})
+		p, err := authenticate(a, "Authorization", "Bearer "+token)
+		assert.NoError(t, err)
+		assert.True(t, p.Admin)
+	})
+	t.Run("wrong secret", func(t *testing.T) {
+		token := hsToken(t, "other", map[string]any{"sub": uid.String(), "exp": exp, "aud": "subs"})
+		_, err := authenticate(a, "Authorization", "Bearer "+token)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+	t.Run("expired", func(t *testing.T) {
+		token := hsToken(t, "secret", map[string]any{"sub": uid.String(), "exp": time.Now().Add(-time.Hour).Unix(), "aud": "subs"})
+		_, err := authenticate(a, "Authorization", "Bearer "+token)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+	t.Run("wrong audience", func(t *testing.T) {
+		token := hsToken(t, "secret", map[string]any{"sub": uid.String(), "exp": exp, "aud": "other"})
+		_, err := authenticate(a, "Authorization", "Bearer "+token)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
+
+func TestAuthenticateJWKSToken(t *testing.T) {
+	t.Parallel()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "main",
+		"n":   base64.RawURLEncoding.EncodeToString(priv.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.E)).Bytes()),
+	}}}
+	data, _ := sonic.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := auth.New(keyStoreMock{}, &auth.JWTConfig{JWKSFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := uuid.New()
+	sign := func(kid string) string {
+		signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." +
+			encodeSegment(t, map[string]any{"sub": uid.String(), "exp": time.Now().Add(time.Hour).Unix()})
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	t.Run("successful", func(t *testing.T) {
+		p, err := authenticate(a, "Authorization", "Bearer "+sign("main"))
+		assert.NoError(t, err)
+		assert.Equal(t, uid, p.UID)
+	})
+	t.Run("unknown kid", func(t *testing.T) {
+		_, err := authenticate(a, "Authorization", "Bearer "+sign("other"))
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+	t.Run("hmac token without secret", func(t *testing.T) {
+		token := hsToken(t, "", map[string]any{"sub": uid.String(), "exp": time.Now().Add(time.Hour).Unix()})
+		_, err := authenticate(a, "Authorization", "Bearer "+token)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+)
+
+// Allowed clock skew for exp and nbf claims
+const leeway = time.Minute
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errUnsupportedAlg = errors.New("unsupported signing algorithm")
+	errUnknownKey     = errors.New("no key to verify token")
+	errBadSignature   = errors.New("invalid token signature")
+)
+
+type JWTConfig struct {
+	// Secret for HS256/HS384/HS512 tokens
+	HMACSecret string
+	// Path to local JWKS file with RSA and EC public keys
+	// for RS* and ES* tokens
+	JWKSFile string
+	// If set, tokens must contain matching iss and aud claims
+	Issuer   string
+	Audience string
+}
+
+type jwtVerifier struct {
+	hmacSecret []byte
+	keys       map[string]crypto.PublicKey
+	issuer     string
+	audience   string
+	now        func() time.Time
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Sub  string   `json:"sub"`
+	Iss  string   `json:"iss"`
+	Aud  any      `json:"aud"`
+	Exp  *float64 `json:"exp"`
+	Nbf  *float64 `json:"nbf"`
+	Role string   `json:"role"`
+}
+
+func newJWTVerifier(cfg *JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		hmacSecret: []byte(cfg.HMACSecret),
+		keys:       make(map[string]crypto.PublicKey),
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		now:        time.Now,
+	}
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, errors.New("reading jwks file error: " + err.Error())
+		}
+		if v.keys, err = parseJWKS(data); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Verifies token signature and registered claims and returns
+// principal built from sub and role claims. sub must be user's UUID,
+// role "admin" grants admin rights
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errMalformedToken
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errMalformedToken
+	}
+	if err = v.verifySignature(header, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+	var claims jwtClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := v.now()
+	if claims.Exp == nil || now.After(unixTime(*claims.Exp).Add(leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.Nbf != nil && now.Add(leeway).Before(unixTime(*claims.Nbf)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if v.issuer != "" && claims.Iss != v.issuer {
+		return nil, errors.New("unexpected token issuer")
+	}
+	if v.audience != "" && !audienceContains(claims.Aud, v.audience) {
+		return nil, errors.New("unexpected token audience")
+	}
+	uid, err := uuid.Parse(claims.Sub)
+	if err != nil {
+		return nil, errors.New("token subject is not a valid uuid")
+	}
+	return &Principal{
+		Subject: claims.Sub,
+		UID:     uid,
+		Admin:   claims.Role == "admin",
+	}, nil
+}
+
+func (v *jwtVerifier) verifySignature(header jwtHeader, signed string, sig []byte) error {
+	if len(header.Alg) != 5 {
+		return errUnsupportedAlg
+	}
+	var newHash func() hash.Hash
+	var cryptoHash crypto.Hash
+	switch header.Alg[2:] {
+	case "256":
+		newHash, cryptoHash = sha256.New, crypto.SHA256
+	case "384":
+		newHash, cryptoHash = sha512.New384, crypto.SHA384
+	case "512":
+		newHash, cryptoHash = sha512.New, crypto.SHA512
+	default:
+		return errUnsupportedAlg
+	}
+
+	if header.Alg[:2] == "HS" {
+		if len(v.hmacSecret) == 0 {
+			return errUnknownKey
+		}
+		mac := hmac.New(newHash, v.hmacSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errBadSignature
+		}
+		return nil
+	}
+
+	key, err := v.keyFor(header.Kid)
+	if err != nil {
+		return err
+	}
+	h := newHash()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch header.Alg[:2] {
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errUnknownKey
+		}
+		if rsa.VerifyPKCS1v15(pub, cryptoHash, digest, sig) != nil {
+			return errBadSignature
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errUnknownKey
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errBadSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errBadSignature
+		}
+	default:
+		return errUnsupportedAlg
+	}
+	return nil
+}
+
+// Tokens without kid are accepted only when JWKS contains single key
+func (v *jwtVerifier) keyFor(kid string) (crypto.PublicKey, error) {
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, errUnknownKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := sonic.Unmarshal(data, &set); err != nil {
+		return nil, errors.New("parsing jwks error: " + err.Error())
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				return nil, errors.New("invalid RSA key " + k.Kid + " in jwks")
+			}
+			key = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, errors.New("unsupported curve " + k.Crv + " in jwks")
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				return nil, errors.New("invalid EC key " + k.Kid + " in jwks")
+			}
+			key = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		default:
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func decodeSegment(seg string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errMalformedToken
+	}
+	if err = sonic.Unmarshal(data, dst); err != nil {
+		return errMalformedToken
+	}
+	return nil
+}
+
+func audienceContains(aud any, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unixTime(sec float64) time.Time {
+	return time.Unix(int64(sec), 0)
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Authenticated caller of the API
+type Principal struct {
+	// Unique caller identifier, "apikey:<id>" for API keys
+	// or JWT subject
+	Subject string
+	// User on whose behalf requests are made
+	UID   uuid.UUID
+	Admin bool
+	// ID of the API key used, 0 for JWT
+	KeyID int
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// Returns principal stored in ctx, ok is false if request
+// wasn't authenticated
+func FromContext(ctx context.Context) (p *Principal, ok bool) {
+	p, ok = ctx.Value(ctxKey{}).(*Principal)
+	return p, ok && p != nil
+}
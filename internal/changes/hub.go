@@ -0,0 +1,85 @@
+// Package changes wakes up readers of the change log of this instance
+// once changes are committed by any instance
+package changes
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delay before listening again after connection failed
+const retryDelay = 5 * time.Second
+
+type Listener interface {
+	ListenChanges(ctx context.Context, notify func(uid uuid.UUID)) error
+}
+
+type Hub struct {
+	listener Listener
+	mu       sync.Mutex
+	subs     map[chan struct{}]uuid.UUID
+}
+
+func NewHub(listener Listener) *Hub {
+	return &Hub{
+		listener: listener,
+		subs:     make(map[chan struct{}]uuid.UUID),
+	}
+}
+
+// Returns channel receiving a value after changes of user uid, or of
+// all users if uid is uuid.Nil. Wakeups are coalesced, so receiver must
+// read all changes following the last one it has read. Returned func
+// unsubscribes and must be called once receiving is done
+func (h *Hub) Subscribe(uid uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.subs[ch] = uid
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Wakes up subscribers of user uid, every subscriber if uid is uuid.Nil
+func (h *Hub) Publish(uid uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch, subUID := range h.subs {
+		if uid != uuid.Nil && subUID != uuid.Nil && subUID != uid {
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Starts listening for changes, which runs until ctx is done.
+// Failed listening is started again after retryDelay
+func (h *Hub) Run(ctx context.Context) {
+	go func() {
+		for {
+			err := h.listener.ListenChanges(ctx, h.Publish)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Error("listening for changes failed",
+					slog.String("error", err.Error()))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+	}()
+}
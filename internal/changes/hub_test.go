@@ -0,0 +1,82 @@
+package changes_test
+
+import (
+	"context"
+	"sync"
+	"testcase/internal/changes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func woken(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	t.Parallel()
+	hub := changes.NewHub(nil)
+	alice, bob := uuid.New(), uuid.New()
+	aliceCh, unsubscribeAlice := hub.Subscribe(alice)
+	allCh, unsubscribeAll := hub.Subscribe(uuid.Nil)
+	defer unsubscribeAll()
+
+	hub.Publish(bob)
+	assert.False(t, woken(aliceCh))
+	assert.True(t, woken(allCh))
+
+	// Wakeups are coalesced
+	hub.Publish(alice)
+	hub.Publish(alice)
+	assert.True(t, woken(aliceCh))
+	assert.False(t, woken(aliceCh))
+	assert.True(t, woken(allCh))
+
+	hub.Publish(uuid.Nil)
+	assert.True(t, woken(aliceCh))
+	assert.True(t, woken(allCh))
+
+	unsubscribeAlice()
+	hub.Publish(alice)
+	assert.False(t, woken(aliceCh))
+}
+
+type fakeListener struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeListener) ListenChanges(ctx context.Context, notify func(uid uuid.UUID)) error {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	notify(uuid.Nil)
+	<-ctx.Done()
+	return nil
+}
+
+func TestHubRun(t *testing.T) {
+	t.Parallel()
+	listener := &fakeListener{}
+	hub := changes.NewHub(listener)
+	ch, unsubscribe := hub.Subscribe(uuid.New())
+	defer unsubscribe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub.Run(ctx)
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber wasn't woken once listening started")
+	}
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	assert.Equal(t, 1, listener.calls)
+}
@@ -6,4 +6,20 @@ var (
 	ErrNoSuchRow      = errors.New("lack of row with such id")
 	ErrInvalidRequest = errors.New("request with invalid body or path/query params")
 	ErrInternal       = errors.New("internal error")
+	ErrNotFound       = errors.New("route not found")
+	ErrCORSRejected   = errors.New("cross-origin request is not allowed")
+	ErrUnauthorized   = errors.New("missing or invalid credentials")
+	ErrForbidden      = errors.New("not enough permissions")
+	ErrRateLimited    = errors.New("too many requests")
+	ErrKeyReused      = errors.New("idempotency key was used with another request")
+	ErrKeyInProgress  = errors.New("request with this idempotency key is in progress")
+	ErrBatchTooLarge  = errors.New("too many operations in batch")
+	ErrBatchRejected  = errors.New("batch operation failed, batch is rolled back")
+	ErrUnsupported    = errors.New("unsupported content type")
+	ErrNotAcceptable  = errors.New("none of accepted formats is supported")
+	ErrNoRate         = errors.New("no exchange rate for some of the charged months")
+	ErrInvalidPrice   = errors.New("price has more decimal places than currency allows")
+	ErrAlreadyPaused  = errors.New("subscription is already paused")
+	ErrNotPaused      = errors.New("subscription is not paused")
+	ErrCancelled      = errors.New("subscription is cancelled or expired")
 )
@@ -0,0 +1,288 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"iter"
+	"slices"
+	"strings"
+	"testcase/models"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+)
+
+// Subscription fields which may be mapped to CSV columns
+const (
+	FieldUID        = "uid"
+	FieldName       = "name"
+	FieldPrice      = "price"
+	FieldStart      = "start_date"
+	FieldExpires    = "expires"
+	FieldBilling    = "billing_period"
+	FieldCurrency   = "currency"
+	FieldTrialEnd   = "trial_end"
+	FieldTrialPrice = "trial_price"
+)
+
+var fields = []string{FieldUID, FieldName, FieldPrice, FieldStart, FieldExpires, FieldBilling, FieldCurrency, FieldTrialEnd, FieldTrialPrice}
+
+const (
+	// Max length of JSON line
+	maxLineSize = 1 << 20
+	// Max number of rejected rows kept for the report
+	maxReported = 1000
+)
+
+// Maps subscription fields to CSV header columns,
+// unmapped fields are looked up by their own names
+type Mapping map[string]string
+
+// Parses mapping in "field:column,field:column" format
+func ParseMapping(s string) (Mapping, error) {
+	m := make(Mapping)
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, errors.New("invalid column mapping: " + pair)
+		}
+		m[strings.TrimSpace(field)] = strings.TrimSpace(column)
+	}
+	return m, m.validate()
+}
+
+func (m Mapping) validate() error {
+	for field := range m {
+		if !slices.Contains(fields, field) {
+			return errors.New("unknown subscription field in mapping: " + field)
+		}
+	}
+	return nil
+}
+
+// Row which didn't pass parsing or validation
+type Rejected struct {
+	Line   int    `json:"line" example:"3"`
+	Reason string `json:"reason" example:"empty name"`
+}
+
+// Streams subscriptions from CSV or JSON Lines input. Invalid rows are
+// skipped and counted, first of them are collected in Rejected, so Next
+// returns only valid ones. Rows without uid get DefaultUID
+type Parser struct {
+	DefaultUID    uuid.UUID
+	Rejected      []Rejected
+	RejectedCount int
+
+	next func() (*models.Subscription, int, error)
+	sub  *models.Subscription
+	err  error
+}
+
+// Creates parser of CSV input with header row
+func NewCSV(r io.Reader, m Mapping) (*Parser, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("reading csv header error: " + err.Error())
+	}
+	columns := make(map[string]int, len(fields))
+	for _, field := range fields {
+		name := field
+		if mapped, ok := m[field]; ok {
+			name = mapped
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	for _, required := range []string{FieldName, FieldPrice, FieldStart} {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.New("csv header lacks column for " + required)
+		}
+	}
+	p := &Parser{}
+	p.next = func() (*models.Subscription, int, error) {
+		record, err := cr.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, parseErr.StartLine, &rowError{parseErr.Err.Error()}
+			}
+			return nil, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		sub, err := parseRecord(value)
+		return sub, line, err
+	}
+	return p, nil
+}
+
+// Creates parser of JSON Lines input in models.Subscription format
+func NewJSONL(r io.Reader) *Parser {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	p := &Parser{}
+	p.next = func() (*models.Subscription, int, error) {
+		for sc.Scan() {
+			line++
+			data := strings.TrimSpace(sc.Text())
+			if data == "" {
+				continue
+			}
+			var sub models.Subscription
+			if err := sonic.UnmarshalString(data, &sub); err != nil {
+				return nil, line, &rowError{"invalid json: " + err.Error()}
+			}
+			return &sub, line, nil
+		}
+		if err := sc.Err(); err != nil {
+			return nil, line, err
+		}
+		return nil, line, io.EOF
+	}
+	return p
+}
+
+// Advances to the next valid subscription, returns false
+// at the end of input or on read error
+func (p *Parser) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	for {
+		sub, line, err := p.next()
+		if err == nil {
+			if sub.UID == uuid.Nil {
+				sub.UID = p.DefaultUID
+			}
+			p.sub = sub
+			return true
+		}
+		var rowErr *rowError
+		if !errors.As(err, &rowErr) {
+			if !errors.Is(err, io.EOF) {
+				p.err = errors.New("reading input error: " + err.Error())
+			}
+			p.sub = nil
+			return false
+		}
+		p.RejectedCount++
+		if len(p.Rejected) < maxReported {
+			p.Rejected = append(p.Rejected, Rejected{Line: line, Reason: rowErr.reason})
+		}
+	}
+}
+
+// Returns current subscription
+func (p *Parser) Sub() *models.Subscription {
+	return p.sub
+}
+
+// Returns sequence of valid subscriptions, which ends
+// with input error if parsing was stopped by it
+func (p *Parser) All() iter.Seq2[*models.Subscription, error] {
+	return func(yield func(*models.Subscription, error) bool) {
+		for p.Next() {
+			if !yield(p.Sub(), nil) {
+				return
+			}
+		}
+		if p.Err() != nil {
+			yield(nil, p.Err())
+		}
+	}
+}
+
+// Returns input error which stopped parsing, invalid rows aren't errors
+func (p *Parser) Err() error {
+	return p.err
+}
+
+type rowError struct {
+	reason string
+}
+
+func (e *rowError) Error() string {
+	return e.reason
+}
+
+func parseRecord(value func(field string) string) (*models.Subscription, error) {
+	var sub models.Subscription
+	var err error
+	if uid := value(FieldUID); uid != "" {
+		if sub.UID, err = uuid.Parse(uid); err != nil {
+			return nil, &rowError{"invalid uid"}
+		}
+	}
+	sub.Name = value(FieldName)
+	if sub.Price, err = models.ParseMoney(value(FieldPrice)); err != nil {
+		return nil, &rowError{"invalid price"}
+	}
+	if sub.Start, err = models.ParseDate(value(FieldStart)); err != nil {
+		return nil, &rowError{"invalid start_date, expected MM-YYYY or YYYY-MM-DD"}
+	}
+	if expires := value(FieldExpires); expires != "" {
+		parsed, err := models.ParseDate(expires)
+		if err != nil {
+			return nil, &rowError{"invalid expires, expected MM-YYYY or YYYY-MM-DD"}
+		}
+		sub.Expires = &parsed
+	}
+	if trialEnd := value(FieldTrialEnd); trialEnd != "" {
+		parsed, err := models.ParseDate(trialEnd)
+		if err != nil {
+			return nil, &rowError{"invalid trial_end, expected MM-YYYY or YYYY-MM-DD"}
+		}
+		sub.TrialEnd = &parsed
+	}
+	if trialPrice := value(FieldTrialPrice); trialPrice != "" {
+		parsed, err := models.ParseMoney(trialPrice)
+		if err != nil {
+			return nil, &rowError{"invalid trial_price"}
+		}
+		sub.TrialPrice = &parsed
+	}
+	sub.BillingPeriod = models.BillingMonthly
+	if billing := value(FieldBilling); billing != "" {
+		sub.BillingPeriod = strings.ToLower(billing)
+	}
+	sub.Currency = models.BaseCurrency
+	if currency := value(FieldCurrency); currency != "" {
+		sub.Currency = strings.ToUpper(currency)
+	}
+	if models.ValidCurrency(sub.Currency) {
+		if sub.Price, err = sub.Price.ForCurrency(sub.Currency); err != nil {
+			return nil, &rowError{"invalid price, too many decimal places"}
+		}
+		if sub.TrialPrice != nil {
+			trialPrice, err := sub.TrialPrice.ForCurrency(sub.Currency)
+			if err != nil {
+				return nil, &rowError{"invalid trial_price, too many decimal places"}
+			}
+			sub.TrialPrice = &trialPrice
+		}
+	}
+	if err = sub.Validate(); err != nil {
+		return nil, &rowError{err.Error()}
+	}
+	return &sub, nil
+}
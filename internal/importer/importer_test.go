@@ -0,0 +1,121 @@
+package importer_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testcase/internal/importer"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSV(t *testing.T) {
+	t.Parallel()
+	uid := uuid.New()
+	input := "service,Price,start_date,expires,uid\n" +
+		"yandex,400,07-2025,,\n" +
+		"spotify,abc,07-2025,,\n" +
+		",100,07-2025,,\n" +
+		"\"bare \"quote\",100,07-2025,,\n" +
+		"netflix,900,07-2025,06-2025,\n" +
+		"okko,300,01-2025,12-2025," + uid.String() + "\n"
+	p, err := importer.NewCSV(strings.NewReader(input), importer.Mapping{importer.FieldName: "service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultUID := uuid.New()
+	p.DefaultUID = defaultUID
+	var names []string
+	for p.Next() {
+		names = append(names, p.Sub().Name)
+		if p.Sub().Name == "yandex" {
+			assert.Equal(t, defaultUID, p.Sub().UID)
+		} else {
+			assert.Equal(t, uid, p.Sub().UID)
+		}
+	}
+	assert.NoError(t, p.Err())
+	assert.Equal(t, []string{"yandex", "okko"}, names)
+	assert.Equal(t, 4, p.RejectedCount)
+	assert.Equal(t, []importer.Rejected{
+		{Line: 3, Reason: "invalid price"},
+		{Line: 4, Reason: "empty name"},
+		{Line: 5, Reason: csv.ErrQuote.Error()},
+		{Line: 6, Reason: "expires is before start_date"},
+	}, p.Rejected)
+}
+
+func TestCSVHeader(t *testing.T) {
+	t.Parallel()
+	t.Run("missing column", func(t *testing.T) {
+		_, err := importer.NewCSV(strings.NewReader("name,price\n"), nil)
+		assert.Error(t, err)
+	})
+	t.Run("unknown field in mapping", func(t *testing.T) {
+		_, err := importer.ParseMapping("cost:price")
+		assert.Error(t, err)
+	})
+}
+
+func TestJSONL(t *testing.T) {
+	t.Parallel()
+	input := `{"name":"yandex","price":400,"start_date":"07-2025"}
+
+{"name":"spotify","price":200,"start_date":"2025-07"}
+not json
+{"name":"okko","price":-1,"start_date":"07-2025"}
+`
+	p := importer.NewJSONL(strings.NewReader(input))
+	count := 0
+	for sub, err := range p.All() {
+		assert.NoError(t, err)
+		assert.Equal(t, "yandex", sub.Name)
+		count++
+	}
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 3, p.RejectedCount)
+	lines := make([]int, 0, len(p.Rejected))
+	for _, rej := range p.Rejected {
+		lines = append(lines, rej.Line)
+	}
+	assert.Equal(t, []int{3, 4, 5}, lines)
+}
+
+func TestCSVBillingPeriod(t *testing.T) {
+	t.Parallel()
+	input := "name,price,start_date,billing_period\n" +
+		"yandex,400,07-2025,\n" +
+		"okko,3000,07-2025,Yearly\n" +
+		"spotify,100,07-2025,daily\n"
+	p, err := importer.NewCSV(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var periods []string
+	for p.Next() {
+		periods = append(periods, p.Sub().BillingPeriod)
+	}
+	assert.NoError(t, p.Err())
+	assert.Equal(t, []string{"monthly", "yearly"}, periods)
+	assert.Equal(t, []importer.Rejected{{Line: 4, Reason: "invalid billing_period"}}, p.Rejected)
+}
+
+func TestCSVPrice(t *testing.T) {
+	t.Parallel()
+	input := "name,price,start_date,currency\n" +
+		"yandex,299.99,07-2025,\n" +
+		"okko,300.5,07-2025,jpy\n" +
+		"kinopoisk,1500,07-2025,JPY\n"
+	p, err := importer.NewCSV(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prices []string
+	for p.Next() {
+		prices = append(prices, p.Sub().Price.String())
+	}
+	assert.NoError(t, p.Err())
+	assert.Equal(t, []string{"299.99", "1500"}, prices)
+	assert.Equal(t, []importer.Rejected{{Line: 3, Reason: "invalid price, too many decimal places"}}, p.Rejected)
+}
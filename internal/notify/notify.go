@@ -0,0 +1,52 @@
+// Package notify sends reminders about renewing and expiring
+// subscriptions through pluggable channels
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testcase/models"
+)
+
+// Returned by notifiers which can't send the reminder to its user
+// yet, e.g. without email. Reminder isn't counted as sent then
+var ErrSkipped = errors.New("reminder can't be sent through the channel")
+
+type Notifier interface {
+	// Sends reminder to the user, returned error means
+	// reminder wasn't delivered and may be sent again
+	Notify(ctx context.Context, r *models.Reminder) error
+}
+
+// Adapter allowing to use ordinary functions as Notifier
+type NotifierFunc func(ctx context.Context, r *models.Reminder) error
+
+func (f NotifierFunc) Notify(ctx context.Context, r *models.Reminder) error {
+	return f(ctx, r)
+}
+
+// Writes reminders to the default logger
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, r *models.Reminder) error {
+	slog.InfoContext(ctx, "subscription reminder",
+		slog.String("kind", r.Kind),
+		slog.Int("sub_id", r.SubID),
+		slog.String("uid", r.UID.String()),
+		slog.String("date", models.FullFormat.Date(r.Date)),
+		slog.String("text", Text(r)))
+	return nil
+}
+
+// Returns human readable text of reminder
+func Text(r *models.Reminder) string {
+	if r.Kind == models.ReminderExpiry {
+		return "Subscription " + r.Name + " expires on " + models.FullFormat.Date(r.Date)
+	}
+	text := "Subscription " + r.Name + " renews on " + models.FullFormat.Date(r.Date)
+	if r.Amount != nil {
+		text += ", " + r.Amount.String() + " " + r.Currency + " will be charged"
+	}
+	return text
+}
@@ -0,0 +1,212 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testcase/internal/notify"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func reminder() *models.Reminder {
+	date, _ := models.ParseDate("2025-09-17")
+	amount := models.MustParseMoney("399.99")
+	return &models.Reminder{
+		Kind:     models.ReminderRenewal,
+		SubID:    1,
+		UID:      uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		Email:    "user@example.com",
+		Name:     "yandex",
+		Date:     date,
+		Amount:   &amount,
+		Currency: models.BaseCurrency,
+	}
+}
+
+func TestText(t *testing.T) {
+	t.Parallel()
+	r := reminder()
+	assert.Equal(t, "Subscription yandex renews on 2025-09-17, 399.99 RUB will be charged", notify.Text(r))
+	r.Kind, r.Amount = models.ReminderExpiry, nil
+	assert.Equal(t, "Subscription yandex expires on 2025-09-17", notify.Text(r))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+	var body string
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	n := notify.NewWebhook(srv.URL, time.Second)
+	assert.NoError(t, n.Notify(context.Background(), reminder()))
+	assert.JSONEq(t, `{"kind":"renewal","sub_id":1,"uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","email":"user@example.com",
+"name":"yandex","date":"2025-09-17","amount":"399.99","currency":"RUB"}`, body)
+	status = http.StatusBadGateway
+	assert.Error(t, n.Notify(context.Background(), reminder()))
+}
+
+// Accepts a single SMTP session and returns the message data
+func smtpStub(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+	data := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) {
+			_, _ = conn.Write([]byte(line + "\r\n"))
+		}
+		reply("220 stub")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					line, err = r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					msg.WriteString(line)
+				}
+				data <- msg.String()
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	t.Parallel()
+	addr, data := smtpStub(t)
+	n := notify.NewSMTP(&notify.SMTPConfig{Addr: addr, From: "subs@example.com"})
+	t.Run("without email", func(t *testing.T) {
+		r := reminder()
+		r.Email = ""
+		assert.ErrorIs(t, n.Notify(context.Background(), r), notify.ErrSkipped)
+	})
+	t.Run("mailed", func(t *testing.T) {
+		assert.NoError(t, n.Notify(context.Background(), reminder()))
+		msg := <-data
+		assert.Contains(t, msg, "To: user@example.com\r\n")
+		assert.Contains(t, msg, "Subject: Subscription yandex renews on 2025-09-17, 399.99 RUB will be charged\r\n")
+	})
+}
+
+type fakeRepo struct {
+	mu        sync.Mutex
+	reminders []*models.Reminder
+	sent      map[string]bool
+}
+
+func key(r *models.Reminder, channel string) string {
+	return r.Kind + models.FullFormat.Date(r.Date) + channel
+}
+
+func (f *fakeRepo) DueReminders(ctx context.Context, days int, channels []string) ([]*models.Reminder, error) {
+	return f.reminders, nil
+}
+
+func (f *fakeRepo) ClaimReminder(ctx context.Context, r *models.Reminder, channel string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sent[key(r, channel)] {
+		return false, nil
+	}
+	f.sent[key(r, channel)] = true
+	return true, nil
+}
+
+func (f *fakeRepo) ReleaseReminder(ctx context.Context, r *models.Reminder, channel string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sent, key(r, channel))
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+	repo := &fakeRepo{reminders: []*models.Reminder{reminder()}, sent: make(map[string]bool)}
+	logged, failing := 0, true
+	s := notify.NewScheduler(repo, map[string]notify.Notifier{
+		"log": notify.NotifierFunc(func(ctx context.Context, r *models.Reminder) error {
+			logged++
+			return nil
+		}),
+		"webhook": notify.NotifierFunc(func(ctx context.Context, r *models.Reminder) error {
+			if failing {
+				return errors.New("unavailable")
+			}
+			return nil
+		}),
+	})
+	sent, err := s.RunOnce(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, sent)
+	failing = false
+	sent, err = s.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	sent, err = s.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, 1, logged)
+}
+
+func TestSchedulerSkipped(t *testing.T) {
+	t.Parallel()
+	r := reminder()
+	repo := &fakeRepo{reminders: []*models.Reminder{r}, sent: make(map[string]bool)}
+	s := notify.NewScheduler(repo, map[string]notify.Notifier{
+		"email": notify.NotifierFunc(func(ctx context.Context, r *models.Reminder) error {
+			if r.Email == "" {
+				return notify.ErrSkipped
+			}
+			return nil
+		}),
+	})
+	r.Email = ""
+	sent, err := s.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.False(t, repo.sent[key(r, "email")], "skipped reminder stays unclaimed")
+	r.Email = "user@example.com"
+	sent, err = s.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+}
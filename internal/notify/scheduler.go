@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"testcase/internal/settings"
+	"testcase/models"
+	"time"
+)
+
+type Repository interface {
+	DueReminders(ctx context.Context, days int, channels []string) ([]*models.Reminder, error)
+	ClaimReminder(ctx context.Context, r *models.Reminder, channel string) (bool, error)
+	ReleaseReminder(ctx context.Context, r *models.Reminder, channel string) error
+}
+
+// Looks up due reminders and sends each of them once per channel
+type Scheduler struct {
+	repo     Repository
+	channels map[string]Notifier
+	cfg      atomic.Pointer[settings.Reminders]
+}
+
+// Takes notifiers by channel names, which reminders
+// are tracked with to avoid sending duplicates
+func NewScheduler(repo Repository, channels map[string]Notifier) *Scheduler {
+	s := &Scheduler{
+		repo:     repo,
+		channels: channels,
+	}
+	s.Reconfigure(settings.DefaultRuntime())
+	return s
+}
+
+// Applies runtime configuration, used as settings subscriber
+func (s *Scheduler) Reconfigure(rt *settings.Runtime) {
+	cfg := rt.Reminders
+	s.cfg.Store(&cfg)
+}
+
+// Sends reminders which are due now through channels they weren't
+// sent through yet and returns number of sent ones. Reminder is claimed
+// before sending and released if sending fails or is skipped, so it
+// is retried later
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	names := slices.Sorted(maps.Keys(s.channels))
+	reminders, err := s.repo.DueReminders(ctx, s.cfg.Load().LeadDays, names)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, r := range reminders {
+		for _, name := range names {
+			claimed, err := s.repo.ClaimReminder(ctx, r, name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			if err = s.channels[name].Notify(ctx, r); err != nil {
+				if !errors.Is(err, ErrSkipped) {
+					errs = append(errs, errors.New(name+" channel error: "+err.Error()))
+				}
+				if err = s.repo.ReleaseReminder(ctx, r, name); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			sent++
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// Starts background sending of reminders, which runs until ctx is done.
+// Interval and lead days are taken from the current runtime configuration
+func (s *Scheduler) Run(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.cfg.Load().Interval):
+			}
+			if !s.cfg.Load().Enabled {
+				continue
+			}
+			n, err := s.RunOnce(ctx)
+			if err != nil {
+				slog.Error("sending reminders failed",
+					slog.Int("sent", n),
+					slog.String("error", err.Error()))
+				continue
+			}
+			slog.Info("sent reminders", slog.Int("sent", n))
+		}
+	}()
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"testcase/models"
+	"time"
+)
+
+type SMTPConfig struct {
+	// host:port of SMTP server
+	Addr string
+	From string
+	// PLAIN auth is used if User is set
+	User     string
+	Password string
+}
+
+// Mails reminders to email of the user, reminders of users
+// without email are skipped with ErrSkipped
+type SMTPNotifier struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+}
+
+func NewSMTP(cfg *SMTPConfig) *SMTPNotifier {
+	n := &SMTPNotifier{cfg: *cfg}
+	if cfg.User != "" {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		n.auth = smtp.PlainAuth("", cfg.User, cfg.Password, host)
+	}
+	return n
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, r *models.Reminder) error {
+	if r.Email == "" {
+		return ErrSkipped
+	}
+	text := Text(r)
+	msg := strings.Join([]string{
+		"From: " + n.cfg.From,
+		"To: " + r.Email,
+		"Subject: " + mime.QEncoding.Encode("utf-8", text),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		text + ".",
+		"",
+	}, "\r\n")
+	// SendMail doesn't take context, so it is only checked before sending
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(n.cfg.Addr, n.auth, n.cfg.From, []string{r.Email}, []byte(msg)); err != nil {
+		return errors.New("sending mail error: " + err.Error())
+	}
+	return nil
+}
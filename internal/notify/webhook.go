@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testcase/models"
+	"time"
+)
+
+// Posts reminders as JSON to URL, any response
+// status other than 2xx is treated as failure
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, r *models.Reminder) error {
+	body, err := r.MarshalFormat(models.FullFormat)
+	if err != nil {
+		return errors.New("marshalling reminder error: " + err.Error())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("building webhook request error: " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.New("sending webhook error: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("webhook responded with status " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
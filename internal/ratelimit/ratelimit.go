@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Token bucket parameters: bucket holds up to Burst tokens
+// and is refilled with Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the bucket is full again
+	Reset time.Duration
+	// Time until the next token, zero if request is allowed
+	RetryAfter time.Duration
+}
+
+type Store interface {
+	// Takes one token from the bucket identified by key
+	Take(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// Adapter allowing to use ordinary functions as Store
+type StoreFunc func(ctx context.Context, key string, limit Limit) (*Result, error)
+
+func (f StoreFunc) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	return f(ctx, key, limit)
+}
+
+// Builds result from tokens left in the bucket after taking
+func NewResult(allowed bool, tokens float64, limit Limit) *Result {
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// In-memory store for single instance deployments
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens:  float64(limit.Burst),
+			updated: now,
+		}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return NewResult(allowed, b.tokens, limit), nil
+}
+
+// Removes buckets which weren't used for a long time, they
+// are full anyway for any sane limit
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) > time.Hour {
+			delete(m.buckets, key)
+		}
+	}
+}
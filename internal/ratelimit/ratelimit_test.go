@@ -0,0 +1,50 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testcase/internal/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 0.5, Burst: 2}
+	t.Run("burst is allowed", func(t *testing.T) {
+		for i := 1; i >= 0; i-- {
+			res, err := store.Take(context.Background(), "key", limit)
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, i, res.Remaining)
+			assert.Equal(t, 2, res.Limit)
+		}
+	})
+	t.Run("exceeded", func(t *testing.T) {
+		res, err := store.Take(context.Background(), "key", limit)
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+		assert.Equal(t, 2*time.Second, res.RetryAfter)
+		assert.Equal(t, 4*time.Second, res.Reset)
+	})
+	t.Run("other keys are independent", func(t *testing.T) {
+		res, err := store.Take(context.Background(), "other", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+	})
+}
+
+func TestNewResult(t *testing.T) {
+	t.Parallel()
+	res := ratelimit.NewResult(false, 0.25, ratelimit.Limit{Rate: 1, Burst: 10})
+	assert.Equal(t, &ratelimit.Result{
+		Allowed:    false,
+		Limit:      10,
+		Remaining:  0,
+		Reset:      10 * time.Second,
+		RetryAfter: time.Second,
+	}, res)
+}
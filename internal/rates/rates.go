@@ -0,0 +1,78 @@
+// Package rates reads exchange rates files in CSV format with
+// currency, effective_from (MM-YYYY) and rate columns, e.g.
+//
+//	currency,effective_from,rate
+//	USD,07-2025,78.5
+package rates
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testcase/models"
+	"time"
+)
+
+var header = []string{"currency", "effective_from", "rate"}
+
+// Parses rates from CSV with header row, the first invalid row fails parsing
+func ParseCSV(r io.Reader) ([]*models.ExchangeRate, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(header)
+	cr.TrimLeadingSpace = true
+	head, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("reading rates header error: " + err.Error())
+	}
+	for i, column := range header {
+		if !strings.EqualFold(strings.TrimSpace(head[i]), column) {
+			return nil, errors.New("rates header must be " + strings.Join(header, ","))
+		}
+	}
+	var result []*models.ExchangeRate
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, errors.New("reading rates error: " + err.Error())
+		}
+		line, _ := cr.FieldPos(0)
+		rate, err := parseRecord(record)
+		if err != nil {
+			return nil, errors.New("invalid rate at line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+		result = append(result, rate)
+	}
+}
+
+// Reads rates from CSV file
+func ReadFile(path string) ([]*models.ExchangeRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("opening rates file error: " + err.Error())
+	}
+	defer f.Close()
+	return ParseCSV(f)
+}
+
+func parseRecord(record []string) (*models.ExchangeRate, error) {
+	effective, err := time.Parse("01-2006", strings.TrimSpace(record[1]))
+	if err != nil {
+		return nil, errors.New("invalid effective_from, expected MM-YYYY")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+	if err != nil {
+		return nil, errors.New("invalid rate")
+	}
+	rate := &models.ExchangeRate{
+		Currency:      strings.ToUpper(strings.TrimSpace(record[0])),
+		EffectiveFrom: effective,
+		Rate:          value,
+	}
+	return rate, rate.Validate()
+}
@@ -0,0 +1,44 @@
+package rates_test
+
+import (
+	"strings"
+	"testcase/internal/rates"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSV(t *testing.T) {
+	t.Parallel()
+	t.Run("successful", func(t *testing.T) {
+		input := "currency,effective_from,rate\n" +
+			"usd,07-2025,78.5\n" +
+			"EUR, 08-2025, 91\n"
+		result, err := rates.ParseCSV(strings.NewReader(input))
+		assert.NoError(t, err)
+		july, _ := time.Parse("01-2006", "07-2025")
+		august, _ := time.Parse("01-2006", "08-2025")
+		assert.Equal(t, []*models.ExchangeRate{
+			{Currency: "USD", EffectiveFrom: july, Rate: 78.5},
+			{Currency: "EUR", EffectiveFrom: august, Rate: 91},
+		}, result)
+	})
+	t.Run("invalid header", func(t *testing.T) {
+		_, err := rates.ParseCSV(strings.NewReader("code,month,rate\n"))
+		assert.Error(t, err)
+	})
+	t.Run("invalid rows", func(t *testing.T) {
+		for _, row := range []string{
+			"USD,2025-07,78.5",
+			"USD,07-2025,abc",
+			"USD,07-2025,-1",
+			"RUB,07-2025,1",
+			"DOLLAR,07-2025,78.5",
+		} {
+			_, err := rates.ParseCSV(strings.NewReader("currency,effective_from,rate\n" + row + "\n"))
+			assert.Error(t, err, row)
+		}
+	})
+}
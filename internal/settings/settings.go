@@ -1,9 +1,21 @@
 package settings
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -13,6 +25,83 @@ var (
 )
 
 type Config struct {
+	runtime     atomic.Pointer[Runtime]
+	mu          sync.Mutex
+	subscribers []func(*Runtime)
+}
+
+// Runtime is the part of configuration which can be changed
+// without restarting the service
+type Runtime struct {
+	LogLevel         slog.Level
+	QueryTimeout     time.Duration
+	AggregateTimeout time.Duration
+	ExportTimeout    time.Duration
+	ImportTimeout    time.Duration
+	CORS             CORS
+	RateLimits       RateLimits
+	IdempotencyTTL   time.Duration
+	BatchMaxSize     int
+	// Interval of comment lines sent to change streams
+	StreamHeartbeat time.Duration
+	// Default mapping of subscription fields to CSV columns on import
+	CSVColumns map[string]string
+	SoftDelete SoftDelete
+	Reminders  Reminders
+	Webhooks   Webhooks
+}
+
+// Outbox is polled every Interval and up to BatchSize deliveries are
+// sent at once. Failed deliveries are retried after BackoffBase doubled
+// on every attempt up to BackoffMax, and are dead after MaxAttempts
+type Webhooks struct {
+	Interval    time.Duration
+	Timeout     time.Duration
+	BatchSize   int
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// Every Interval subscriptions renewing or expiring in LeadDays
+// are looked up and their users are reminded about them
+type Reminders struct {
+	Enabled  bool
+	Interval time.Duration
+	LeadDays int
+}
+
+// Soft deleted subscriptions are purged every PurgeInterval
+// once they were deleted longer than Retention ago
+type SoftDelete struct {
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// CORS policy. Origins may contain "*" for any origin or
+// wildcard subdomains like "https://*.example.com"
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Token bucket limits: Rate is requests per second, Burst is
+// bucket size. Route limits are applied in addition to the default one.
+// PerIP limit is applied by client IP before authentication
+type RateLimits struct {
+	Enabled bool
+	Default RateLimit
+	PerIP   RateLimit
+	Routes  map[string]RateLimit
+}
+
+type RateLimit struct {
+	Rate  float64
+	Burst int
 }
 
 func GetConfig() *Config {
@@ -20,15 +109,46 @@ func GetConfig() *Config {
 		viper.AddConfigPath("./config")
 		viper.SetConfigName("cfg")
 		viper.SetConfigType("yaml")
+		setDefaults(viper.GetViper())
+		// Secret is better kept out of the config file baked into image
+		_ = viper.BindEnv("auth.jwt.hmac_secret", "AUTH_JWT_HMAC_SECRET")
 		err := viper.ReadInConfig()
 		if err != nil {
 			log.Fatal(err)
 		}
+		if err = validateStatic(viper.GetViper()); err != nil {
+			log.Fatal(err)
+		}
+		rt, err := parseRuntime(viper.GetViper())
+		if err != nil {
+			log.Fatal(err)
+		}
 		instance = &Config{}
+		instance.runtime.Store(rt)
 	})
 	return instance
 }
 
+// Shortest accepted secret for HS* tokens
+const minHMACSecretLen = 32
+
+// Secrets of config examples, anyone could forge tokens signed with them
+var exampleSecrets = map[string]bool{
+	"change-me": true,
+	"changeme":  true,
+	"secret":    true,
+}
+
+// Validates the part of configuration which is read once on start
+func validateStatic(v *viper.Viper) error {
+	secret := v.GetString("auth.jwt.hmac_secret")
+	if secret != "" && (exampleSecrets[strings.ToLower(secret)] || len(secret) < minHMACSecretLen) {
+		return errors.New("auth.jwt.hmac_secret must be a random string of at least " +
+			strconv.Itoa(minHMACSecretLen) + " characters, empty one disables HS* tokens")
+	}
+	return nil
+}
+
 func (cfg *Config) GetString(key string) string {
 	return viper.GetString(key)
 }
@@ -36,3 +156,232 @@ func (cfg *Config) GetString(key string) string {
 func (cfg *Config) Get(key string) any {
 	return viper.Get(key)
 }
+
+func (cfg *Config) GetStringSlice(key string) []string {
+	return viper.GetStringSlice(key)
+}
+
+func (cfg *Config) GetDuration(key string) time.Duration {
+	return viper.GetDuration(key)
+}
+
+// Returns current runtime-tunable configuration. Returned value
+// must not be modified
+func (cfg *Config) Runtime() *Runtime {
+	return cfg.runtime.Load()
+}
+
+// Registers fn to be called with the new runtime configuration
+// after every successful reload. fn is also called immediately
+// with the current one
+func (cfg *Config) Subscribe(fn func(*Runtime)) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.subscribers = append(cfg.subscribers, fn)
+	fn(cfg.runtime.Load())
+}
+
+// Re-reads config file and swaps runtime configuration. If the file
+// can't be read or contains invalid values, previous configuration is kept
+func (cfg *Config) Reload() error {
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigFile(viper.ConfigFileUsed())
+	if err := v.ReadInConfig(); err != nil {
+		return errors.New("reading config error: " + err.Error())
+	}
+	rt, err := parseRuntime(v)
+	if err != nil {
+		return err
+	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if reflect.DeepEqual(rt, cfg.runtime.Load()) {
+		return nil
+	}
+	cfg.runtime.Store(rt)
+	for _, fn := range cfg.subscribers {
+		fn(rt)
+	}
+	return nil
+}
+
+// Starts watching config file changes and SIGHUP, reloading runtime
+// configuration on each of them until ctx is done
+func (cfg *Config) Watch(ctx context.Context) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		cfg.reloadWithLog("config file changed")
+	})
+	viper.WatchConfig()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				cfg.reloadWithLog("SIGHUP received")
+			}
+		}
+	}()
+}
+
+func (cfg *Config) reloadWithLog(reason string) {
+	if err := cfg.Reload(); err != nil {
+		slog.Error("config reload rejected, keeping previous one",
+			slog.String("reason", reason),
+			slog.String("error", err.Error()))
+		return
+	}
+	slog.Info("config reloaded", slog.String("reason", reason))
+}
+
+// Returns runtime configuration with default values
+func DefaultRuntime() *Runtime {
+	rt, _ := parseRuntime(setDefaults(viper.New()))
+	return rt
+}
+
+func setDefaults(v *viper.Viper) *viper.Viper {
+	v.SetDefault("log_level", "info")
+	v.SetDefault("query_timeout", "10s")
+	v.SetDefault("aggregate_query_timeout", "15s")
+	v.SetDefault("export_timeout", "10m")
+	v.SetDefault("import_timeout", "10m")
+	v.SetDefault("cors.allowed_origins", []string{"*"})
+	v.SetDefault("cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE"})
+	v.SetDefault("cors.allowed_headers", []string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key", "Last-Event-ID"})
+	v.SetDefault("cors.exposed_headers", []string{"X-Request-ID", "ETag",
+		"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"})
+	v.SetDefault("cors.allow_credentials", false)
+	v.SetDefault("cors.max_age", "10m")
+	v.SetDefault("idempotency_ttl", "24h")
+	v.SetDefault("batch_max_size", 100)
+	v.SetDefault("stream_heartbeat", "15s")
+	v.SetDefault("soft_delete.retention", "720h")
+	v.SetDefault("soft_delete.purge_interval", "1h")
+	v.SetDefault("reminders.enabled", true)
+	v.SetDefault("reminders.interval", "1h")
+	v.SetDefault("reminders.lead_days", 3)
+	v.SetDefault("webhooks.interval", "5s")
+	v.SetDefault("webhooks.timeout", "10s")
+	v.SetDefault("webhooks.batch_size", 100)
+	v.SetDefault("webhooks.max_attempts", 8)
+	v.SetDefault("webhooks.backoff_base", "30s")
+	v.SetDefault("webhooks.backoff_max", "6h")
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.default.rate", 10)
+	v.SetDefault("rate_limit.default.burst", 20)
+	v.SetDefault("rate_limit.per_ip.rate", 50)
+	v.SetDefault("rate_limit.per_ip.burst", 100)
+	return v
+}
+
+func parseRuntime(v *viper.Viper) (*Runtime, error) {
+	rt := &Runtime{
+		QueryTimeout:     v.GetDuration("query_timeout"),
+		AggregateTimeout: v.GetDuration("aggregate_query_timeout"),
+		ExportTimeout:    v.GetDuration("export_timeout"),
+		ImportTimeout:    v.GetDuration("import_timeout"),
+		IdempotencyTTL:   v.GetDuration("idempotency_ttl"),
+		BatchMaxSize:     v.GetInt("batch_max_size"),
+		StreamHeartbeat:  v.GetDuration("stream_heartbeat"),
+		CSVColumns:       v.GetStringMapString("import.csv_columns"),
+		SoftDelete: SoftDelete{
+			Retention:     v.GetDuration("soft_delete.retention"),
+			PurgeInterval: v.GetDuration("soft_delete.purge_interval"),
+		},
+		Reminders: Reminders{
+			Enabled:  v.GetBool("reminders.enabled"),
+			Interval: v.GetDuration("reminders.interval"),
+			LeadDays: v.GetInt("reminders.lead_days"),
+		},
+		Webhooks: Webhooks{
+			Interval:    v.GetDuration("webhooks.interval"),
+			Timeout:     v.GetDuration("webhooks.timeout"),
+			BatchSize:   v.GetInt("webhooks.batch_size"),
+			MaxAttempts: v.GetInt("webhooks.max_attempts"),
+			BackoffBase: v.GetDuration("webhooks.backoff_base"),
+			BackoffMax:  v.GetDuration("webhooks.backoff_max"),
+		},
+		CORS: CORS{
+			AllowedOrigins:   v.GetStringSlice("cors.allowed_origins"),
+			AllowedMethods:   v.GetStringSlice("cors.allowed_methods"),
+			AllowedHeaders:   v.GetStringSlice("cors.allowed_headers"),
+			ExposedHeaders:   v.GetStringSlice("cors.exposed_headers"),
+			AllowCredentials: v.GetBool("cors.allow_credentials"),
+			MaxAge:           v.GetDuration("cors.max_age"),
+		},
+	}
+	rt.RateLimits = RateLimits{
+		Enabled: v.GetBool("rate_limit.enabled"),
+		Default: RateLimit{
+			Rate:  v.GetFloat64("rate_limit.default.rate"),
+			Burst: v.GetInt("rate_limit.default.burst"),
+		},
+		PerIP: RateLimit{
+			Rate:  v.GetFloat64("rate_limit.per_ip.rate"),
+			Burst: v.GetInt("rate_limit.per_ip.burst"),
+		},
+	}
+	if err := v.UnmarshalKey("rate_limit.routes", &rt.RateLimits.Routes); err != nil {
+		return nil, errors.New("invalid rate_limit.routes: " + err.Error())
+	}
+	if err := rt.LogLevel.UnmarshalText([]byte(v.GetString("log_level"))); err != nil {
+		return nil, errors.New("invalid log_level: " + err.Error())
+	}
+	if rt.QueryTimeout <= 0 || rt.AggregateTimeout <= 0 || rt.ExportTimeout <= 0 || rt.ImportTimeout <= 0 {
+		return nil, errors.New("query timeouts must be positive durations")
+	}
+	if rt.IdempotencyTTL <= 0 {
+		return nil, errors.New("idempotency_ttl must be positive duration")
+	}
+	if rt.BatchMaxSize <= 0 {
+		return nil, errors.New("batch_max_size must be positive")
+	}
+	if rt.StreamHeartbeat <= 0 {
+		return nil, errors.New("stream_heartbeat must be positive duration")
+	}
+	if rt.SoftDelete.Retention <= 0 || rt.SoftDelete.PurgeInterval <= 0 {
+		return nil, errors.New("soft_delete retention and purge_interval must be positive durations")
+	}
+	if rt.Reminders.Interval <= 0 || rt.Reminders.LeadDays <= 0 {
+		return nil, errors.New("reminders interval and lead_days must be positive")
+	}
+	if wh := rt.Webhooks; wh.Interval <= 0 || wh.Timeout <= 0 || wh.BatchSize <= 0 || wh.MaxAttempts <= 0 ||
+		wh.BackoffBase <= 0 || wh.BackoffMax < wh.BackoffBase {
+		return nil, errors.New("webhooks values must be positive and backoff_max must not be less than backoff_base")
+	}
+	for _, origin := range rt.CORS.AllowedOrigins {
+		if origin == "" {
+			return nil, errors.New("cors.allowed_origins contains empty origin")
+		}
+		if origin == "*" && rt.CORS.AllowCredentials {
+			return nil, errors.New("cors.allow_credentials can't be used with \"*\" origin")
+		}
+		if strings.Count(origin, "*") > 1 || (origin != "*" && strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+			return nil, errors.New("invalid wildcard in cors origin " + origin)
+		}
+	}
+	if rt.CORS.MaxAge < 0 {
+		return nil, errors.New("cors.max_age must not be negative")
+	}
+	if !rt.RateLimits.Default.valid() {
+		return nil, errors.New("invalid rate_limit.default, rate and burst must be positive")
+	}
+	if !rt.RateLimits.PerIP.valid() {
+		return nil, errors.New("invalid rate_limit.per_ip, rate and burst must be positive")
+	}
+	for route, limit := range rt.RateLimits.Routes {
+		if !limit.valid() {
+			return nil, errors.New("invalid rate limit for route " + route + ", rate and burst must be positive")
+		}
+	}
+	return rt, nil
+}
+
+func (l RateLimit) valid() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
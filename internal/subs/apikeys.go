@@ -0,0 +1,72 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Saves new API key with provided hash, sets key's ID and CreatedAt
+func (cli *Client) AddAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	err := cli.conn.QueryRow(ctx, `INSERT INTO api_keys (name, key_hash, uid, admin) VALUES
+($1, $2, $3, $4) RETURNING id, created_at;`, key.Name, hash, key.UID, key.Admin).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return errors.New("error inserting api key: " + err.Error())
+	}
+	return nil
+}
+
+// Returns not revoked API key by its hash, if there is no any
+// returns ErrNoSuchRow
+func (cli *Client) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	var key models.APIKey
+	row := cli.conn.QueryRow(ctx, `SELECT id, name, uid, admin, created_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL;`, hash)
+	if err := row.Scan(&key.ID, &key.Name, &key.UID, &key.Admin, &key.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errvalues.ErrNoSuchRow
+		}
+		return nil, errors.New("error getting api key: " + err.Error())
+	}
+	return &key, nil
+}
+
+// Returns all API keys including revoked ones
+func (cli *Client) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, `SELECT id, name, uid, admin, created_at, revoked_at FROM api_keys ORDER BY id;`)
+	if err != nil {
+		return nil, errors.New("getting api keys error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.APIKey, 0)
+	for rows.Next() {
+		var key models.APIKey
+		if err = rows.Scan(&key.ID, &key.Name, &key.UID, &key.Admin, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &key)
+	}
+	return result, rows.Err()
+}
+
+// Revokes key with provided id, if there is no active one
+// returns ErrNoSuchRow
+func (cli *Client) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL;`, id)
+	if err != nil {
+		return errors.New("revoking api key error: " + err.Error())
+	} else if tag.RowsAffected() == 0 {
+		return errvalues.ErrNoSuchRow
+	}
+	return nil
+}
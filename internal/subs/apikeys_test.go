@@ -0,0 +1,83 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindAPIKey(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	key := &models.APIKey{
+		ID:        3,
+		Name:      "billing",
+		UID:       uuid.New(),
+		CreatedAt: time.Now(),
+	}
+	query := regexp.QuoteMeta(`SELECT id, name, uid, admin, created_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs("hash").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "uid", "admin", "created_at"}).
+				AddRow(key.ID, key.Name, key.UID, key.Admin, key.CreatedAt))
+		result, err := cli.FindAPIKey(context.Background(), "hash")
+		assert.NoError(t, err)
+		assert.Equal(t, key, result)
+	})
+	t.Run("unknown or revoked", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs("hash").
+			WillReturnError(pgx.ErrNoRows)
+		_, err := cli.FindAPIKey(context.Background(), "hash")
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+	})
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	query := regexp.QuoteMeta(`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(3).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		assert.NoError(t, cli.RevokeAPIKey(context.Background(), 3))
+	})
+	t.Run("already revoked", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(3).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+		assert.ErrorIs(t, cli.RevokeAPIKey(context.Background(), 3), errvalues.ErrNoSuchRow)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(3).
+			WillReturnError(errors.New("db error"))
+		assert.Error(t, cli.RevokeAPIKey(context.Background(), 3))
+	})
+}
@@ -0,0 +1,118 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/auth"
+	"testcase/models"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// Actor of changes made without principal (background jobs)
+const systemActor = "system"
+
+func actor(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.Subject
+	}
+	return systemActor
+}
+
+func requestID(ctx context.Context) *string {
+	if id, ok := ctx.Value("Request-ID").(string); ok {
+		return &id
+	}
+	return nil
+}
+
+// Snapshots are stored in full format, so no day is lost
+func snapshot(s *models.Subscription) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return s.MarshalFormat(models.FullFormat)
+}
+
+// Writes audit record of the change within tx and puts
+// it to webhook outbox, so it is delivered once committed
+func recordChange(ctx context.Context, tx pgx.Tx, op string, before, after *models.Subscription) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return errors.New("encoding snapshot error: " + err.Error())
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return errors.New("encoding snapshot error: " + err.Error())
+	}
+	sub := after
+	if sub == nil {
+		sub = before
+	}
+	_, err = tx.Exec(ctx, `WITH change AS (INSERT INTO audit_log (sub_id, uid, actor, request_id, op, before, after) VALUES
+($1, $2, $3, $4, $5, $6, $7) RETURNING id)
+INSERT INTO webhook_outbox (audit_id) SELECT id FROM change;`, sub.ID, sub.UID, actor(ctx), requestID(ctx), op, beforeJSON, afterJSON)
+	if err != nil {
+		return errors.New("recording change error: " + err.Error())
+	}
+	return nil
+}
+
+// Returns audit records matching opts, newest first.
+// Non-admin principal gets records of its user's subscriptions only
+func (cli *Client) ListAudit(ctx context.Context, opts *models.AuditOpts) ([]*models.AuditRecord, error) {
+	query := squirrel.Select("id, sub_id, uid, actor, request_id, op, before, after, created_at").
+		From("audit_log").
+		OrderBy("id DESC").
+		Offset(uint64(opts.Offset))
+	if opts.Limit != 0 {
+		query = query.Limit(uint64(opts.Limit))
+	}
+	filter := squirrel.Eq{}
+	if opts.SubID != 0 {
+		filter["sub_id"] = opts.SubID
+	}
+	if opts.Actor != "" {
+		filter["actor"] = opts.Actor
+	}
+	if opts.Op != "" {
+		filter["op"] = opts.Op
+	}
+	if uid, scoped := ownerScope(ctx); scoped {
+		filter["uid"] = uid
+	}
+	query = query.Where(filter)
+	if !opts.From.IsZero() {
+		query = query.Where(squirrel.GtOrEq{"created_at": opts.From})
+	}
+	if !opts.To.IsZero() {
+		query = query.Where(squirrel.Lt{"created_at": opts.To})
+	}
+	sql, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting audit log error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.AuditRecord, 0)
+	for rows.Next() {
+		var rec models.AuditRecord
+		var before, after []byte
+		err = rows.Scan(&rec.ID, &rec.SubID, &rec.UID, &rec.Actor, &rec.RequestID, &rec.Op, &before, &after, &rec.CreatedAt)
+		if err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		rec.Before, rec.After = before, after
+		result = append(result, &rec)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading audit log error: " + err.Error())
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/auth"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAudit(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	reqID := "req"
+	now := time.Now()
+	columns := []string{"id", "sub_id", "uid", "actor", "request_id", "op", "before", "after", "created_at"}
+	t.Run("filtered", func(t *testing.T) {
+		from := now.Add(-time.Hour)
+		pool.ExpectQuery(regexp.QuoteMeta(`SELECT id, sub_id, uid, actor, request_id, op, before, after, created_at FROM audit_log WHERE actor = $1 AND op = $2 AND sub_id = $3 AND created_at >= $4 ORDER BY id DESC LIMIT 10 OFFSET 0`)).
+			WithArgs("apikey:1", models.OpUpdate, 1, from).
+			WillReturnRows(pgxmock.NewRows(columns).
+				AddRow(int64(2), 1, uid, "apikey:1", &reqID, models.OpUpdate, []byte(`{"price":300}`), []byte(`{"price":400}`), now))
+		result, err := cli.ListAudit(context.Background(), &models.AuditOpts{
+			SubID: 1,
+			Actor: "apikey:1",
+			Op:    models.OpUpdate,
+			From:  from,
+			Limit: 10,
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, result, 1) {
+			assert.Equal(t, "req", *result[0].RequestID)
+			assert.JSONEq(t, `{"price":400}`, string(result[0].After))
+		}
+	})
+	t.Run("scoped to principal's user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: uid})
+		pool.ExpectQuery(regexp.QuoteMeta(`SELECT id, sub_id, uid, actor, request_id, op, before, after, created_at FROM audit_log WHERE uid = $1 ORDER BY id DESC OFFSET 0`)).
+			WithArgs(uid.String()).
+			WillReturnRows(pgxmock.NewRows(columns))
+		result, err := cli.ListAudit(ctx, &models.AuditOpts{})
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT (.+) FROM audit_log").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.ListAudit(context.Background(), &models.AuditOpts{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,121 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Executes batch of operations in a single transaction.
+// By default batch is all-or-nothing: updates and deletes are executed
+// one by one, creates are inserted at once with COPY, the first failed
+// operation rolls back the whole batch and ErrBatchRejected is returned
+// with results describing the failure. In partial mode every operation
+// runs in its own savepoint, so failed ones don't affect the others.
+// Operations of non-admin principal are restricted to its user
+func (cli *Client) Batch(ctx context.Context, ops []*models.BatchOp, partial bool) ([]*models.BatchResult, error) {
+	results := make([]*models.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = &models.BatchResult{
+			Index:  i,
+			Op:     op.Op,
+			ID:     op.ID,
+			Status: models.BatchOK,
+		}
+	}
+	err := cli.inTx(ctx, cli.timeouts.Load().aggregate, func(ctx context.Context, tx pgx.Tx) error {
+		if partial {
+			return batchPartial(ctx, tx, ops, results)
+		}
+		return batchAtomic(ctx, tx, ops, results)
+	})
+	if err != nil {
+		if errors.Is(err, errvalues.ErrBatchRejected) {
+			return results, err
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+func batchAtomic(ctx context.Context, tx pgx.Tx, ops []*models.BatchOp, results []*models.BatchResult) error {
+	var created [][]any
+	var createdResults []*models.BatchResult
+	for i, op := range ops {
+		if op.Op == models.BatchCreate {
+			values, err := copyValues(ctx, op.Sub)
+			if err != nil {
+				return err
+			}
+			created = append(created, values)
+			createdResults = append(createdResults, results[i])
+			continue
+		}
+		if err := execBatchOp(ctx, tx, op); err != nil {
+			if !errors.Is(err, errvalues.ErrNoSuchRow) {
+				return err
+			}
+			for _, res := range results {
+				res.Status = models.BatchRolledBack
+			}
+			results[i].Status = models.BatchError
+			results[i].Error = err.Error()
+			return errvalues.ErrBatchRejected
+		}
+	}
+	if len(created) == 0 {
+		return nil
+	}
+	ids, err := copySubs(ctx, tx, pgx.CopyFromRows(created))
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		createdResults[i].ID = id
+	}
+	return nil
+}
+
+func batchPartial(ctx context.Context, tx pgx.Tx, ops []*models.BatchOp, results []*models.BatchResult) error {
+	for i, op := range ops {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return errors.New("creating savepoint error: " + err.Error())
+		}
+		if err = execBatchOp(ctx, sp, op); err != nil {
+			if rbErr := sp.Rollback(ctx); rbErr != nil {
+				return errors.New("rolling back to savepoint error: " + rbErr.Error())
+			}
+			results[i].Status = models.BatchError
+			results[i].Error = errvalues.ErrInternal.Error()
+			if errors.Is(err, errvalues.ErrNoSuchRow) {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		if err = sp.Commit(ctx); err != nil {
+			return errors.New("releasing savepoint error: " + err.Error())
+		}
+		if op.Op == models.BatchCreate {
+			results[i].ID = op.Sub.ID
+		}
+	}
+	return nil
+}
+
+// Executes single batch operation, returns ErrNoSuchRow if updated
+// or deleted row doesn't exist (or belongs to another user)
+func execBatchOp(ctx context.Context, tx pgx.Tx, op *models.BatchOp) error {
+	switch op.Op {
+	case models.BatchCreate:
+		return insertSub(ctx, tx, op.Sub)
+	case models.BatchUpdate:
+		return updateSub(ctx, tx, op.ID, op.Sub)
+	case models.BatchDelete:
+		return deleteSub(ctx, tx, op.ID)
+	}
+	return errors.New("unknown batch operation: " + op.Op)
+}
@@ -0,0 +1,130 @@
+package subs_test
+
+import (
+	"context"
+	"regexp"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	sub := &models.Subscription{
+		Name:  "yandex",
+		Price: models.NewMoney(400, 0),
+		UID:   uuid.New(),
+		Start: start,
+	}
+	old := &models.Subscription{ID: 2, Name: "yandex", Price: models.NewMoney(300, 0), UID: sub.UID, Start: start}
+	update := regexp.QuoteMeta(`UPDATE subscriptions SET uid = $1, name = $2, cost = $3, created_at = $4, expires = $5, billing_period = $6, currency = $7, trial_end = $8, trial_price = $9 WHERE id = $10;`)
+	insert := regexp.QuoteMeta(`INSERT INTO subscriptions (uid, name, cost, created_at, expires, billing_period, currency, trial_end, trial_price) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`)
+	columns := []string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "snapshot"}
+	t.Run("atomic successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(2).
+			WillReturnRows(lockedRow(old))
+		pool.ExpectExec(update).
+			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, models.BillingMonthly, models.BaseCurrency, sub.TrialEnd, sub.TrialPrice, 2).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		pool.ExpectQuery(monthQuery).
+			WithArgs(sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"month"}).AddRow(start))
+		pool.ExpectExec(priceQuery).
+			WithArgs(2, start, sub.Price).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(2, sub.UID, "system", pgxmock.AnyArg(), models.OpUpdate, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec("CREATE TEMP TABLE subs_staging").
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		pool.ExpectCopyFrom(pgx.Identifier{"subs_staging"}, columns).
+			WillReturnResult(2)
+		pool.ExpectQuery("UPDATE subs_staging SET id").
+			WillReturnRows(pgxmock.NewRows([]string{"pos", "id"}).AddRow(2, 11).AddRow(1, 10))
+		pool.ExpectExec("INSERT INTO subscriptions").
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+		pool.ExpectExec("INSERT INTO sub_prices").
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+		pool.ExpectExec(`INSERT INTO audit_log (.+) INSERT INTO webhook_outbox`).
+			WithArgs("system", pgxmock.AnyArg(), models.OpCreate).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+		pool.ExpectCommit()
+		results, err := cli.Batch(context.Background(), []*models.BatchOp{
+			{Op: models.BatchCreate, Sub: sub},
+			{Op: models.BatchUpdate, ID: 2, Sub: sub},
+			{Op: models.BatchCreate, Sub: sub},
+		}, false)
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		for _, res := range results {
+			assert.Equal(t, models.BatchOK, res.Status)
+		}
+		assert.Equal(t, 10, results[0].ID)
+		assert.Equal(t, 2, results[1].ID)
+		assert.Equal(t, 11, results[2].ID)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("atomic rolled back", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(5).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		results, err := cli.Batch(context.Background(), []*models.BatchOp{
+			{Op: models.BatchCreate, Sub: sub},
+			{Op: models.BatchDelete, ID: 5},
+		}, false)
+		assert.ErrorIs(t, err, errvalues.ErrBatchRejected)
+		assert.Equal(t, models.BatchRolledBack, results[0].Status)
+		assert.Equal(t, models.BatchError, results[1].Status)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("partial", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectBegin()
+		pool.ExpectQuery(insert).
+			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, models.BillingMonthly, models.BaseCurrency, sub.TrialEnd, sub.TrialPrice).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(7))
+		pool.ExpectExec(priceQuery).
+			WithArgs(7, sub.Start, sub.Price).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(7, sub.UID, "system", pgxmock.AnyArg(), models.OpCreate, []byte(nil), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(5).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		pool.ExpectCommit()
+		results, err := cli.Batch(context.Background(), []*models.BatchOp{
+			{Op: models.BatchCreate, Sub: sub},
+			{Op: models.BatchDelete, ID: 5},
+		}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, models.BatchOK, results[0].Status)
+		assert.Equal(t, 7, results[0].ID)
+		assert.Equal(t, models.BatchError, results[1].Status)
+		assert.Equal(t, errvalues.ErrNoSuchRow.Error(), results[1].Error)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,108 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/models"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel notified by audit log trigger with user of every change
+const changesChannel = "sub_changes"
+
+// Changes of transactions which are finished together with all older ones.
+// Newer transactions can't write rows ordered before them anymore
+const streamable = "txid < pg_snapshot_xmin(pg_current_snapshot())"
+
+// Returns id of the last change which can be streamed, changes
+// following it are returned by Changes. Returns 0 if there are none
+func (cli *Client) LastChangeID(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	var id int64
+	err := cli.conn.QueryRow(ctx, `SELECT COALESCE((SELECT id FROM audit_log WHERE `+streamable+`
+ORDER BY txid DESC, id DESC LIMIT 1), 0);`).Scan(&id)
+	if err != nil {
+		return 0, errors.New("getting last change error: " + err.Error())
+	}
+	return id, nil
+}
+
+// Returns up to limit changes matching filter which follow change with
+// id after, in the order they are to be streamed. If there is no such
+// change, changes with greater ids are returned. Non-admin principal
+// gets changes of its user's subscriptions only
+func (cli *Client) Changes(ctx context.Context, filter map[string]interface{}, after int64, limit int) ([]*models.AuditRecord, error) {
+	query := squirrel.Select("a.id, a.sub_id, a.uid, a.actor, a.request_id, a.op, a.before, a.after, a.created_at").
+		From("audit_log a").
+		LeftJoin("audit_log c ON c.id = ?", after).
+		Where("a."+streamable).
+		Where("((a.txid, a.id) > (c.txid, c.id) OR c.id IS NULL AND a.id > ?)", after).
+		OrderBy("a.txid, a.id").
+		Limit(uint64(limit))
+	for k, v := range scopeFilter(ctx, filter) {
+		query = query.Where(squirrel.Eq{"a." + k: v})
+	}
+	sql, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting changes error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.AuditRecord, 0)
+	for rows.Next() {
+		var rec models.AuditRecord
+		var before, after []byte
+		err = rows.Scan(&rec.ID, &rec.SubID, &rec.UID, &rec.Actor, &rec.RequestID, &rec.Op, &before, &after, &rec.CreatedAt)
+		if err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		rec.Before, rec.After = before, after
+		result = append(result, &rec)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading changes error: " + err.Error())
+	}
+	return result, nil
+}
+
+// Listens for committed changes until ctx is done or connection fails,
+// notify is called with user of every change. Since changes could be
+// missed before listening started, notify is called with uuid.Nil then
+func (cli *Client) ListenChanges(ctx context.Context, notify func(uid uuid.UUID)) error {
+	pool, ok := cli.conn.(*pgxpool.Pool)
+	if !ok {
+		return errors.New("listening for changes requires connection pool")
+	}
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return errors.New("acquiring connection error: " + err.Error())
+	}
+	// Connection is closed instead of release, so pool doesn't reuse it listening
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+	if _, err = conn.Exec(ctx, "LISTEN "+changesChannel); err != nil {
+		return errors.New("listening for changes error: " + err.Error())
+	}
+	notify(uuid.Nil)
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.New("waiting for changes error: " + err.Error())
+		}
+		if uid, err := uuid.Parse(n.Payload); err == nil {
+			notify(uid)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/auth"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastChangeID(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	query := regexp.QuoteMeta(`SELECT COALESCE((SELECT id FROM audit_log WHERE txid < pg_snapshot_xmin(pg_current_snapshot())
+ORDER BY txid DESC, id DESC LIMIT 1), 0);`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(42)))
+		id, err := cli.LastChangeID(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), id)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WillReturnError(errors.New("db error"))
+		_, err := cli.LastChangeID(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	now := time.Now()
+	columns := []string{"id", "sub_id", "uid", "actor", "request_id", "op", "before", "after", "created_at"}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(regexp.QuoteMeta(`SELECT a.id, a.sub_id, a.uid, a.actor, a.request_id, a.op, a.before, a.after, a.created_at FROM audit_log a LEFT JOIN audit_log c ON c.id = $1 WHERE a.txid < pg_snapshot_xmin(pg_current_snapshot()) AND ((a.txid, a.id) > (c.txid, c.id) OR c.id IS NULL AND a.id > $2) ORDER BY a.txid, a.id LIMIT 500`)).
+			WithArgs(int64(41), int64(41)).
+			WillReturnRows(pgxmock.NewRows(columns).
+				AddRow(int64(42), 1, uid, "apikey:1", nil, models.OpUpdate, []byte(`{"id":1}`), []byte(`{"id":1}`), now))
+		result, err := cli.Changes(context.Background(), nil, 41, 500)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.AuditRecord{
+			{ID: 42, SubID: 1, UID: uid, Actor: "apikey:1", Op: models.OpUpdate, Before: []byte(`{"id":1}`), After: []byte(`{"id":1}`), CreatedAt: now},
+		}, result)
+	})
+	t.Run("scoped to principal's user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: uid})
+		pool.ExpectQuery(`SELECT a.id(.+)AND a.uid = \$3 ORDER BY a.txid, a.id LIMIT 500`).
+			WithArgs(int64(0), int64(0), uid.String()).
+			WillReturnRows(pgxmock.NewRows(columns))
+		result, err := cli.Changes(ctx, map[string]interface{}{"uid": uuid.New()}, 0, 500)
+		assert.NoError(t, err)
+		assert.Empty(t, result)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT a.id").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.Changes(context.Background(), nil, 0, 500)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,78 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reserves idempotency key for the request with provided hash for lease,
+// which must outlast the request. If the key is free or its previous
+// record has expired, returns reserved = true. Otherwise returns existing
+// record, which may belong to the request still in progress. Reservation
+// left by the request which didn't finish, e.g. because the process
+// died, is taken over once lease expires
+func (cli *Client) ReserveIdempotencyKey(ctx context.Context, scope, key, hash string, lease time.Duration) (*models.IdempotencyRecord, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `INSERT INTO idempotency_keys (scope, key, request_hash, expires_at) VALUES
+($1, $2, $3, now() + make_interval(secs => $4))
+ON CONFLICT (scope, key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status = NULL, response = NULL,
+created_at = now(), expires_at = EXCLUDED.expires_at WHERE idempotency_keys.expires_at < now();`, scope, key, hash, lease.Seconds())
+	if err != nil {
+		return nil, false, errors.New("reserving idempotency key error: " + err.Error())
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+	var record models.IdempotencyRecord
+	err = cli.conn.QueryRow(ctx, `SELECT request_hash, status, response FROM idempotency_keys WHERE scope = $1 AND key = $2;`, scope, key).
+		Scan(&record.RequestHash, &record.Status, &record.Response)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, errvalues.ErrNoSuchRow
+		}
+		return nil, false, errors.New("getting idempotency record error: " + err.Error())
+	}
+	return &record, false, nil
+}
+
+// Stores response of the request which reserved the key,
+// it is replayed until ttl passes
+func (cli *Client) SaveIdempotentResponse(ctx context.Context, scope, key string, status int, response []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	_, err := cli.conn.Exec(ctx, `UPDATE idempotency_keys SET status = $3, response = $4, expires_at = now() + make_interval(secs => $5)
+WHERE scope = $1 AND key = $2;`,
+		scope, key, status, response, ttl.Seconds())
+	if err != nil {
+		return errors.New("saving idempotent response error: " + err.Error())
+	}
+	return nil
+}
+
+// Frees reserved key, so the request can be retried
+func (cli *Client) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	_, err := cli.conn.Exec(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status IS NULL;`, scope, key)
+	if err != nil {
+		return errors.New("releasing idempotency key error: " + err.Error())
+	}
+	return nil
+}
+
+// Deletes expired idempotency keys, returns number of deleted ones
+func (cli *Client) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now();`)
+	if err != nil {
+		return 0, errors.New("purging idempotency keys error: " + err.Error())
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,96 @@
+package subs_test
+
+import (
+	"context"
+	"regexp"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	insert := `INSERT INTO idempotency_keys`
+	selectQuery := regexp.QuoteMeta(`SELECT request_hash, status, response FROM idempotency_keys WHERE scope = $1 AND key = $2;`)
+	t.Run("reserved", func(t *testing.T) {
+		pool.ExpectExec(insert).
+			WithArgs("apikey:1", "key", "hash", 3600.0).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		record, reserved, err := cli.ReserveIdempotencyKey(context.Background(), "apikey:1", "key", "hash", time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+		assert.Nil(t, record)
+	})
+	t.Run("completed before", func(t *testing.T) {
+		status := 200
+		pool.ExpectExec(insert).
+			WithArgs("apikey:1", "key", "hash", 3600.0).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+		pool.ExpectQuery(selectQuery).
+			WithArgs("apikey:1", "key").
+			WillReturnRows(pgxmock.NewRows([]string{"request_hash", "status", "response"}).
+				AddRow("hash", &status, []byte(`{"message":"ok"}`)))
+		record, reserved, err := cli.ReserveIdempotencyKey(context.Background(), "apikey:1", "key", "hash", time.Hour)
+		assert.NoError(t, err)
+		assert.False(t, reserved)
+		assert.Equal(t, &models.IdempotencyRecord{RequestHash: "hash", Status: &status, Response: []byte(`{"message":"ok"}`)}, record)
+	})
+	t.Run("released concurrently", func(t *testing.T) {
+		pool.ExpectExec(insert).
+			WithArgs("apikey:1", "key", "hash", 3600.0).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+		pool.ExpectQuery(selectQuery).
+			WithArgs("apikey:1", "key").
+			WillReturnError(pgx.ErrNoRows)
+		_, _, err := cli.ReserveIdempotencyKey(context.Background(), "apikey:1", "key", "hash", time.Hour)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+	})
+}
+
+func TestSaveIdempotentResponse(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	pool.ExpectExec(regexp.QuoteMeta(`UPDATE idempotency_keys SET status = $3, response = $4, expires_at = now() + make_interval(secs => $5)`)).
+		WithArgs("apikey:1", "key", 200, []byte(`{"message":"ok"}`), 86400.0).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	assert.NoError(t, cli.SaveIdempotentResponse(context.Background(), "apikey:1", "key", 200, []byte(`{"message":"ok"}`), 24*time.Hour))
+	assert.NoError(t, pool.ExpectationsWereMet())
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	pool.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_keys WHERE expires_at < now();`)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	n, err := cli.PurgeIdempotencyKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+}
@@ -0,0 +1,58 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testcase/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Adapts sequence of subscriptions to pgx.CopyFromSource,
+// non-nil error from the sequence aborts the copy
+type copySource struct {
+	ctx  context.Context
+	next func() (*models.Subscription, error, bool)
+	sub  *models.Subscription
+	err  error
+}
+
+func (cs *copySource) Next() bool {
+	sub, err, ok := cs.next()
+	if !ok {
+		return false
+	}
+	if err != nil {
+		cs.err = err
+		return false
+	}
+	cs.sub = sub
+	return true
+}
+
+func (cs *copySource) Values() ([]any, error) {
+	return copyValues(cs.ctx, cs.sub)
+}
+
+func (cs *copySource) Err() error {
+	return cs.err
+}
+
+// Inserts all subscriptions from seq with COPY, so either all of them
+// are inserted or none. For non-admin principal subscriptions are
+// always created for the principal's user. Returns number of inserted rows
+func (cli *Client) ImportSubs(ctx context.Context, seq iter.Seq2[*models.Subscription, error]) (int64, error) {
+	next, stop := iter.Pull2(seq)
+	defer stop()
+	var n int64
+	err := cli.inTx(ctx, cli.timeouts.Load().imports, func(ctx context.Context, tx pgx.Tx) error {
+		ids, err := copySubs(ctx, tx, &copySource{ctx: ctx, next: next})
+		n = int64(len(ids))
+		return err
+	})
+	if err != nil {
+		return 0, errors.New("importing subs error: " + err.Error())
+	}
+	return n, nil
+}
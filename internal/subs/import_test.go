@@ -0,0 +1,61 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportSubs(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	seq := func(yield func(*models.Subscription, error) bool) {
+		yield(&models.Subscription{Name: "yandex", Price: models.NewMoney(400, 0)}, nil)
+	}
+	columns := []string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "snapshot"}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectExec("CREATE TEMP TABLE subs_staging").
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		pool.ExpectCopyFrom(pgx.Identifier{"subs_staging"}, columns).
+			WillReturnResult(1)
+		pool.ExpectQuery("UPDATE subs_staging SET id").
+			WillReturnRows(pgxmock.NewRows([]string{"pos", "id"}).AddRow(1, 10))
+		pool.ExpectExec("INSERT INTO subscriptions").
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec("INSERT INTO sub_prices").
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(`INSERT INTO audit_log (.+) INSERT INTO webhook_outbox`).
+			WithArgs("system", pgxmock.AnyArg(), models.OpCreate).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		n, err := cli.ImportSubs(context.Background(), seq)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectExec("CREATE TEMP TABLE subs_staging").
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		pool.ExpectCopyFrom(pgx.Identifier{"subs_staging"}, columns).
+			WillReturnError(errors.New("db error"))
+		pool.ExpectRollback()
+		_, err := cli.ImportSubs(context.Background(), seq)
+		assert.Error(t, err)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,97 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Status of subscriptions row on the current day in time zone of its
+// user, see models statuses for precedence. Paused while it has a pause
+// without end
+const subStatus = `(SELECT CASE WHEN subscriptions.expires <= t.today THEN 'expired'
+WHEN subscriptions.cancelled_at IS NOT NULL THEN 'cancelled'
+WHEN EXISTS (SELECT 1 FROM sub_pauses WHERE sub_pauses.sub_id = subscriptions.id AND sub_pauses.ends IS NULL) THEN 'paused'
+WHEN subscriptions.created_at > t.today THEN 'upcoming'
+WHEN subscriptions.trial_end > t.today THEN 'trial'
+ELSE 'active' END
+FROM (SELECT ` + subToday + ` AS today) t)`
+
+// Current day in time zone of the user of subscriptions row
+const subToday = `(now() AT TIME ZONE COALESCE((SELECT time_zone FROM user_settings WHERE uid = subscriptions.uid), '` +
+	models.DefaultTimeZone + `'))::date`
+
+// Pauses subscription with provided id from the current day in time
+// zone of its user and returns the pause. If there is no such subscription
+// (or it belongs to another user, or is deleted) returns ErrNoSuchRow,
+// if it is paused already returns ErrAlreadyPaused, if it is cancelled
+// or expired returns ErrCancelled
+func (cli *Client) PauseSub(ctx context.Context, id int) (*models.Pause, error) {
+	var pause *models.Pause
+	err := cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		pause, err = pauseSub(ctx, tx, id)
+		return err
+	})
+	return pause, err
+}
+
+// Resumes paused subscription with provided id from the current day in
+// time zone of its user and returns the closed pause. If there is no such
+// subscription (or it belongs to another user, or is deleted) returns
+// ErrNoSuchRow, if it isn't paused returns ErrNotPaused
+func (cli *Client) ResumeSub(ctx context.Context, id int) (*models.Pause, error) {
+	var pause *models.Pause
+	err := cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		pause, err = resumeSub(ctx, tx, id)
+		return err
+	})
+	return pause, err
+}
+
+// Cancels subscription with provided id at the end of its billing period
+// containing the current day in time zone of its user, so the period
+// which is already paid isn't cut. Earlier expiration date is kept, open
+// pause is closed. Returns the cancelled subscription. If there is no
+// such subscription (or it belongs to another user, or is deleted)
+// returns ErrNoSuchRow, if it is cancelled or expired returns ErrCancelled
+func (cli *Client) CancelSub(ctx context.Context, id int) (*models.Subscription, error) {
+	var sub *models.Subscription
+	err := cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		sub, err = cancelSub(ctx, tx, id)
+		return err
+	})
+	return sub, err
+}
+
+// Returns pauses of the subscription with provided id ordered by start.
+// If there is no such subscription (or it belongs to another user, or is
+// deleted) returns ErrNoSuchRow
+func (cli *Client) ListPauses(ctx context.Context, id int) ([]*models.Pause, error) {
+	if _, err := cli.GetSub(ctx, id); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, `SELECT starts, ends FROM sub_pauses WHERE sub_id = $1 ORDER BY starts, id;`, id)
+	if err != nil {
+		return nil, errors.New("getting sub pauses error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.Pause, 0)
+	for rows.Next() {
+		var p models.Pause
+		if err = rows.Scan(&p.Start, &p.End); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading sub pauses error: " + err.Error())
+	}
+	return result, nil
+}
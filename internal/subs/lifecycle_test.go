@@ -0,0 +1,254 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseSub(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	today, _ := models.ParseDate("2025-09-17")
+	sub := &models.Subscription{ID: 1, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start, Status: models.StatusActive}
+	query := regexp.QuoteMeta(`INSERT INTO sub_pauses (sub_id, starts) VALUES ($1, $2);`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(sub))
+		pool.ExpectQuery(dayQuery).
+			WithArgs(sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"day"}).AddRow(today))
+		pool.ExpectExec(query).
+			WithArgs(1, today).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, sub.UID, "system", pgxmock.AnyArg(), models.OpPause, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		pause, err := cli.PauseSub(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Pause{Start: today}, pause)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("already paused", func(t *testing.T) {
+		paused := *sub
+		paused.Status = models.StatusPaused
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(&paused))
+		pool.ExpectRollback()
+		_, err := cli.PauseSub(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrAlreadyPaused)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("No row with such id", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		_, err := cli.PauseSub(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestResumeSub(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	paused, _ := models.ParseDate("2025-09-17")
+	today, _ := models.ParseDate("2025-11-02")
+	sub := &models.Subscription{ID: 1, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start, Status: models.StatusPaused}
+	query := regexp.QuoteMeta(`UPDATE sub_pauses SET ends = GREATEST(starts, $2) WHERE sub_id = $1 AND ends IS NULL RETURNING starts, ends;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(sub))
+		pool.ExpectQuery(dayQuery).
+			WithArgs(sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"day"}).AddRow(today))
+		pool.ExpectQuery(query).
+			WithArgs(1, today).
+			WillReturnRows(pgxmock.NewRows([]string{"starts", "ends"}).AddRow(paused, &today))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, sub.UID, "system", pgxmock.AnyArg(), models.OpResume, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		pause, err := cli.ResumeSub(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Pause{Start: paused, End: &today}, pause)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("not paused", func(t *testing.T) {
+		active := *sub
+		active.Status = models.StatusActive
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(&active))
+		pool.ExpectRollback()
+		_, err := cli.ResumeSub(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrNotPaused)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestListPauses(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	paused, _ := models.ParseDate("2025-09-17")
+	resumed, _ := models.ParseDate("2025-11-02")
+	getQuery := regexp.QuoteMeta(`SELECT uid, name, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, ` + todayColumn + ` FROM subscriptions ` + priceJoin + ` WHERE id = $1 AND deleted_at IS NULL;`)
+	query := regexp.QuoteMeta(`SELECT starts, ends FROM sub_pauses WHERE sub_id = $1 ORDER BY starts, id;`)
+	subRow := func() *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+			AddRow(uuid.New(), "yandex", models.NewMoney(400, 0), start, nil, models.BillingMonthly, models.BaseCurrency, nil, nil, models.StatusPaused, start)
+	}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnRows(subRow())
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"starts", "ends"}).
+				AddRow(paused, &resumed).
+				AddRow(resumed.AddDate(0, 1, 0), nil))
+		result, err := cli.ListPauses(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Pause{
+			{Start: paused, End: &resumed},
+			{Start: resumed.AddDate(0, 1, 0)},
+		}, result)
+	})
+	t.Run("No row with such id", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		_, err := cli.ListPauses(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnRows(subRow())
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnError(errors.New("db error"))
+		_, err := cli.ListPauses(context.Background(), 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestCancelSub(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := models.ParseDate("2025-07-17")
+	today, _ := models.ParseDate("2025-09-20")
+	periodEnd, _ := models.ParseDate("2025-10-17")
+	sub := &models.Subscription{ID: 1, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start, BillingPeriod: models.BillingMonthly, Status: models.StatusActive}
+	query := regexp.QuoteMeta(`UPDATE subscriptions SET expires = $1, cancelled_at = $2 WHERE id = $3;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(sub))
+		pool.ExpectQuery(dayQuery).
+			WithArgs(sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"day"}).AddRow(today))
+		pool.ExpectExec(query).
+			WithArgs(&periodEnd, today, 1).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, sub.UID, "system", pgxmock.AnyArg(), models.OpCancel, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		result, err := cli.CancelSub(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &periodEnd, result.Expires)
+		assert.Equal(t, models.StatusCancelled, result.Status)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("paused with earlier expiration", func(t *testing.T) {
+		expires, _ := models.ParseDate("2025-10-01")
+		paused := *sub
+		paused.Status, paused.Expires = models.StatusPaused, &expires
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(&paused))
+		pool.ExpectQuery(dayQuery).
+			WithArgs(sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"day"}).AddRow(today))
+		pool.ExpectExec(query).
+			WithArgs(&expires, today, 1).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		pool.ExpectExec(regexp.QuoteMeta(`UPDATE sub_pauses SET ends = GREATEST(starts, $2) WHERE sub_id = $1 AND ends IS NULL;`)).
+			WithArgs(1, today).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, sub.UID, "system", pgxmock.AnyArg(), models.OpCancel, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		result, err := cli.CancelSub(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &expires, result.Expires)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("already cancelled", func(t *testing.T) {
+		cancelled := *sub
+		cancelled.Status = models.StatusCancelled
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(&cancelled))
+		pool.ExpectRollback()
+		_, err := cli.CancelSub(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrCancelled)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
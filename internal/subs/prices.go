@@ -0,0 +1,85 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Price of subscriptions row effective on the current day of its user,
+// read from subPriceJoin. Rows without price history yet fall back to
+// cost they were written with
+const (
+	subPrice     = `COALESCE(p.current_cost, subscriptions.cost)`
+	subPriceJoin = `LEFT JOIN LATERAL (SELECT cost AS current_cost FROM sub_prices
+WHERE sub_id = subscriptions.id AND effective_from <= ` + subToday + `
+ORDER BY effective_from DESC LIMIT 1) p ON true`
+)
+
+// Writes price of the subscription effective from the month,
+// replacing the one already set for the same month
+func setPrice(ctx context.Context, tx pgx.Tx, id int, p *models.PriceChange) error {
+	_, err := tx.Exec(ctx, `INSERT INTO sub_prices (sub_id, effective_from, cost) VALUES ($1, $2, $3)
+ON CONFLICT (sub_id, effective_from) DO UPDATE SET cost = EXCLUDED.cost, created_at = now();`,
+		id, p.EffectiveFrom, p.Price)
+	if err != nil {
+		return errors.New("setting sub price error: " + err.Error())
+	}
+	return nil
+}
+
+// Schedules price change of the subscription with provided id, the price is
+// used in spend calculations and read as the subscription price from
+// p.EffectiveFrom month until the next change.
+// The change is recorded as update with p in the after snapshot.
+// If there is no such subscription (or it belongs to another user, or is
+// deleted) returns ErrNoSuchRow. If the price has more decimal places than
+// currency of the subscription allows returns ErrInvalidPrice
+func (cli *Client) SchedulePrice(ctx context.Context, id int, p *models.PriceChange) error {
+	return cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		sub, err := lockSub(ctx, tx, id, false)
+		if err != nil {
+			return err
+		}
+		if p.Price, err = p.Price.ForCurrency(sub.Currency); err != nil {
+			return errvalues.ErrInvalidPrice
+		}
+		if err = setPrice(ctx, tx, id, p); err != nil {
+			return err
+		}
+		after := *sub
+		after.PriceChange = p
+		return recordChange(ctx, tx, models.OpUpdate, sub, &after)
+	})
+}
+
+// Returns price history of the subscription with provided id ordered by
+// effective month, including scheduled changes. If there is no such
+// subscription (or it belongs to another user, or is deleted) returns ErrNoSuchRow
+func (cli *Client) ListPrices(ctx context.Context, id int) ([]*models.PriceChange, error) {
+	if _, err := cli.GetSub(ctx, id); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, `SELECT cost, effective_from FROM sub_prices WHERE sub_id = $1 ORDER BY effective_from;`, id)
+	if err != nil {
+		return nil, errors.New("getting sub prices error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.PriceChange, 0)
+	for rows.Next() {
+		var p models.PriceChange
+		if err = rows.Scan(&p.Price, &p.EffectiveFrom); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading sub prices error: " + err.Error())
+	}
+	return result, nil
+}
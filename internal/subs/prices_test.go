@@ -0,0 +1,130 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// Matches audit snapshot containing fragment
+type snapshotWith string
+
+func (f snapshotWith) Match(v any) bool {
+	data, ok := v.([]byte)
+	return ok && strings.Contains(string(data), string(f))
+}
+
+func TestSchedulePrice(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	effective, _ := time.Parse("01-2006", "09-2025")
+	sub := &models.Subscription{ID: 1, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start, Currency: models.BaseCurrency}
+	change := &models.PriceChange{Price: models.NewMoney(500, 0), EffectiveFrom: effective}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(sub))
+		pool.ExpectExec(priceQuery).
+			WithArgs(1, effective, models.NewMoney(50000, 2)).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, sub.UID, "system", pgxmock.AnyArg(), models.OpUpdate, pgxmock.AnyArg(),
+				snapshotWith(`"price_change":{"price":"500.00","effective_from":"09-2025"}`)).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		assert.NoError(t, cli.SchedulePrice(context.Background(), 1, change))
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("No row with such id", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		assert.ErrorIs(t, cli.SchedulePrice(context.Background(), 1, change), errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("too many decimal places", func(t *testing.T) {
+		yen := *sub
+		yen.Currency = "JPY"
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(&yen))
+		pool.ExpectRollback()
+		err := cli.SchedulePrice(context.Background(), 1, &models.PriceChange{Price: models.MustParseMoney("499.99"), EffectiveFrom: effective})
+		assert.ErrorIs(t, err, errvalues.ErrInvalidPrice)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestListPrices(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	effective, _ := time.Parse("01-2006", "09-2025")
+	getQuery := regexp.QuoteMeta(`SELECT uid, name, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, ` + todayColumn + ` FROM subscriptions ` + priceJoin + ` WHERE id = $1 AND deleted_at IS NULL;`)
+	query := regexp.QuoteMeta(`SELECT cost, effective_from FROM sub_prices WHERE sub_id = $1 ORDER BY effective_from;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+				AddRow(uuid.New(), "yandex", models.NewMoney(400, 0), start, nil, models.BillingMonthly, models.BaseCurrency, nil, nil, models.StatusActive, start))
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"cost", "effective_from"}).
+				AddRow(models.NewMoney(400, 0), start).
+				AddRow(models.NewMoney(500, 0), effective))
+		result, err := cli.ListPrices(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.PriceChange{
+			{Price: models.NewMoney(400, 0), EffectiveFrom: start},
+			{Price: models.NewMoney(500, 0), EffectiveFrom: effective},
+		}, result)
+	})
+	t.Run("No row with such id", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		_, err := cli.ListPrices(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+				AddRow(uuid.New(), "yandex", models.NewMoney(400, 0), start, nil, models.BillingMonthly, models.BaseCurrency, nil, nil, models.StatusActive, start))
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnError(errors.New("db error"))
+		_, err := cli.ListPrices(context.Background(), 1)
+		assert.Error(t, err)
+	})
+}
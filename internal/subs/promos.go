@@ -0,0 +1,65 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Adds promo discount to the subscription with provided id and sets its ID.
+// The promo is recorded as update with p in the after snapshot.
+// If there is no such subscription (or it belongs to another user, or is
+// deleted) returns ErrNoSuchRow. If fixed discount has more decimal places
+// than currency of the subscription allows returns ErrInvalidPrice
+func (cli *Client) AddPromo(ctx context.Context, id int, p *models.Promo) error {
+	return cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		sub, err := lockSub(ctx, tx, id, false)
+		if err != nil {
+			return err
+		}
+		if p.Kind == models.PromoFixed {
+			if p.Value, err = p.Value.ForCurrency(sub.Currency); err != nil {
+				return errvalues.ErrInvalidPrice
+			}
+		}
+		err = tx.QueryRow(ctx, `INSERT INTO sub_promos (sub_id, kind, value, starts, months) VALUES
+($1, $2, $3, $4, $5) RETURNING id;`, id, p.Kind, p.Value, monthOf(p.Start), p.Months).Scan(&p.ID)
+		if err != nil {
+			return errors.New("adding sub promo error: " + err.Error())
+		}
+		after := *sub
+		after.Promo = p
+		return recordChange(ctx, tx, models.OpUpdate, sub, &after)
+	})
+}
+
+// Returns promo discounts of the subscription with provided id ordered by
+// start month. If there is no such subscription (or it belongs to another
+// user, or is deleted) returns ErrNoSuchRow
+func (cli *Client) ListPromos(ctx context.Context, id int) ([]*models.Promo, error) {
+	if _, err := cli.GetSub(ctx, id); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, `SELECT id, kind, value, starts, months FROM sub_promos WHERE sub_id = $1 ORDER BY starts, id;`, id)
+	if err != nil {
+		return nil, errors.New("getting sub promos error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.Promo, 0)
+	for rows.Next() {
+		var p models.Promo
+		if err = rows.Scan(&p.ID, &p.Kind, &p.Value, &p.Start, &p.Months); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading sub promos error: " + err.Error())
+	}
+	return result, nil
+}
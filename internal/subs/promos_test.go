@@ -0,0 +1,123 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddPromo(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	sub := &models.Subscription{ID: 1, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start, Currency: models.BaseCurrency}
+	query := regexp.QuoteMeta(`INSERT INTO sub_promos (sub_id, kind, value, starts, months) VALUES
+($1, $2, $3, $4, $5) RETURNING id;`)
+	t.Run("successful", func(t *testing.T) {
+		promo := &models.Promo{Kind: models.PromoFixed, Value: models.NewMoney(100, 0), Start: start, Months: 3}
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(sub))
+		pool.ExpectQuery(query).
+			WithArgs(1, models.PromoFixed, models.NewMoney(10000, 2), start, 3).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(4))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, sub.UID, "system", pgxmock.AnyArg(), models.OpUpdate, pgxmock.AnyArg(),
+				snapshotWith(`"promo":{"start":"07-2025","value":"100.00","id":4,"kind":"fixed","months":3}`)).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		assert.NoError(t, cli.AddPromo(context.Background(), 1, promo))
+		assert.Equal(t, 4, promo.ID)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("No row with such id", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		err := cli.AddPromo(context.Background(), 1, &models.Promo{Kind: models.PromoPercent, Value: models.NewMoney(10, 0), Start: start, Months: 1})
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("too many decimal places", func(t *testing.T) {
+		yen := *sub
+		yen.Currency = "JPY"
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(&yen))
+		pool.ExpectRollback()
+		err := cli.AddPromo(context.Background(), 1, &models.Promo{Kind: models.PromoFixed, Value: models.MustParseMoney("99.99"), Start: start, Months: 1})
+		assert.ErrorIs(t, err, errvalues.ErrInvalidPrice)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestListPromos(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	getQuery := regexp.QuoteMeta(`SELECT uid, name, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, ` + todayColumn + ` FROM subscriptions ` + priceJoin + ` WHERE id = $1 AND deleted_at IS NULL;`)
+	query := regexp.QuoteMeta(`SELECT id, kind, value, starts, months FROM sub_promos WHERE sub_id = $1 ORDER BY starts, id;`)
+	subRow := func() *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+			AddRow(uuid.New(), "yandex", models.NewMoney(400, 0), start, nil, models.BillingMonthly, models.BaseCurrency, nil, nil, models.StatusActive, start)
+	}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnRows(subRow())
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "kind", "value", "starts", "months"}).
+				AddRow(1, models.PromoPercent, models.NewMoney(50, 0), start, 3))
+		result, err := cli.ListPromos(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Promo{
+			{ID: 1, Kind: models.PromoPercent, Value: models.NewMoney(50, 0), Start: start, Months: 3},
+		}, result)
+	})
+	t.Run("No row with such id", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		_, err := cli.ListPromos(context.Background(), 1)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(getQuery).
+			WithArgs(1).
+			WillReturnRows(subRow())
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnError(errors.New("db error"))
+		_, err := cli.ListPromos(context.Background(), 1)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,56 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Hard-deletes subscriptions which were soft deleted
+// longer than retention ago, returns number of purged rows
+func (cli *Client) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `DELETE FROM subscriptions WHERE deleted_at < now() - make_interval(secs => $1);`,
+		retention.Seconds())
+	if err != nil {
+		return 0, errors.New("purging deleted subs error: " + err.Error())
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Starts background purge of soft deleted subscriptions, expired
+// idempotency keys and idle rate limit buckets, which runs until ctx
+// is done. Interval and retention are taken from the current runtime
+// configuration
+func (cli *Client) RunPurge(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cli.softDelete.Load().PurgeInterval):
+			}
+			if n, err := cli.PurgeIdempotencyKeys(ctx); err != nil {
+				slog.Error("purge of expired idempotency keys failed",
+					slog.String("error", err.Error()))
+			} else {
+				slog.Info("purged expired idempotency keys", slog.Int64("rows", n))
+			}
+			if n, err := cli.PurgeRateLimits(ctx); err != nil {
+				slog.Error("purge of idle rate limits failed",
+					slog.String("error", err.Error()))
+			} else {
+				slog.Info("purged idle rate limits", slog.Int64("rows", n))
+			}
+			n, err := cli.PurgeDeleted(ctx, cli.softDelete.Load().Retention)
+			if err != nil {
+				slog.Error("purge of deleted subscriptions failed",
+					slog.String("error", err.Error()))
+				continue
+			}
+			slog.Info("purged deleted subscriptions", slog.Int64("rows", n))
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/ratelimit"
+)
+
+// Tokens in the bucket after refilling since last update
+const refilledTokens = `LEAST($2::float8, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at)::float8 * $3::float8)`
+
+// Takes token from the bucket stored in db, so the limit is shared
+// between service instances. Refill and take are done atomically
+// in a single statement
+func (cli *Client) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit) (*ratelimit.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	var tokens float64
+	var allowed bool
+	err := cli.conn.QueryRow(ctx, `INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at) VALUES
+($1, $2::float8 - 1, TRUE, now())
+ON CONFLICT (key) DO UPDATE SET
+tokens = `+refilledTokens+` - CASE WHEN `+refilledTokens+` >= 1 THEN 1 ELSE 0 END,
+allowed = `+refilledTokens+` >= 1,
+updated_at = now()
+RETURNING tokens, allowed;`, key, float64(limit.Burst), limit.Rate).Scan(&tokens, &allowed)
+	if err != nil {
+		return nil, errors.New("taking rate limit token error: " + err.Error())
+	}
+	return ratelimit.NewResult(allowed, tokens, limit), nil
+}
+
+// Deletes buckets which weren't used for an hour, they are full anyway
+// for any sane limit. Returns number of deleted ones
+func (cli *Client) PurgeRateLimits(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `DELETE FROM rate_limits WHERE updated_at < now() - interval '1 hour';`)
+	if err != nil {
+		return 0, errors.New("purging rate limits error: " + err.Error())
+	}
+	return tag.RowsAffected(), nil
+}
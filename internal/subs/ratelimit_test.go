@@ -0,0 +1,69 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/ratelimit"
+	"testcase/internal/subs"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeRateLimitToken(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+	query := `INSERT INTO rate_limits AS rl`
+	t.Run("allowed", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs("sum:apikey:1", 5.0, 1.0).
+			WillReturnRows(pgxmock.NewRows([]string{"tokens", "allowed"}).AddRow(3.5, true))
+		res, err := cli.TakeRateLimitToken(context.Background(), "sum:apikey:1", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Remaining)
+	})
+	t.Run("rejected", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs("sum:apikey:1", 5.0, 1.0).
+			WillReturnRows(pgxmock.NewRows([]string{"tokens", "allowed"}).AddRow(0.5, false))
+		res, err := cli.TakeRateLimitToken(context.Background(), "sum:apikey:1", limit)
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.NotZero(t, res.RetryAfter)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs("sum:apikey:1", 5.0, 1.0).
+			WillReturnError(errors.New("db error"))
+		_, err := cli.TakeRateLimitToken(context.Background(), "sum:apikey:1", limit)
+		assert.Error(t, err)
+	})
+}
+
+func TestPurgeRateLimits(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	pool.ExpectExec(regexp.QuoteMeta(`DELETE FROM rate_limits WHERE updated_at < now() - interval '1 hour';`)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	n, err := cli.PurgeRateLimits(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
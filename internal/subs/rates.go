@@ -0,0 +1,59 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/models"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// Saves exchange rates in a single transaction, rate
+// set for the same currency and month before is replaced
+func (cli *Client) SetRates(ctx context.Context, rates []*models.ExchangeRate) error {
+	return cli.inTx(ctx, cli.timeouts.Load().aggregate, func(ctx context.Context, tx pgx.Tx) error {
+		for _, r := range rates {
+			_, err := tx.Exec(ctx, `INSERT INTO exchange_rates (currency, effective_from, rate) VALUES ($1, $2, $3)
+ON CONFLICT (currency, effective_from) DO UPDATE SET rate = EXCLUDED.rate;`, r.Currency, r.EffectiveFrom, r.Rate)
+			if err != nil {
+				return errors.New("setting exchange rate error: " + err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+// Returns exchange rates ordered by currency and effective month,
+// rates of all currencies are returned if currency is empty
+func (cli *Client) ListRates(ctx context.Context, currency string) ([]*models.ExchangeRate, error) {
+	query := squirrel.Select("currency, effective_from, rate").
+		From("exchange_rates").
+		OrderBy("currency", "effective_from")
+	if currency != "" {
+		query = query.Where(squirrel.Eq{"currency": currency})
+	}
+	sql, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting exchange rates error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.ExchangeRate, 0)
+	for rows.Next() {
+		var r models.ExchangeRate
+		if err = rows.Scan(&r.Currency, &r.EffectiveFrom, &r.Rate); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading exchange rates error: " + err.Error())
+	}
+	return result, nil
+}
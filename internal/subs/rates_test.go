@@ -0,0 +1,80 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRates(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	july, _ := time.Parse("01-2006", "07-2025")
+	rates := []*models.ExchangeRate{
+		{Currency: "USD", EffectiveFrom: july, Rate: 78.5},
+		{Currency: "EUR", EffectiveFrom: july, Rate: 91},
+	}
+	query := regexp.QuoteMeta(`INSERT INTO exchange_rates (currency, effective_from, rate) VALUES ($1, $2, $3)`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		for _, r := range rates {
+			pool.ExpectExec(query).
+				WithArgs(r.Currency, r.EffectiveFrom, r.Rate).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		}
+		pool.ExpectCommit()
+		assert.NoError(t, cli.SetRates(context.Background(), rates))
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectExec(query).
+			WithArgs("USD", july, 78.5).
+			WillReturnError(errors.New("db error"))
+		pool.ExpectRollback()
+		assert.Error(t, cli.SetRates(context.Background(), rates))
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestListRates(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	july, _ := time.Parse("01-2006", "07-2025")
+	t.Run("filtered by currency", func(t *testing.T) {
+		pool.ExpectQuery(regexp.QuoteMeta(`SELECT currency, effective_from, rate FROM exchange_rates WHERE currency = $1 ORDER BY currency, effective_from`)).
+			WithArgs("USD").
+			WillReturnRows(pgxmock.NewRows([]string{"currency", "effective_from", "rate"}).
+				AddRow("USD", july, 78.5))
+		result, err := cli.ListRates(context.Background(), "USD")
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.ExchangeRate{{Currency: "USD", EffectiveFrom: july, Rate: 78.5}}, result)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT (.+) FROM exchange_rates").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.ListRates(context.Background(), "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,85 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// Returns reminders about subscriptions renewing or expiring in days
+// starting from the current day in time zone of subscription user,
+// ordered by date. Charges on start dates aren't renewals and are
+// skipped. Reminders already sent through all of channels are skipped
+func (cli *Client) DueReminders(ctx context.Context, days int, channels []string) ([]*models.Reminder, error) {
+	renewals := dueChargesQuery(days).
+		Columns("'"+models.ReminderRenewal+"' AS kind", "us.email").
+		Where("c.charged > s.created_at")
+	expiries := squirrel.Select("s.id", "s.uid", "s.name", "s.currency", "s.expires", "NULL",
+		"'"+models.ReminderExpiry+"'", "us.email").
+		From("subscriptions s").
+		JoinClause(`LEFT JOIN user_settings us ON us.uid = s.uid`).
+		JoinClause(localToday).
+		Where(squirrel.Eq{"s.deleted_at": nil}).
+		Where("s.expires >= t.today").
+		Where("s.expires < t.today + ?::int", days)
+	sql, args, err := squirrel.Select("r.kind", "r.id", "r.uid", "COALESCE(r.email, '')", "r.name", "r.charged", "r.amount", "r.currency").
+		FromSelect(renewals.Suffix("UNION ALL").SuffixExpr(expiries), "r").
+		Where(`(SELECT COUNT(*) FROM sub_reminders
+WHERE sub_id = r.id AND kind = r.kind AND due = r.charged AND channel = ANY(?)) < ?`, channels, len(channels)).
+		OrderBy("r.charged", "r.id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting due reminders error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.Reminder, 0)
+	for rows.Next() {
+		r := models.Reminder{}
+		if err = rows.Scan(&r.Kind, &r.SubID, &r.UID, &r.Email, &r.Name, &r.Date, &r.Amount, &r.Currency); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		if r.Amount != nil {
+			amount := r.Amount.Round(models.MinorUnits(r.Currency))
+			r.Amount = &amount
+		}
+		result = append(result, &r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading due reminders error: " + err.Error())
+	}
+	return result, nil
+}
+
+// Marks reminder as sent through channel, returns false
+// if it was already marked, so it mustn't be sent again
+func (cli *Client) ClaimReminder(ctx context.Context, r *models.Reminder, channel string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `INSERT INTO sub_reminders (sub_id, kind, due, channel) VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;`, r.SubID, r.Kind, r.Date, channel)
+	if err != nil {
+		return false, errors.New("claiming reminder error: " + err.Error())
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Unmarks reminder claimed for channel, so it is sent again later
+func (cli *Client) ReleaseReminder(ctx context.Context, r *models.Reminder, channel string) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	_, err := cli.conn.Exec(ctx, `DELETE FROM sub_reminders WHERE sub_id = $1 AND kind = $2 AND due = $3 AND channel = $4;`,
+		r.SubID, r.Kind, r.Date, channel)
+	if err != nil {
+		return errors.New("releasing reminder error: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueReminders(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	date, _ := models.ParseDate("2025-09-17")
+	channels := []string{"log", "smtp"}
+	t.Run("successful", func(t *testing.T) {
+		unrounded := models.MustParseMoney("199.995")
+		pool.ExpectQuery(`SELECT r.kind, r.id, r.uid, COALESCE\(r.email, ''\), r.name, r.charged, r.amount, r.currency FROM \(SELECT s.id, s.uid, s.name, s.currency, c.charged, (.+) AS amount, 'renewal' AS kind, us.email FROM subscriptions s (.+)AND c.charged > s.created_at UNION ALL SELECT s.id, s.uid, s.name, s.currency, s.expires, NULL, 'expiry', us.email FROM subscriptions s LEFT JOIN user_settings us ON us.uid = s.uid CROSS JOIN LATERAL (.+) WHERE s.deleted_at IS NULL AND s.expires >= t.today AND s.expires < t.today \+ \$3::int\) AS r WHERE \(SELECT COUNT\(\*\) FROM sub_reminders WHERE sub_id = r.id AND kind = r.kind AND due = r.charged AND channel = ANY\(\$4\)\) < \$5 ORDER BY r.charged, r.id`).
+			WithArgs(3, 3, 3, channels, 2).
+			WillReturnRows(pgxmock.NewRows([]string{"kind", "id", "uid", "email", "name", "charged", "amount", "currency"}).
+				AddRow(models.ReminderRenewal, 1, uid, "user@example.com", "yandex", date, &unrounded, models.BaseCurrency).
+				AddRow(models.ReminderExpiry, 2, uid, "user@example.com", "okko", date, nil, "USD"))
+		result, err := cli.DueReminders(context.Background(), 3, channels)
+		assert.NoError(t, err)
+		amount := models.MustParseMoney("200.00")
+		assert.Equal(t, []*models.Reminder{
+			{Kind: models.ReminderRenewal, SubID: 1, UID: uid, Email: "user@example.com", Name: "yandex", Date: date, Amount: &amount, Currency: models.BaseCurrency},
+			{Kind: models.ReminderExpiry, SubID: 2, UID: uid, Email: "user@example.com", Name: "okko", Date: date, Currency: "USD"},
+		}, result)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT r.kind").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.DueReminders(context.Background(), 3, channels)
+		assert.Error(t, err)
+	})
+}
+
+func TestClaimReminder(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	date, _ := models.ParseDate("2025-09-17")
+	reminder := &models.Reminder{Kind: models.ReminderExpiry, SubID: 1, Date: date}
+	query := regexp.QuoteMeta(`INSERT INTO sub_reminders (sub_id, kind, due, channel) VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;`)
+	t.Run("claimed", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(1, models.ReminderExpiry, date, "log").
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		claimed, err := cli.ClaimReminder(context.Background(), reminder, "log")
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+	})
+	t.Run("already sent", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(1, models.ReminderExpiry, date, "log").
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
+		claimed, err := cli.ClaimReminder(context.Background(), reminder, "log")
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+	})
+	t.Run("released", func(t *testing.T) {
+		pool.ExpectExec(regexp.QuoteMeta(`DELETE FROM sub_reminders WHERE sub_id = $1 AND kind = $2 AND due = $3 AND channel = $4;`)).
+			WithArgs(1, models.ReminderExpiry, date, "log").
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		assert.NoError(t, cli.ReleaseReminder(context.Background(), reminder, "log"))
+	})
+}
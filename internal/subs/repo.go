@@ -3,8 +3,11 @@ package subs
 import (
 	"context"
 	"errors"
+	"iter"
 	"log"
+	"sync/atomic"
 	"testcase/internal/errvalues"
+	"testcase/internal/settings"
 	"testcase/models"
 	"time"
 
@@ -19,10 +22,21 @@ type PgConnection interface {
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	Ping(ctx context.Context) error
+	Begin(ctx context.Context) (pgx.Tx, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 }
 
 type Client struct {
-	conn PgConnection
+	conn       PgConnection
+	timeouts   atomic.Pointer[timeouts]
+	softDelete atomic.Pointer[settings.SoftDelete]
+}
+
+type timeouts struct {
+	query     time.Duration
+	aggregate time.Duration
+	export    time.Duration
+	imports   time.Duration
 }
 
 type DBConfig struct {
@@ -49,9 +63,7 @@ func New(cfg *DBConfig) *Client {
 	if err != nil {
 		log.Fatal("ping error: " + err.Error())
 	}
-	return &Client{
-		conn: p,
-	}
+	return newClient(p)
 }
 
 func NewWithConn(conn PgConnection) *Client {
@@ -59,135 +71,199 @@ func NewWithConn(conn PgConnection) *Client {
 	if err != nil {
 		log.Fatal("ping error: " + err.Error())
 	}
-	return &Client{
+	return newClient(conn)
+}
+
+func newClient(conn PgConnection) *Client {
+	cli := &Client{
 		conn: conn,
 	}
+	cli.Reconfigure(settings.DefaultRuntime())
+	return cli
 }
 
-// Creates a new subscription row in db
-func (cli *Client) AddSub(s *models.Subscription) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-	_, err := cli.conn.Exec(ctx, `INSERT INTO subscriptions (uid, name, cost, created_at, expires) VALUES
-($1, $2, $3, $4, $5);`, s.UID, s.Name, s.Price, s.Start, s.Expires)
-	if err != nil {
-		return errors.New("error inserting sub: " + err.Error())
-	}
-	return nil
+// Applies runtime configuration, used as settings subscriber
+func (cli *Client) Reconfigure(rt *settings.Runtime) {
+	cli.timeouts.Store(&timeouts{
+		query:     rt.QueryTimeout,
+		aggregate: rt.AggregateTimeout,
+		export:    rt.ExportTimeout,
+		imports:   rt.ImportTimeout,
+	})
+	softDelete := rt.SoftDelete
+	cli.softDelete.Store(&softDelete)
+}
+
+// Creates a new subscription row in db and sets its ID. For non-admin
+// principal subscription is always created for the principal's user
+func (cli *Client) AddSub(ctx context.Context, s *models.Subscription) error {
+	return cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		return insertSub(ctx, tx, s)
+	})
 }
 
 // Returns subscription by provided id, if there is no any
-// returns ErrNoSuchRow
-func (cli *Client) GetSub(id int) (*models.Subscription, error) {
+// (or it belongs to another user, or is deleted) returns ErrNoSuchRow
+func (cli *Client) GetSub(ctx context.Context, id int) (*models.Subscription, error) {
 	result := models.Subscription{
 		ID: id,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	var today time.Time
+	query := `SELECT uid, name, ` + subPrice + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + subStatus + `, ` + subToday + ` FROM subscriptions ` + subPriceJoin + ` WHERE id = $1 AND deleted_at IS NULL`
+	args := []any{id}
+	if uid, scoped := ownerScope(ctx); scoped {
+		query += ` AND uid = $2`
+		args = append(args, uid)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
 	defer cancel()
-	row := cli.conn.QueryRow(ctx, `SELECT uid, name, cost, created_at, expires FROM subscriptions WHERE id = $1;`, id)
-	if err := row.Scan(&result.UID, &result.Name, &result.Price, &result.Start, &result.Expires); err != nil {
+	row := cli.conn.QueryRow(ctx, query+`;`, args...)
+	if err := row.Scan(&result.UID, &result.Name, &result.Price, &result.Start, &result.Expires, &result.BillingPeriod, &result.Currency, &result.TrialEnd, &result.TrialPrice, &result.Status, &today); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errvalues.ErrNoSuchRow
 		}
 		return nil, errors.New("error getting subscription: " + err.Error())
 	}
+	result.Schedule(today)
 	return &result, nil
 }
 
 // Takes new subscription info and updates row with provided id,
-// if there is no any returns ErrNoSuchRow
-func (cli *Client) UpdateSub(id int, s *models.Subscription) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-	tag, err := cli.conn.Exec(ctx, `UPDATE subscriptions SET uid = $1, name = $2, cost = $3, created_at = $4, expires = $5 WHERE id = $6;`,
-		s.UID, s.Name, s.Price, s.Start, s.Expires, id)
-	if err != nil {
-		return errors.New("error updating subscription: " + err.Error())
-	} else if tag.RowsAffected() == 0 {
-		return errvalues.ErrNoSuchRow
-	}
-	return nil
+// if there is no any (or it belongs to another user, or is deleted)
+// returns ErrNoSuchRow
+func (cli *Client) UpdateSub(ctx context.Context, id int, s *models.Subscription) error {
+	return cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		return updateSub(ctx, tx, id, s)
+	})
 }
 
-// Deletes row with provided id, if there is no any returns ErrNoSuchRow
-func (cli *Client) DeleteSub(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-	tag, err := cli.conn.Exec(ctx, `DELETE FROM subscriptions WHERE id = $1;`, id)
-	if err != nil {
-		return errors.New("deleting sub error: " + err.Error())
-	} else if tag.RowsAffected() == 0 {
-		return errvalues.ErrNoSuchRow
-	}
-	return nil
+// Marks row with provided id deleted, such rows are hidden from reads
+// and purged after retention period. If there is no any (or it belongs
+// to another user, or is already deleted) returns ErrNoSuchRow
+func (cli *Client) DeleteSub(ctx context.Context, id int) error {
+	return cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		return deleteSub(ctx, tx, id)
+	})
 }
 
-// Takes opts for filtering, limit, order and offset settings and returns
-// list of subscriptions. opts.Filter and opts.Order can be nil for unfiltered
-// and unordered result
-func (cli *Client) ListSubs(opts *models.ListOpts) ([]*models.Subscription, error) {
-	query := squirrel.Select("id, name, uid, cost, created_at, expires").
+// Takes deleted row with provided id back, if there is no any (or it
+// belongs to another user, or isn't deleted) returns ErrNoSuchRow
+func (cli *Client) RestoreSub(ctx context.Context, id int) error {
+	return cli.inTx(ctx, cli.timeouts.Load().query, func(ctx context.Context, tx pgx.Tx) error {
+		return restoreSub(ctx, tx, id)
+	})
+}
+
+// Columns of models.OrderFields
+var orderColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"uid":        "uid",
+	"price":      subPrice,
+	"created_at": "created_at",
+	"expires":    "expires",
+}
+
+// Builds select of subscriptions with opts applied,
+// uid filter is forced for non-admin principal
+func listQuery(ctx context.Context, opts *models.ListOpts) (string, []any, error) {
+	query := squirrel.Select("id, name, uid, " + subPrice + ", created_at, expires, billing_period, currency, trial_end, trial_price, " + subStatus + ", " + subToday + ", deleted_at").
 		From("subscriptions").
+		JoinClause(subPriceJoin).
 		Offset(uint64(opts.Offset))
+	// Deleted rows are visible to admins only
+	if _, scoped := ownerScope(ctx); !opts.IncludeDeleted || scoped {
+		query = query.Where(squirrel.Eq{"deleted_at": nil})
+	}
 	if opts.Limit != 0 {
 		query = query.Limit(uint64(opts.Limit))
 	}
 	if opts.Order != "" {
-		query = query.OrderBy(opts.Order)
+		field, desc, err := models.ParseOrder(opts.Order)
+		if err != nil {
+			return "", nil, err
+		}
+		column := orderColumns[field]
+		if desc {
+			column += " DESC"
+		}
+		query = query.OrderBy(column)
 	}
-	if opts.Filter != nil {
-		query = query.Where(squirrel.Eq(opts.Filter))
+	if filter := scopeFilter(ctx, opts.Filter); filter != nil {
+		query = query.Where(squirrel.Eq(filter))
+	}
+	if len(opts.Status) != 0 {
+		query = query.Where(squirrel.Eq{subStatus: opts.Status})
 	}
 	sql, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
 	if err != nil {
-		return nil, errors.New("building query error: " + err.Error())
+		return "", nil, errors.New("building query error: " + err.Error())
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	return sql, args, nil
+}
+
+// Takes opts for filtering, limit, order and offset settings and returns
+// list of subscriptions. opts.Filter and opts.Order can be nil for unfiltered
+// and unordered result, rows are also filtered by opts.Status if it is set.
+// For non-admin principal uid filter is forced to the principal's user
+func (cli *Client) ListSubs(ctx context.Context, opts *models.ListOpts) ([]*models.Subscription, error) {
+	sql, args, err := listQuery(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
 	defer cancel()
 	rows, err := cli.conn.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, errors.New("getting subs list error: " + err.Error())
 	}
+	defer rows.Close()
 	result := make([]*models.Subscription, 0, len(rows.RawValues()))
 	for rows.Next() {
 		s := models.Subscription{}
-		err = rows.Scan(&s.ID, &s.Name, &s.UID, &s.Price, &s.Start, &s.Expires)
+		var today time.Time
+		err = rows.Scan(&s.ID, &s.Name, &s.UID, &s.Price, &s.Start, &s.Expires, &s.BillingPeriod, &s.Currency, &s.TrialEnd, &s.TrialPrice, &s.Status, &today, &s.DeletedAt)
 		if err != nil {
 			return nil, errors.New("error converting rows error: " + err.Error())
 		}
+		s.Schedule(today)
 		result = append(result, &s)
 	}
 	return result, nil
 }
 
-// Returns sum of found rows with provided filter and range.
-// If filter is nil, returns sum of all rows.
-// If period is nil, returns sum for all time.
-func (cli *Client) PriceSum(filter map[string]interface{}, period *models.RangeOpts) (int, error) {
-	query := squirrel.Select("SUM(cost)").
-		From("subscriptions").
-		Where(squirrel.Eq(filter))
-	if filter != nil {
-		query = query.Where(squirrel.Eq(filter))
-	}
-	if period != nil {
-		query = query.Where(squirrel.Or{
-			squirrel.LtOrEq{"created_at": period.End},
-			squirrel.GtOrEq{"expires": period.Start},
-		})
-	}
-	sql, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
-	if err != nil {
-		return 0, errors.New("building query error: " + err.Error())
-	}
-	var result int
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
-	if err = cli.conn.QueryRow(ctx, sql, args...).Scan(&result); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, errvalues.ErrNoSuchRow
+// Same as ListSubs, but yields rows one by one as they are read from db,
+// so memory usage doesn't depend on result size. Export timeout is used
+// instead of aggregate one. Iteration stops on the first error
+func (cli *Client) StreamSubs(ctx context.Context, opts *models.ListOpts) iter.Seq2[*models.Subscription, error] {
+	return func(yield func(*models.Subscription, error) bool) {
+		sql, args, err := listQuery(ctx, opts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().export)
+		defer cancel()
+		rows, err := cli.conn.Query(ctx, sql, args...)
+		if err != nil {
+			yield(nil, errors.New("getting subs list error: "+err.Error()))
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s := models.Subscription{}
+			var today time.Time
+			if err = rows.Scan(&s.ID, &s.Name, &s.UID, &s.Price, &s.Start, &s.Expires, &s.BillingPeriod, &s.Currency, &s.TrialEnd, &s.TrialPrice, &s.Status, &today, &s.DeletedAt); err != nil {
+				yield(nil, errors.New("error converting rows error: "+err.Error()))
+				return
+			}
+			s.Schedule(today)
+			if !yield(&s, nil) {
+				return
+			}
+		}
+		if err = rows.Err(); err != nil {
+			yield(nil, errors.New("reading subs rows error: "+err.Error()))
 		}
-		return 0, errors.New("getting subs sum error: " + err.Error())
 	}
-	return result, nil
 }
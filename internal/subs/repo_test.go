@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
+	"testcase/internal/auth"
 	"testcase/internal/errvalues"
 	"testcase/internal/subs"
 	"testcase/models"
@@ -22,6 +24,41 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+var (
+	auditQuery = regexp.QuoteMeta(`WITH change AS (INSERT INTO audit_log (sub_id, uid, actor, request_id, op, before, after) VALUES`)
+	priceQuery = regexp.QuoteMeta(`INSERT INTO sub_prices (sub_id, effective_from, cost) VALUES ($1, $2, $3)`)
+	monthQuery = regexp.QuoteMeta(`SELECT date_trunc('month', now() AT TIME ZONE COALESCE(`)
+	dayQuery   = regexp.QuoteMeta(`SELECT (now() AT TIME ZONE COALESCE(`)
+)
+
+const statusColumn = `(SELECT CASE WHEN subscriptions.expires <= t.today THEN 'expired'
+WHEN subscriptions.cancelled_at IS NOT NULL THEN 'cancelled'
+WHEN EXISTS (SELECT 1 FROM sub_pauses WHERE sub_pauses.sub_id = subscriptions.id AND sub_pauses.ends IS NULL) THEN 'paused'
+WHEN subscriptions.created_at > t.today THEN 'upcoming'
+WHEN subscriptions.trial_end > t.today THEN 'trial'
+ELSE 'active' END
+FROM (SELECT ` + todayColumn + ` AS today) t)`
+
+const todayColumn = `(now() AT TIME ZONE COALESCE((SELECT time_zone FROM user_settings WHERE uid = subscriptions.uid), 'UTC'))::date`
+
+const priceColumn = `COALESCE(p.current_cost, subscriptions.cost)`
+
+const priceJoin = `LEFT JOIN LATERAL (SELECT cost AS current_cost FROM sub_prices
+WHERE sub_id = subscriptions.id AND effective_from <= ` + todayColumn + `
+ORDER BY effective_from DESC LIMIT 1) p ON true`
+
+func lockQuery(deleted bool) string {
+	if deleted {
+		return regexp.QuoteMeta(`SELECT id, uid, name, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, deleted_at FROM subscriptions ` + priceJoin + ` WHERE id = $1 AND deleted_at IS NOT NULL`)
+	}
+	return regexp.QuoteMeta(`SELECT id, uid, name, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, deleted_at FROM subscriptions ` + priceJoin + ` WHERE id = $1 AND deleted_at IS NULL`)
+}
+
+func lockedRow(s *models.Subscription) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"id", "uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "deleted_at"}).
+		AddRow(s.ID, s.UID, s.Name, s.Price, s.Start, s.Expires, s.BillingPeriod, s.Currency, s.TrialEnd, s.TrialPrice, s.Status, s.DeletedAt)
+}
+
 func TestAddSub(t *testing.T) {
 	t.Parallel()
 	pool, err := pgxmock.NewPool()
@@ -36,25 +73,39 @@ func TestAddSub(t *testing.T) {
 	exp, _ := time.Parse("01-2006", "08-2025")
 	sub := &models.Subscription{
 		Name:    "yandex",
-		Price:   400,
+		Price:   models.NewMoney(400, 0),
 		UID:     uuid.New(),
 		Start:   start,
 		Expires: &exp,
 	}
-	query := regexp.QuoteMeta(`INSERT INTO subscriptions (uid, name, cost, created_at, expires) VALUES ($1, $2, $3, $4, $5);`)
+	query := regexp.QuoteMeta(`INSERT INTO subscriptions (uid, name, cost, created_at, expires, billing_period, currency, trial_end, trial_price) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`)
 	t.Run("successful", func(t *testing.T) {
-		pool.ExpectExec(query).
-			WillReturnResult(pgxmock.NewResult("INSERT", 1)).
-			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires)
-		err = cli.AddSub(sub)
+		ctx := context.WithValue(context.Background(), "Request-ID", "req")
+		pool.ExpectBegin()
+		pool.ExpectQuery(query).
+			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, models.BillingMonthly, models.BaseCurrency, sub.TrialEnd, sub.TrialPrice).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(3))
+		pool.ExpectExec(priceQuery).
+			WithArgs(3, sub.Start, sub.Price).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(3, sub.UID, "system", pgxmock.AnyArg(), models.OpCreate, []byte(nil), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		err = cli.AddSub(ctx, sub)
 		assert.NoError(t, err)
+		assert.Equal(t, 3, sub.ID)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 	t.Run("with error", func(t *testing.T) {
-		pool.ExpectExec(query).
-			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires).
+		pool.ExpectBegin()
+		pool.ExpectQuery(query).
+			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, models.BillingMonthly, models.BaseCurrency, sub.TrialEnd, sub.TrialPrice).
 			WillReturnError(errors.New("db error"))
-		err = cli.AddSub(sub)
+		pool.ExpectRollback()
+		err = cli.AddSub(context.Background(), sub)
 		assert.Error(t, err)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 }
 
@@ -73,18 +124,21 @@ func TestGetSub(t *testing.T) {
 	sub := &models.Subscription{
 		ID:      1,
 		Name:    "yandex",
-		Price:   400,
+		Price:   models.NewMoney(400, 0),
 		UID:     uuid.New(),
 		Start:   start,
 		Expires: &exp,
+		Status:  models.StatusActive,
 	}
-	query := regexp.QuoteMeta(`SELECT uid, name, cost, created_at, expires FROM subscriptions WHERE id = $1;`)
+	autoRenew, remaining := false, 1
+	sub.AutoRenew, sub.NextChargeDate, sub.RemainingCharges = &autoRenew, &start, &remaining
+	query := regexp.QuoteMeta(`SELECT uid, name, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, ` + todayColumn + ` FROM subscriptions ` + priceJoin + ` WHERE id = $1 AND deleted_at IS NULL;`)
 	t.Run("successful", func(t *testing.T) {
 		pool.ExpectQuery(query).
 			WithArgs(1).
-			WillReturnRows(pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires"}).
-				AddRow(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires))
-		result, err := cli.GetSub(1)
+			WillReturnRows(pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+				AddRow(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, sub.BillingPeriod, sub.Currency, sub.TrialEnd, sub.TrialPrice, sub.Status, start))
+		result, err := cli.GetSub(context.Background(), 1)
 		assert.NoError(t, err)
 		assert.Equal(t, sub, result)
 	})
@@ -92,16 +146,36 @@ func TestGetSub(t *testing.T) {
 		pool.ExpectQuery(query).
 			WithArgs(1).
 			WillReturnError(errors.New("db error"))
-		_, err := cli.GetSub(1)
+		_, err := cli.GetSub(context.Background(), 1)
 		assert.Error(t, err)
 	})
 	t.Run("No row with such id", func(t *testing.T) {
 		pool.ExpectQuery(query).
 			WithArgs(1).
 			WillReturnError(pgx.ErrNoRows)
-		_, err := cli.GetSub(1)
+		_, err := cli.GetSub(context.Background(), 1)
 		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
 	})
+	t.Run("scoped to principal's user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: sub.UID})
+		pool.ExpectQuery(regexp.QuoteMeta(`SELECT uid, name, `+priceColumn+`, created_at, expires, billing_period, currency, trial_end, trial_price, `+statusColumn+`, `+todayColumn+` FROM subscriptions `+priceJoin+` WHERE id = $1 AND deleted_at IS NULL AND uid = $2;`)).
+			WithArgs(1, sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+				AddRow(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, sub.BillingPeriod, sub.Currency, sub.TrialEnd, sub.TrialPrice, sub.Status, start))
+		result, err := cli.GetSub(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, sub, result)
+	})
+	t.Run("admin is not scoped", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: uuid.New(), Admin: true})
+		pool.ExpectQuery(query).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today"}).
+				AddRow(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, sub.BillingPeriod, sub.Currency, sub.TrialEnd, sub.TrialPrice, sub.Status, start))
+		result, err := cli.GetSub(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, sub, result)
+	})
 }
 
 func TestUpdateSub(t *testing.T) {
@@ -118,33 +192,59 @@ func TestUpdateSub(t *testing.T) {
 	exp, _ := time.Parse("01-2006", "08-2025")
 	sub := &models.Subscription{
 		Name:    "yandex",
-		Price:   400,
+		Price:   models.NewMoney(400, 0),
 		UID:     uuid.New(),
 		Start:   start,
 		Expires: &exp,
 	}
 	id := 1
-	query := regexp.QuoteMeta(`UPDATE subscriptions SET uid = $1, name = $2, cost = $3, created_at = $4, expires = $5 WHERE id = $6`)
+	old := &models.Subscription{ID: id, Name: "yandex", Price: models.NewMoney(300, 0), UID: sub.UID, Start: start}
+	query := regexp.QuoteMeta(`UPDATE subscriptions SET uid = $1, name = $2, cost = $3, created_at = $4, expires = $5, billing_period = $6, currency = $7, trial_end = $8, trial_price = $9 WHERE id = $10`)
 	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(id).
+			WillReturnRows(lockedRow(old))
 		pool.ExpectExec(query).
-			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, id).
+			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, models.BillingMonthly, models.BaseCurrency, sub.TrialEnd, sub.TrialPrice, id).
 			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
-		err := cli.UpdateSub(id, sub)
+		month := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+		pool.ExpectQuery(monthQuery).
+			WithArgs(sub.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"month"}).AddRow(month))
+		pool.ExpectExec(priceQuery).
+			WithArgs(id, month, sub.Price).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(id, sub.UID, "system", pgxmock.AnyArg(), models.OpUpdate, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		err := cli.UpdateSub(context.Background(), id, sub)
 		assert.NoError(t, err)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 	t.Run("No row with such id", func(t *testing.T) {
-		pool.ExpectExec(query).
-			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, id).
-			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
-		err := cli.UpdateSub(id, sub)
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(id).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		err := cli.UpdateSub(context.Background(), id, sub)
 		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 	t.Run("db error", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
+			WithArgs(id).
+			WillReturnRows(lockedRow(old))
 		pool.ExpectExec(query).
-			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, id).
+			WithArgs(sub.UID, sub.Name, sub.Price, sub.Start, sub.Expires, models.BillingMonthly, models.BaseCurrency, sub.TrialEnd, sub.TrialPrice, id).
 			WillReturnError(errors.New("db error"))
-		err := cli.UpdateSub(id, sub)
+		pool.ExpectRollback()
+		err := cli.UpdateSub(context.Background(), id, sub)
 		assert.Error(t, err)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 }
 
@@ -159,27 +259,196 @@ func TestDelete(t *testing.T) {
 	})
 	cli := subs.NewWithConn(pool)
 	id := 1
-	query := regexp.QuoteMeta(`DELETE FROM subscriptions WHERE id = $1;`)
+	start, _ := time.Parse("01-2006", "07-2025")
+	old := &models.Subscription{ID: id, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start}
+	now := time.Now()
+	query := regexp.QuoteMeta(`UPDATE subscriptions SET deleted_at = now() WHERE id = $1 RETURNING deleted_at;`)
 	t.Run("successful", func(t *testing.T) {
-		pool.ExpectExec(query).
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
 			WithArgs(id).
-			WillReturnResult(pgxmock.NewResult("DELETE", 1))
-		err := cli.DeleteSub(id)
+			WillReturnRows(lockedRow(old))
+		pool.ExpectQuery(query).
+			WithArgs(id).
+			WillReturnRows(pgxmock.NewRows([]string{"deleted_at"}).AddRow(&now))
+		pool.ExpectExec(auditQuery).
+			WithArgs(id, old.UID, "system", pgxmock.AnyArg(), models.OpDelete, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		err := cli.DeleteSub(context.Background(), id)
 		assert.NoError(t, err)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 	t.Run("No row with such id", func(t *testing.T) {
-		pool.ExpectExec(query).
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)).
 			WithArgs(id).
-			WillReturnResult(pgxmock.NewResult("DELETE", 0))
-		err := cli.DeleteSub(id)
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		err := cli.DeleteSub(context.Background(), id)
 		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
 	})
 	t.Run("db error", func(t *testing.T) {
+		pool.ExpectBegin().WillReturnError(errors.New("db error"))
+		err := cli.DeleteSub(context.Background(), id)
+		assert.Error(t, err)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("other user's row", func(t *testing.T) {
+		uid := uuid.New()
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: uid})
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(false)+regexp.QuoteMeta(` AND uid = $2 FOR UPDATE OF subscriptions;`)).
+			WithArgs(id, uid).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		err := cli.DeleteSub(ctx, id)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestRestoreSub(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	deletedAt := time.Now()
+	old := &models.Subscription{ID: 1, Name: "yandex", Price: models.NewMoney(400, 0), UID: uuid.New(), Start: start, DeletedAt: &deletedAt}
+	query := regexp.QuoteMeta(`UPDATE subscriptions SET deleted_at = NULL WHERE id = $1;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(true)).
+			WithArgs(1).
+			WillReturnRows(lockedRow(old))
 		pool.ExpectExec(query).
-			WithArgs(id).
+			WithArgs(1).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		pool.ExpectExec(auditQuery).
+			WithArgs(1, old.UID, "system", pgxmock.AnyArg(), models.OpRestore, pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		pool.ExpectCommit()
+		assert.NoError(t, cli.RestoreSub(context.Background(), 1))
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("not deleted or unexisted", func(t *testing.T) {
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(true)).
+			WithArgs(1).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		assert.ErrorIs(t, cli.RestoreSub(context.Background(), 1), errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("other user's row", func(t *testing.T) {
+		uid := uuid.New()
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: uid})
+		pool.ExpectBegin()
+		pool.ExpectQuery(lockQuery(true)+regexp.QuoteMeta(` AND uid = $2 FOR UPDATE OF subscriptions;`)).
+			WithArgs(1, uid).
+			WillReturnError(pgx.ErrNoRows)
+		pool.ExpectRollback()
+		assert.ErrorIs(t, cli.RestoreSub(ctx, 1), errvalues.ErrNoSuchRow)
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	pool.ExpectExec(regexp.QuoteMeta(`DELETE FROM subscriptions WHERE deleted_at < now() - make_interval(secs => $1);`)).
+		WithArgs(3600.0).
+		WillReturnResult(pgxmock.NewResult("DELETE", 4))
+	n, err := cli.PurgeDeleted(context.Background(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+}
+
+func TestStreamSubs(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "07-2025")
+	uid := uuid.New().String()
+	query := regexp.QuoteMeta(`SELECT id, name, uid, ` + priceColumn + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + statusColumn + `, ` + todayColumn + `, deleted_at FROM subscriptions ` + priceJoin + ` WHERE deleted_at IS NULL AND uid = $1 ORDER BY name`)
+	opts := &models.ListOpts{
+		Filter: map[string]interface{}{"uid": uid},
+		Order:  "name",
+	}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs(uid).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "uid", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today", "deleted_at"}).
+				AddRow(1, "okko", uuid.MustParse(uid), models.MustParseMoney("299.99"), start, nil, models.BillingYearly, "USD", nil, nil, models.StatusActive, start, nil).
+				AddRow(2, "yandex", uuid.MustParse(uid), models.NewMoney(400, 0), start, nil, models.BillingMonthly, models.BaseCurrency, nil, nil, models.StatusPaused, start, nil))
+		var names []string
+		for s, err := range cli.StreamSubs(context.Background(), opts) {
+			assert.NoError(t, err)
+			names = append(names, s.Name)
+		}
+		assert.Equal(t, []string{"okko", "yandex"}, names)
+	})
+	t.Run("filtered by status", func(t *testing.T) {
+		pool.ExpectQuery(regexp.QuoteMeta(`FROM subscriptions `+priceJoin+` WHERE deleted_at IS NULL AND uid = $1 AND `+statusColumn+` IN ($2,$3) ORDER BY name`)).
+			WithArgs(uid, models.StatusActive, models.StatusTrial).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "uid", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today", "deleted_at"}).
+				AddRow(1, "okko", uuid.MustParse(uid), models.MustParseMoney("299.99"), start, nil, models.BillingYearly, "USD", nil, nil, models.StatusActive, start, nil))
+		filtered := *opts
+		filtered.Status = []string{models.StatusActive, models.StatusTrial}
+		count := 0
+		for s, err := range cli.StreamSubs(context.Background(), &filtered) {
+			assert.NoError(t, err)
+			assert.Equal(t, models.StatusActive, s.Status)
+			count++
+		}
+		assert.Equal(t, 1, count)
+	})
+	t.Run("with error", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs(uid).
 			WillReturnError(errors.New("db error"))
-		err := cli.DeleteSub(id)
-		assert.Error(t, err)
+		for s, err := range cli.StreamSubs(context.Background(), opts) {
+			assert.Nil(t, s)
+			assert.Error(t, err)
+		}
+	})
+	t.Run("ordered by price descending", func(t *testing.T) {
+		pool.ExpectQuery(regexp.QuoteMeta(`FROM subscriptions ` + priceJoin + ` WHERE deleted_at IS NULL AND uid = $1 ORDER BY ` + priceColumn + ` DESC`)).
+			WithArgs(uid).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "uid", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "status", "today", "deleted_at"}))
+		ordered := *opts
+		ordered.Order = "price desc"
+		for _, err := range cli.StreamSubs(context.Background(), &ordered) {
+			assert.NoError(t, err)
+		}
+	})
+	t.Run("with invalid order", func(t *testing.T) {
+		ordered := *opts
+		ordered.Order = "(SELECT name FROM subscriptions LIMIT 1)"
+		for s, err := range cli.StreamSubs(context.Background(), &ordered) {
+			assert.Nil(t, s)
+			assert.Error(t, err)
+		}
 	})
 }
 
@@ -212,14 +481,11 @@ func setupTestDB(t *testing.T) subs.DBConfig {
 	if err != nil {
 		t.Fatal("error connecting to container: " + err.Error())
 	}
-	_, err = pool.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS subscriptions (
-    id SERIAL PRIMARY KEY,
-    uid UUID NOT NULL, 
-    name text NOT NULL,
-    cost INTEGER NOT NULL,
-    created_at DATE CHECK (EXTRACT(DAY FROM created_at) = 1) NOT NULL,
-    expires TIMESTAMPTZ
-);`)
+	migrations, err := os.ReadFile("../../migrations/baseline.sql")
+	if err != nil {
+		t.Fatal("error reading migrations: " + err.Error())
+	}
+	_, err = pool.Exec(context.Background(), string(migrations))
 	if err != nil {
 		t.Fatal("error setting migrations: " + err.Error())
 	}
@@ -245,20 +511,320 @@ func TestIntegrational(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		sub := &models.Subscription{
 			Name:    fmt.Sprintf("name #%d", i),
-			Price:   i * 100,
+			Price:   models.NewMoney(int64(i*100), 0),
 			UID:     uid,
 			Start:   start,
 			Expires: &exp,
 		}
-		err := cli.AddSub(sub)
+		err := cli.AddSub(context.Background(), sub)
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
 
+	// Not parallel, so the subscription is deleted before
+	// total price sum is checked
+	t.Run("price changed", func(t *testing.T) {
+		subStart, _ := time.Parse("01-2006", "01-2025")
+		subExp, _ := time.Parse("01-2006", "05-2025")
+		changed, _ := time.Parse("01-2006", "03-2025")
+		sub := &models.Subscription{
+			Name:    "repriced",
+			Price:   models.NewMoney(100, 0),
+			UID:     uuid.New(),
+			Start:   subStart,
+			Expires: &subExp,
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, cli.SchedulePrice(context.Background(), sub.ID, &models.PriceChange{Price: models.NewMoney(200, 0), EffectiveFrom: changed}))
+		filter := map[string]interface{}{"uid": sub.UID}
+		sum, err := cli.PriceSum(context.Background(), filter, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "600.00", sum.String())
+		sum, err = cli.PriceSum(context.Background(), filter, &models.RangeOpts{Start: subStart, End: subStart}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "100.00", sum.String())
+		prices, err := cli.ListPrices(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Len(t, prices, 2)
+		got, err := cli.GetSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "200.00", got.Price.String())
+		// The old price sent back replaces the effective one
+		assert.NoError(t, cli.UpdateSub(context.Background(), sub.ID, sub))
+		got, err = cli.GetSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "100.00", got.Price.String())
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("yearly billing", func(t *testing.T) {
+		subStart, _ := time.Parse("01-2006", "01-2025")
+		periodEnd, _ := time.Parse("01-2006", "06-2025")
+		sub := &models.Subscription{
+			Name:          "yearly",
+			Price:         models.NewMoney(1200, 0),
+			UID:           uuid.New(),
+			Start:         subStart,
+			BillingPeriod: models.BillingYearly,
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		filter := map[string]interface{}{"uid": sub.UID}
+		period := &models.RangeOpts{Start: subStart, End: periodEnd}
+		sum, err := cli.PriceSum(context.Background(), filter, period, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "1200.00", sum.String())
+		sum, err = cli.PriceSum(context.Background(), filter, period, &models.SumOpts{MonthlyEquivalent: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "600.00", sum.String())
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("prorated by days", func(t *testing.T) {
+		subStart, _ := models.ParseDate("2025-01-17")
+		subExp, _ := models.ParseDate("2025-03-17")
+		periodStart, _ := models.ParseDate("2025-02-01")
+		periodEnd, _ := models.ParseDate("2025-02-28")
+		sub := &models.Subscription{
+			Name:    "prorated",
+			Price:   models.NewMoney(300, 0),
+			UID:     uuid.New(),
+			Start:   subStart,
+			Expires: &subExp,
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		filter := map[string]interface{}{"uid": sub.UID}
+		period := &models.RangeOpts{Start: periodStart, End: periodEnd, DayPrecision: true}
+		sum, err := cli.PriceSum(context.Background(), filter, period, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "300.00", sum.String())
+		// 16 of 31 days of the first cycle and 12 of 28 days of the second one
+		sum, err = cli.PriceSum(context.Background(), filter, period, &models.SumOpts{Prorate: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "283.41", sum.String())
+		assert.NoError(t, cli.SetUserSettings(context.Background(), &models.UserSettings{UID: sub.UID, TimeZone: "Pacific/Kiritimati"}))
+		settings, err := cli.GetUserSettings(context.Background(), sub.UID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Pacific/Kiritimati", settings.TimeZone)
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("converted to currency", func(t *testing.T) {
+		subStart, _ := time.Parse("01-2006", "01-2025")
+		subExp, _ := time.Parse("01-2006", "03-2025")
+		rateChanged, _ := time.Parse("01-2006", "02-2025")
+		sub := &models.Subscription{
+			Name:     "usd",
+			Price:    models.NewMoney(10, 0),
+			UID:      uuid.New(),
+			Start:    subStart,
+			Expires:  &subExp,
+			Currency: "USD",
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		filter := map[string]interface{}{"uid": sub.UID}
+		_, err := cli.PriceSum(context.Background(), filter, nil, &models.SumOpts{Currency: models.BaseCurrency})
+		assert.ErrorIs(t, err, errvalues.ErrNoRate)
+		assert.NoError(t, cli.SetRates(context.Background(), []*models.ExchangeRate{
+			{Currency: "USD", EffectiveFrom: subStart, Rate: 80},
+			{Currency: "USD", EffectiveFrom: rateChanged, Rate: 90},
+		}))
+		sum, err := cli.PriceSum(context.Background(), filter, nil, &models.SumOpts{Currency: models.BaseCurrency})
+		assert.NoError(t, err)
+		assert.Equal(t, "1700.00", sum.String())
+		totals, err := cli.PriceTotals(context.Background(), filter, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "20.00", totals["USD"].String())
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("trial and promo", func(t *testing.T) {
+		subStart, _ := time.Parse("01-2006", "01-2025")
+		subExp, _ := time.Parse("01-2006", "06-2025")
+		trialEnd, _ := time.Parse("01-2006", "02-2025")
+		promoStart, _ := time.Parse("01-2006", "03-2025")
+		trialPrice := models.NewMoney(1, 0)
+		sub := &models.Subscription{
+			Name:       "trial",
+			Price:      models.NewMoney(200, 0),
+			UID:        uuid.New(),
+			Start:      subStart,
+			Expires:    &subExp,
+			TrialEnd:   &trialEnd,
+			TrialPrice: &trialPrice,
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, cli.AddPromo(context.Background(), sub.ID, &models.Promo{Kind: models.PromoPercent, Value: models.NewMoney(50, 0), Start: promoStart, Months: 1}))
+		assert.NoError(t, cli.AddPromo(context.Background(), sub.ID, &models.Promo{Kind: models.PromoFixed, Value: models.NewMoney(150, 0), Start: promoStart, Months: 2}))
+		// 1 for the trial month, 200, 50 twice with the last added promo, 200
+		sum, err := cli.PriceSum(context.Background(), map[string]interface{}{"uid": sub.UID}, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "501.00", sum.String())
+		promos, err := cli.ListPromos(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Len(t, promos, 2)
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("paused and resumed", func(t *testing.T) {
+		now := time.Now().UTC()
+		sub := &models.Subscription{
+			Name:  "paused",
+			Price: models.NewMoney(100, 0),
+			UID:   uuid.New(),
+			Start: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		filter := map[string]interface{}{"uid": sub.UID}
+		_, err := cli.PauseSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		_, err = cli.PauseSub(context.Background(), sub.ID)
+		assert.ErrorIs(t, err, errvalues.ErrAlreadyPaused)
+		got, err := cli.GetSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusPaused, got.Status)
+		// The only charge falls on the first paused day
+		sum, err := cli.PriceSum(context.Background(), filter, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.00", sum.String())
+		_, err = cli.ResumeSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		got, err = cli.GetSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusActive, got.Status)
+		sum, err = cli.PriceSum(context.Background(), filter, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "100.00", sum.String())
+		pauses, err := cli.ListPauses(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Len(t, pauses, 1)
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		now := time.Now().UTC()
+		sub := &models.Subscription{
+			Name:  "cancelled",
+			Price: models.NewMoney(100, 0),
+			UID:   uuid.New(),
+			Start: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		cancelled, err := cli.CancelSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, sub.Start.AddDate(0, 1, 0), *cancelled.Expires)
+		_, err = cli.CancelSub(context.Background(), sub.ID)
+		assert.ErrorIs(t, err, errvalues.ErrCancelled)
+		list, err := cli.ListSubs(context.Background(), &models.ListOpts{
+			Filter: map[string]interface{}{"uid": sub.UID},
+			Status: []string{models.StatusCancelled},
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, list, 1) {
+			assert.Equal(t, models.StatusCancelled, list[0].Status)
+		}
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("upcoming charges", func(t *testing.T) {
+		now := time.Now().UTC()
+		sub := &models.Subscription{
+			Name:  "renewing",
+			Price: models.NewMoney(100, 0),
+			UID:   uuid.New(),
+			Start: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		got, err := cli.GetSub(context.Background(), sub.ID)
+		assert.NoError(t, err)
+		assert.True(t, *got.AutoRenew)
+		assert.Equal(t, sub.Start, *got.NextChargeDate)
+		assert.Nil(t, got.RemainingCharges)
+		charges, err := cli.UpcomingCharges(context.Background(), map[string]interface{}{"uid": sub.UID}, 7)
+		assert.NoError(t, err)
+		if assert.Len(t, charges, 1) {
+			assert.Equal(t, sub.ID, charges[0].SubID)
+			assert.Equal(t, "100.00", charges[0].Amount.String())
+		}
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+	})
+	t.Run("webhook outbox", func(t *testing.T) {
+		uid := uuid.New()
+		endpoint := &models.WebhookEndpoint{URL: "https://example.com/hook", UID: &uid, Secret: "whsec_test"}
+		assert.NoError(t, cli.AddWebhook(context.Background(), endpoint))
+		sub := &models.Subscription{Name: "hooked", Price: models.NewMoney(100, 0), UID: uid, Start: time.Now().UTC().Truncate(24 * time.Hour)}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		_, err := cli.FanOutEvents(context.Background(), 1000)
+		assert.NoError(t, err)
+		dispatches, err := cli.ClaimDeliveries(context.Background(), 1000, time.Minute)
+		assert.NoError(t, err)
+		var claimed *models.Dispatch
+		for _, d := range dispatches {
+			if d.Event.SubID == sub.ID {
+				claimed = d
+			}
+		}
+		if assert.NotNil(t, claimed) {
+			assert.Equal(t, models.OpCreate, claimed.Event.Op)
+			assert.Equal(t, 1, claimed.Attempt)
+			assert.NoError(t, cli.CompleteDelivery(context.Background(), claimed.DeliveryID, &models.DispatchResult{Status: models.DeliveryDelivered}))
+		}
+		deliveries, err := cli.ListDeliveries(context.Background(), endpoint.ID, &models.DeliveryOpts{Status: models.DeliveryDelivered})
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.NoError(t, cli.DeleteWebhook(context.Background(), endpoint.ID))
+	})
+	t.Run("change stream", func(t *testing.T) {
+		uid := uuid.New()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		notified := make(chan uuid.UUID, 10)
+		go cli.ListenChanges(ctx, func(changed uuid.UUID) {
+			notified <- changed
+		})
+		select {
+		case changed := <-notified:
+			assert.Equal(t, uuid.Nil, changed)
+		case <-time.After(5 * time.Second):
+			t.Fatal("listening didn't start")
+		}
+		after, err := cli.LastChangeID(context.Background())
+		assert.NoError(t, err)
+		sub := &models.Subscription{Name: "streamed", Price: models.NewMoney(100, 0), UID: uid, Start: time.Now().UTC().Truncate(24 * time.Hour)}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, cli.DeleteSub(context.Background(), sub.ID))
+		select {
+		case changed := <-notified:
+			assert.Equal(t, uid, changed)
+		case <-time.After(5 * time.Second):
+			t.Fatal("change wasn't notified")
+		}
+		changes, err := cli.Changes(context.Background(), map[string]interface{}{"uid": uid}, after, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, changes, 2) {
+			assert.Equal(t, models.OpCreate, changes[0].Op)
+			assert.Equal(t, models.OpDelete, changes[1].Op)
+			changes, err = cli.Changes(context.Background(), nil, changes[1].ID, 10)
+			assert.NoError(t, err)
+			assert.Empty(t, changes)
+		}
+	})
 	t.Run("successfully listed", func(t *testing.T) {
 		t.Parallel()
-		result, err := cli.ListSubs(&models.ListOpts{
+		result, err := cli.ListSubs(context.Background(), &models.ListOpts{
 			Limit:  10,
 			Offset: 0,
 			Filter: nil,
@@ -274,7 +840,7 @@ func TestIntegrational(t *testing.T) {
 		filter := make(map[string]interface{})
 		filter["id"] = 2
 
-		result, err := cli.ListSubs(&models.ListOpts{
+		result, err := cli.ListSubs(context.Background(), &models.ListOpts{
 			Limit:  10,
 			Offset: 0,
 			Filter: filter,
@@ -287,7 +853,7 @@ func TestIntegrational(t *testing.T) {
 	})
 	t.Run("listed with limit and offset", func(t *testing.T) {
 		t.Parallel()
-		result, err := cli.ListSubs(&models.ListOpts{
+		result, err := cli.ListSubs(context.Background(), &models.ListOpts{
 			Limit:  5,
 			Offset: 3,
 			Filter: nil,
@@ -301,11 +867,65 @@ func TestIntegrational(t *testing.T) {
 			t.Log(*s)
 		}
 	})
+	t.Run("streamed with filters", func(t *testing.T) {
+		t.Parallel()
+		count := 0
+		for s, err := range cli.StreamSubs(context.Background(), &models.ListOpts{
+			Filter: map[string]interface{}{"uid": uid},
+			Order:  "id",
+		}) {
+			assert.NoError(t, err)
+			assert.Equal(t, uid, s.UID)
+			count++
+		}
+		assert.Equal(t, 10, count)
+	})
+	t.Run("deleted and restored", func(t *testing.T) {
+		t.Parallel()
+		sub := &models.Subscription{
+			Name:  "deleted",
+			Price: models.NewMoney(0, 0),
+			UID:   uuid.New(),
+			Start: start,
+		}
+		if err := cli.AddSub(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+		list, err := cli.ListSubs(context.Background(), &models.ListOpts{
+			Filter: map[string]interface{}{"uid": sub.UID},
+		})
+		if err != nil || len(list) != 1 {
+			t.Fatal("added subscription wasn't found")
+		}
+		id := list[0].ID
+		assert.NoError(t, cli.DeleteSub(context.Background(), id))
+		_, err = cli.GetSub(context.Background(), id)
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+		list, err = cli.ListSubs(context.Background(), &models.ListOpts{
+			Filter:         map[string]interface{}{"uid": sub.UID},
+			IncludeDeleted: true,
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, list, 1) {
+			assert.NotNil(t, list[0].DeletedAt)
+		}
+		assert.NoError(t, cli.RestoreSub(context.Background(), id))
+		_, err = cli.GetSub(context.Background(), id)
+		assert.NoError(t, err)
+		history, err := cli.ListAudit(context.Background(), &models.AuditOpts{SubID: id})
+		assert.NoError(t, err)
+		if assert.Len(t, history, 3) {
+			assert.Equal(t, models.OpRestore, history[0].Op)
+			assert.Equal(t, models.OpDelete, history[1].Op)
+			assert.Equal(t, models.OpCreate, history[2].Op)
+			assert.Nil(t, history[2].Before)
+		}
+	})
 	t.Run("got price sum", func(t *testing.T) {
 		t.Parallel()
-		expected := 4500
-		sum, err := cli.PriceSum(nil, nil)
+		expected := "4500.00"
+		sum, err := cli.PriceSum(context.Background(), nil, nil, nil)
 		assert.NoError(t, err)
-		assert.Equal(t, sum, expected)
+		assert.Equal(t, expected, sum.String())
 	})
 }
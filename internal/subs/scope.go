@@ -0,0 +1,34 @@
+package subs
+
+import (
+	"context"
+	"maps"
+	"testcase/internal/auth"
+
+	"github.com/google/uuid"
+)
+
+// Returns uid which queries must be restricted to. scoped is false
+// for admins and for calls without principal (background jobs)
+func ownerScope(ctx context.Context) (uid uuid.UUID, scoped bool) {
+	p, ok := auth.FromContext(ctx)
+	if !ok || p.Admin {
+		return uuid.Nil, false
+	}
+	return p.UID, true
+}
+
+// Returns copy of filter with uid forced to the principal's user
+// if the query must be scoped, otherwise filter itself
+func scopeFilter(ctx context.Context, filter map[string]interface{}) map[string]interface{} {
+	uid, scoped := ownerScope(ctx)
+	if !scoped {
+		return filter
+	}
+	scopedFilter := maps.Clone(filter)
+	if scopedFilter == nil {
+		scopedFilter = make(map[string]interface{}, 1)
+	}
+	scopedFilter["uid"] = uid
+	return scopedFilter
+}
@@ -0,0 +1,216 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testcase/internal/errvalues"
+	"testcase/models"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Interval between billing dates of subscription s
+	billingStep = `CASE s.billing_period WHEN 'weekly' THEN interval '1 week'
+WHEN 'quarterly' THEN interval '3 months' WHEN 'yearly' THEN interval '1 year' ELSE interval '1 month' END`
+	// Least number of days between billing dates of subscription s
+	billingStepDays = `CASE s.billing_period WHEN 'weekly' THEN 7
+WHEN 'quarterly' THEN 89 WHEN 'yearly' THEN 365 ELSE 28 END`
+	// Share of subscription s price falling on a month
+	monthlyShare = `CASE s.billing_period WHEN 'weekly' THEN 52 / 12.0
+WHEN 'quarterly' THEN 1 / 3.0 WHEN 'yearly' THEN 1 / 12.0 ELSE 1 END`
+	// Rate of the currency effective at charge c, joined with alias
+	rateJoin = `LEFT JOIN LATERAL (SELECT rate FROM exchange_rates
+WHERE currency = %s AND effective_from <= c.charged
+ORDER BY effective_from DESC LIMIT 1) %s ON true`
+	// Price of subscription s effective at charge c, joined as p
+	priceJoin = `LEFT JOIN LATERAL (SELECT cost FROM sub_prices
+WHERE sub_id = s.id AND effective_from <= c.charged
+ORDER BY effective_from DESC LIMIT 1) p ON true`
+	// Promo of subscription s active at charge c, joined as d
+	promoJoin = `LEFT JOIN LATERAL (SELECT kind, value FROM sub_promos
+WHERE sub_id = s.id AND starts <= c.charged AND c.charged < starts + months * interval '1 month'
+ORDER BY id DESC LIMIT 1) d ON true`
+	// Charge c of subscription s doesn't fall on paused days
+	notPaused = `NOT EXISTS (SELECT 1 FROM sub_pauses WHERE sub_id = s.id
+AND starts <= c.charged AND (ends IS NULL OR c.charged < ends))`
+	// Price of charge c of subscription s with trial and promo d applied
+	chargedPrice = `CASE WHEN c.charged < s.trial_end THEN COALESCE(s.trial_price, 0)
+WHEN d.kind = 'percent' THEN COALESCE(p.cost, s.cost) * (100 - d.value) / 100
+WHEN d.kind = 'fixed' THEN GREATEST(COALESCE(p.cost, s.cost) - d.value, 0)
+ELSE COALESCE(p.cost, s.cost) END`
+	// First day after the current month of subscription s user
+	localUntil = `(date_trunc('month', now() AT TIME ZONE ` + userZone + `) + interval '1 month')::date`
+)
+
+// Builds select of charges of subscriptions with currency and amount
+// columns, amount is converted to opts.Currency if it is set and is
+// NULL when there is no rate for the charge
+func chargesQuery(ctx context.Context, filter map[string]interface{}, period *models.RangeOpts, opts *models.SumOpts) squirrel.SelectBuilder {
+	filter = scopeFilter(ctx, filter)
+	// Charges are counted before the first day after the period,
+	// or after the current month in time zone of the user
+	until, untilArgs := localUntil, []any{}
+	if period != nil {
+		until, untilArgs = "?::date", []any{period.Until()}
+	}
+	amount, step, stepDays := `(`+chargedPrice+`)`, `(`+billingStep+`)`, billingStepDays
+	if opts.MonthlyEquivalent {
+		amount += ` * ` + monthlyShare
+		step, stepDays = `interval '1 month'`, `28`
+	}
+	var amountArgs []any
+	if opts.Prorate {
+		// Share of days of the billing cycle within the period
+		from, fromArgs := `c.charged`, []any{}
+		if period != nil {
+			from, fromArgs = `GREATEST(c.charged, ?::date)`, []any{period.Start}
+		}
+		amount += ` * CASE WHEN s.billing_period = 'one-time' THEN 1
+ELSE (LEAST(c.charged + ` + step + `, s.expires, ` + until + `)::date - ` + from + `::date)::numeric
+/ ((c.charged + ` + step + `)::date - c.charged::date) END`
+		amountArgs = append(append(amountArgs, untilArgs...), fromArgs...)
+	}
+	if opts.Currency != "" {
+		dstRate := "dst.rate"
+		if opts.Currency == models.BaseCurrency {
+			dstRate = "1"
+		}
+		amount += ` * CASE WHEN s.currency = ? THEN 1
+ELSE CASE WHEN s.currency = '` + models.BaseCurrency + `' THEN 1 ELSE src.rate END / ` + dstRate + ` END`
+		amountArgs = append(amountArgs, opts.Currency)
+	}
+	query := squirrel.Select("s.currency").
+		Column(squirrel.Expr(amount+" AS amount", amountArgs...)).
+		From("subscriptions s")
+	if period == nil {
+		query = query.JoinClause(`LEFT JOIN user_settings us ON us.uid = s.uid`)
+	}
+	// Billing dates are counted from the start date, so charges
+	// of subscription started on 31st don't drift to 28th
+	query = query.
+		JoinClause(`CROSS JOIN LATERAL (SELECT s.created_at + n * `+step+` AS charged
+FROM generate_series(0, CASE WHEN s.billing_period = 'one-time' THEN 0
+ELSE (LEAST(s.expires, `+until+`) - s.created_at) / `+stepDays+` END) AS n) c`, untilArgs...).
+		JoinClause(priceJoin).
+		JoinClause(promoJoin)
+	if opts.Currency != "" {
+		query = query.JoinClause(fmt.Sprintf(rateJoin, "s.currency", "src"))
+		if opts.Currency != models.BaseCurrency {
+			query = query.JoinClause(fmt.Sprintf(rateJoin, "?", "dst"), opts.Currency)
+		}
+	}
+	query = query.
+		Where(squirrel.Eq{"s.deleted_at": nil}).
+		Where("c.charged < LEAST(s.expires, "+until+")", untilArgs...).
+		Where(notPaused)
+	switch {
+	case period != nil && opts.Prorate:
+		// Cycles started before the period are counted by their days within it
+		query = query.Where(`c.charged + CASE WHEN s.billing_period = 'one-time'
+THEN interval '1 day' ELSE `+step+` END > ?`, period.Start)
+	case period != nil:
+		query = query.Where(squirrel.GtOrEq{"c.charged": period.Start})
+	}
+	if filter != nil {
+		query = query.Where(qualified(filter))
+	}
+	return query
+}
+
+// Qualifies filter columns with subscriptions alias s
+func qualified(filter map[string]interface{}) squirrel.Eq {
+	result := make(squirrel.Eq, len(filter))
+	for k, v := range filter {
+		result["s."+k] = v
+	}
+	return result
+}
+
+// Returns spend of subscriptions found with provided filter: price is
+// charged on every billing date of a subscription, starting from its start
+// date until expiration date, with the price effective in the month of
+// the charge. Charges before trial end are made with trial price, and
+// promo discount active in the month of the charge is applied to the
+// price. Charges dated within pauses are skipped. With
+// opts.MonthlyEquivalent every active month is charged with monthly
+// share of the price instead (one-time price is charged in the start
+// month). With opts.Prorate charges are spread over days of
+// their billing cycles. With opts.Currency every charge is converted at
+// the rate effective in its month, ErrNoRate is returned if some rate
+// is missing. The sum is rounded to minor units of opts.Currency, or of
+// BaseCurrency if prices are summed as is. Both period ends are included.
+// If filter is nil, returns spend of all subscriptions.
+// If period is nil, returns spend for all time up to the end of the
+// current month in time zone of subscription user.
+// For non-admin principal uid filter is forced to the principal's user
+func (cli *Client) PriceSum(ctx context.Context, filter map[string]interface{}, period *models.RangeOpts, opts *models.SumOpts) (models.Money, error) {
+	if opts == nil {
+		opts = &models.SumOpts{}
+	}
+	sql, args, err := squirrel.Select("SUM(amount)", "COUNT(*) FILTER (WHERE amount IS NULL)").
+		FromSelect(chargesQuery(ctx, filter, period, opts), "charges").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return models.Money{}, errors.New("building query error: " + err.Error())
+	}
+	var result models.Money
+	var unconverted int
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	if err = cli.conn.QueryRow(ctx, sql, args...).Scan(&result, &unconverted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Money{}, errvalues.ErrNoSuchRow
+		}
+		return models.Money{}, errors.New("getting subs sum error: " + err.Error())
+	}
+	if unconverted != 0 {
+		return models.Money{}, errvalues.ErrNoRate
+	}
+	currency := opts.Currency
+	if currency == "" {
+		currency = models.BaseCurrency
+	}
+	return result.Round(models.MinorUnits(currency)), nil
+}
+
+// Same as PriceSum without conversion, but returns spend
+// per original currency of subscriptions rounded to its minor units
+func (cli *Client) PriceTotals(ctx context.Context, filter map[string]interface{}, period *models.RangeOpts, opts *models.SumOpts) (map[string]models.Money, error) {
+	var noConversion models.SumOpts
+	if opts != nil {
+		noConversion = *opts
+	}
+	noConversion.Currency = ""
+	sql, args, err := squirrel.Select("currency", "SUM(amount)").
+		FromSelect(chargesQuery(ctx, filter, period, &noConversion), "charges").
+		GroupBy("currency").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting subs totals error: " + err.Error())
+	}
+	defer rows.Close()
+	totals := make(map[string]models.Money)
+	for rows.Next() {
+		var currency string
+		var total models.Money
+		if err = rows.Scan(&currency, &total); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		totals[currency] = total.Round(models.MinorUnits(currency))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading subs totals error: " + err.Error())
+	}
+	return totals, nil
+}
@@ -0,0 +1,119 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceSum(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	start, _ := time.Parse("01-2006", "01-2025")
+	end, _ := time.Parse("01-2006", "06-2025")
+	until := end.AddDate(0, 1, 0)
+	t.Run("with period and filter", func(t *testing.T) {
+		pool.ExpectQuery(`SELECT SUM\(amount\), COUNT\(\*\) FILTER \(WHERE amount IS NULL\) FROM \(SELECT s.currency, \(CASE WHEN c.charged < s.trial_end THEN COALESCE\(s.trial_price, 0\)(.+)ELSE COALESCE\(p.cost, s.cost\) END\) AS amount FROM subscriptions s CROSS JOIN LATERAL \(SELECT s.created_at \+ n \* \(CASE s.billing_period WHEN 'weekly' THEN interval '1 week'(.+)LEAST\(s.expires, \$1::date\)(.+)LEFT JOIN LATERAL(.+)LEFT JOIN LATERAL \(SELECT kind, value FROM sub_promos(.+)WHERE s.deleted_at IS NULL AND c.charged < LEAST\(s.expires, \$2::date\) AND NOT EXISTS \(SELECT 1 FROM sub_pauses WHERE sub_id = s.id AND starts <= c.charged AND \(ends IS NULL OR c.charged < ends\)\) AND c.charged >= \$3 AND s.name = \$4\) AS charges`).
+			WithArgs(until, until, start, "yandex").
+			WillReturnRows(pgxmock.NewRows([]string{"sum", "unconverted"}).AddRow(models.NewMoney(1200, 0), 0))
+		sum, err := cli.PriceSum(context.Background(), map[string]interface{}{"name": "yandex"}, &models.RangeOpts{Start: start, End: end}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "1200.00", sum.String())
+	})
+	t.Run("monthly equivalent", func(t *testing.T) {
+		pool.ExpectQuery(`COALESCE\(p.cost, s.cost\) END\) \* CASE s.billing_period WHEN 'weekly' THEN 52 / 12.0(.+)LEFT JOIN user_settings us ON us.uid = s.uid CROSS JOIN LATERAL \(SELECT s.created_at \+ n \* interval '1 month' AS charged(.+)/ 28 END\) AS n\) c`).
+			WillReturnRows(pgxmock.NewRows([]string{"sum", "unconverted"}).AddRow(models.MustParseMoney("1299.99666666666666666667"), 0))
+		sum, err := cli.PriceSum(context.Background(), nil, nil, &models.SumOpts{MonthlyEquivalent: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "1300.00", sum.String())
+	})
+	t.Run("prorated by days", func(t *testing.T) {
+		period := &models.RangeOpts{Start: start.AddDate(0, 0, 16), End: end.AddDate(0, 0, 9), DayPrecision: true}
+		dayUntil := end.AddDate(0, 0, 10)
+		pool.ExpectQuery(`COALESCE\(p.cost, s.cost\) END\) \* CASE WHEN s.billing_period = 'one-time' THEN 1 ELSE \(LEAST\(c.charged \+ \((.+)\), s.expires, \$1::date\)::date - GREATEST\(c.charged, \$2::date\)::date\)::numeric(.+)AS amount(.+)WHERE s.deleted_at IS NULL AND c.charged < LEAST\(s.expires, \$4::date\) AND NOT EXISTS \((.+)\) AND c.charged \+ CASE WHEN s.billing_period = 'one-time' THEN interval '1 day' ELSE \((.+)\) END > \$5\) AS charges`).
+			WithArgs(dayUntil, period.Start, dayUntil, dayUntil, period.Start).
+			WillReturnRows(pgxmock.NewRows([]string{"sum", "unconverted"}).AddRow(models.MustParseMoney("733.3333"), 0))
+		sum, err := cli.PriceSum(context.Background(), nil, period, &models.SumOpts{Prorate: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "733.33", sum.String())
+	})
+	t.Run("converted", func(t *testing.T) {
+		pool.ExpectQuery(`CASE WHEN s.currency = \$1 THEN 1 ELSE CASE WHEN s.currency = 'RUB' THEN 1 ELSE src.rate END / dst.rate END AS amount(.+)\) src ON true LEFT JOIN LATERAL \(SELECT rate FROM exchange_rates WHERE currency = \$3 (.+)\) dst ON true`).
+			WithArgs("USD", until, "USD", until, start).
+			WillReturnRows(pgxmock.NewRows([]string{"sum", "unconverted"}).AddRow(models.MustParseMoney("15.4449"), 0))
+		sum, err := cli.PriceSum(context.Background(), nil, &models.RangeOpts{Start: start, End: end}, &models.SumOpts{Currency: "USD"})
+		assert.NoError(t, err)
+		assert.Equal(t, "15.44", sum.String())
+	})
+	t.Run("missing rate", func(t *testing.T) {
+		pool.ExpectQuery(`src.rate END / 1 END AS amount`).
+			WithArgs(models.BaseCurrency).
+			WillReturnRows(pgxmock.NewRows([]string{"sum", "unconverted"}).AddRow(models.NewMoney(1000, 0), 2))
+		_, err := cli.PriceSum(context.Background(), nil, nil, &models.SumOpts{Currency: models.BaseCurrency})
+		assert.ErrorIs(t, err, errvalues.ErrNoRate)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT (.+) FROM subscriptions s").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.PriceSum(context.Background(), nil, nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestPriceTotals(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(`SELECT currency, SUM\(amount\) FROM \(SELECT s.currency, \(CASE WHEN c.charged < s.trial_end THEN COALESCE\(s.trial_price, 0\)(.+)ELSE COALESCE\(p.cost, s.cost\) END\) AS amount (.+)\) AS charges GROUP BY currency`).
+			WillReturnRows(pgxmock.NewRows([]string{"currency", "sum"}).
+				AddRow("RUB", models.MustParseMoney("1200.005")).
+				AddRow("USD", models.NewMoney(10, 0)))
+		totals, err := cli.PriceTotals(context.Background(), nil, nil, &models.SumOpts{Currency: "USD"})
+		assert.NoError(t, err)
+		if assert.Len(t, totals, 2) {
+			assert.Equal(t, "1200.01", totals["RUB"].String())
+			assert.Equal(t, "10.00", totals["USD"].String())
+		}
+	})
+	t.Run("prorated without conversion", func(t *testing.T) {
+		start, _ := time.Parse("01-2006", "01-2025")
+		end, _ := time.Parse("01-2006", "06-2025")
+		period := &models.RangeOpts{Start: start.AddDate(0, 0, 16), End: end.AddDate(0, 0, 9), DayPrecision: true}
+		dayUntil := end.AddDate(0, 0, 10)
+		pool.ExpectQuery(`SELECT currency, SUM\(amount\) FROM \(SELECT s.currency, \(CASE WHEN c.charged < s.trial_end THEN COALESCE\(s.trial_price, 0\)(.+)ELSE COALESCE\(p.cost, s.cost\) END\) \* CASE WHEN s.billing_period = 'one-time' THEN 1 ELSE (.+) AS amount (.+)\) AS charges GROUP BY currency`).
+			WithArgs(dayUntil, period.Start, dayUntil, dayUntil, period.Start).
+			WillReturnRows(pgxmock.NewRows([]string{"currency", "sum"}).
+				AddRow("RUB", models.MustParseMoney("733.3333")))
+		totals, err := cli.PriceTotals(context.Background(), nil, period, &models.SumOpts{Currency: "USD", Prorate: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "733.33", totals["RUB"].String())
+		assert.NoError(t, pool.ExpectationsWereMet())
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT currency").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.PriceTotals(context.Background(), nil, nil, nil)
+		assert.Error(t, err)
+	})
+}
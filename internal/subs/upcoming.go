@@ -0,0 +1,70 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// Builds select of charges due in days starting from the current day
+// in time zone of subscription user with id, uid, name, currency,
+// charged and amount columns
+func dueChargesQuery(days int) squirrel.SelectBuilder {
+	until := `LEAST(s.expires, t.today + ?::int)`
+	return squirrel.Select("s.id", "s.uid", "s.name", "s.currency", "c.charged", "("+chargedPrice+") AS amount").
+		From("subscriptions s").
+		JoinClause(`LEFT JOIN user_settings us ON us.uid = s.uid`).
+		JoinClause(localToday).
+		JoinClause(`CROSS JOIN LATERAL (SELECT s.created_at + n * (`+billingStep+`) AS charged
+FROM generate_series(0, CASE WHEN s.billing_period = 'one-time' THEN 0
+ELSE (`+until+` - s.created_at) / `+billingStepDays+` END) AS n) c`, days).
+		JoinClause(priceJoin).
+		JoinClause(promoJoin).
+		Where(squirrel.Eq{"s.deleted_at": nil}).
+		Where("c.charged >= t.today").
+		Where("c.charged < "+until, days).
+		Where(notPaused)
+}
+
+// Returns charges of subscriptions found with provided filter due in
+// days starting from the current day in time zone of subscription user,
+// ordered by date. Charges are counted the same way as in PriceSum, so
+// trial, promo and pauses are respected, and amounts are rounded to minor
+// units of subscription currency. If filter is nil, returns charges of all
+// subscriptions. For non-admin principal uid filter is forced to the
+// principal's user
+func (cli *Client) UpcomingCharges(ctx context.Context, filter map[string]interface{}, days int) ([]*models.Charge, error) {
+	filter = scopeFilter(ctx, filter)
+	query := dueChargesQuery(days)
+	if filter != nil {
+		query = query.Where(qualified(filter))
+	}
+	sql, args, err := query.OrderBy("c.charged", "s.id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().aggregate)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting upcoming charges error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.Charge, 0)
+	for rows.Next() {
+		c := models.Charge{}
+		if err = rows.Scan(&c.SubID, &c.UID, &c.Name, &c.Currency, &c.Date, &c.Amount); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		c.Amount = c.Amount.Round(models.MinorUnits(c.Currency))
+		result = append(result, &c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading upcoming charges error: " + err.Error())
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/auth"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpcomingCharges(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	date, _ := models.ParseDate("2025-09-17")
+	columns := []string{"id", "uid", "name", "currency", "charged", "amount"}
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(`SELECT s.id, s.uid, s.name, s.currency, c.charged, \(CASE WHEN c.charged < s.trial_end(.+)\) AS amount FROM subscriptions s LEFT JOIN user_settings us ON us.uid = s.uid CROSS JOIN LATERAL \(SELECT \(now\(\) AT TIME ZONE COALESCE\(us.time_zone, 'UTC'\)\)::date AS today\) t CROSS JOIN LATERAL \((.+)LEAST\(s.expires, t.today \+ \$1::int\)(.+)WHERE s.deleted_at IS NULL AND c.charged >= t.today AND c.charged < LEAST\(s.expires, t.today \+ \$2::int\) AND NOT EXISTS \((.+)\) AND s.uid = \$3 ORDER BY c.charged, s.id`).
+			WithArgs(30, 30, uid.String()).
+			WillReturnRows(pgxmock.NewRows(columns).
+				AddRow(1, uid, "yandex", models.BaseCurrency, date, models.MustParseMoney("199.995")).
+				AddRow(2, uid, "okko", "JPY", date, models.MustParseMoney("300.4")))
+		charges, err := cli.UpcomingCharges(context.Background(), map[string]interface{}{"uid": uid.String()}, 30)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Charge{
+			{SubID: 1, UID: uid, Name: "yandex", Date: date, Amount: models.MustParseMoney("200.00"), Currency: models.BaseCurrency},
+			{SubID: 2, UID: uid, Name: "okko", Date: date, Amount: models.MustParseMoney("300"), Currency: "JPY"},
+		}, charges)
+	})
+	t.Run("scoped to principal's user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UID: uid})
+		pool.ExpectQuery(`AND s.uid = \$3 ORDER BY c.charged, s.id`).
+			WithArgs(7, 7, uid.String()).
+			WillReturnRows(pgxmock.NewRows(columns))
+		charges, err := cli.UpcomingCharges(ctx, nil, 7)
+		assert.NoError(t, err)
+		assert.Empty(t, charges)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("SELECT (.+) FROM subscriptions s").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.UpcomingCharges(context.Background(), nil, 30)
+		assert.Error(t, err)
+	})
+}
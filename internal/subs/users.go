@@ -0,0 +1,71 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Time zone of subscription s user, joined as us
+	userZone = `COALESCE(us.time_zone, '` + models.DefaultTimeZone + `')`
+	// Current day of subscription s user, joined as t
+	localToday = `CROSS JOIN LATERAL (SELECT (now() AT TIME ZONE ` + userZone + `)::date AS today) t`
+)
+
+// Returns settings of the user, defaults are
+// returned if the user has no saved settings
+func (cli *Client) GetUserSettings(ctx context.Context, uid uuid.UUID) (*models.UserSettings, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	result := models.UserSettings{UID: uid, TimeZone: models.DefaultTimeZone}
+	err := cli.conn.QueryRow(ctx, `SELECT time_zone, COALESCE(email, '') FROM user_settings WHERE uid = $1;`, uid).Scan(&result.TimeZone, &result.Email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.New("getting user settings error: " + err.Error())
+	}
+	return &result, nil
+}
+
+// Saves settings of the user replacing previous ones
+func (cli *Client) SetUserSettings(ctx context.Context, settings *models.UserSettings) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	_, err := cli.conn.Exec(ctx, `INSERT INTO user_settings (uid, time_zone, email) VALUES ($1, $2, NULLIF($3, ''))
+ON CONFLICT (uid) DO UPDATE SET time_zone = EXCLUDED.time_zone, email = EXCLUDED.email, updated_at = now();`,
+		settings.UID, settings.TimeZone, settings.Email)
+	if err != nil {
+		return errors.New("saving user settings error: " + err.Error())
+	}
+	return nil
+}
+
+// Returns the first day of the current month in time zone of the user
+func localMonth(ctx context.Context, tx pgx.Tx, uid uuid.UUID) (time.Time, error) {
+	var month time.Time
+	err := tx.QueryRow(ctx, `SELECT date_trunc('month', now() AT TIME ZONE COALESCE(
+(SELECT time_zone FROM user_settings WHERE uid = $1), '`+models.DefaultTimeZone+`'))::date;`, uid).Scan(&month)
+	if err != nil {
+		return time.Time{}, errors.New("getting user's current month error: " + err.Error())
+	}
+	return month, nil
+}
+
+// Returns the current day in time zone of the user
+func localDay(ctx context.Context, tx pgx.Tx, uid uuid.UUID) (time.Time, error) {
+	var day time.Time
+	err := tx.QueryRow(ctx, `SELECT (now() AT TIME ZONE COALESCE(
+(SELECT time_zone FROM user_settings WHERE uid = $1), '`+models.DefaultTimeZone+`'))::date;`, uid).Scan(&day)
+	if err != nil {
+		return time.Time{}, errors.New("getting user's current day error: " + err.Error())
+	}
+	return day, nil
+}
+
+// Returns the first day of the month of t
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,78 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserSettings(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	query := regexp.QuoteMeta(`SELECT time_zone, COALESCE(email, '') FROM user_settings WHERE uid = $1`)
+	t.Run("saved", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs(uid).
+			WillReturnRows(pgxmock.NewRows([]string{"time_zone", "email"}).AddRow("Europe/Moscow", "user@example.com"))
+		result, err := cli.GetUserSettings(context.Background(), uid)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.UserSettings{UID: uid, TimeZone: "Europe/Moscow", Email: "user@example.com"}, result)
+	})
+	t.Run("defaults", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs(uid).
+			WillReturnError(pgx.ErrNoRows)
+		result, err := cli.GetUserSettings(context.Background(), uid)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.UserSettings{UID: uid, TimeZone: models.DefaultTimeZone}, result)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WithArgs(uid).
+			WillReturnError(errors.New("db error"))
+		_, err := cli.GetUserSettings(context.Background(), uid)
+		assert.Error(t, err)
+	})
+}
+
+func TestSetUserSettings(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	settings := &models.UserSettings{UID: uuid.New(), TimeZone: "Europe/Moscow", Email: "user@example.com"}
+	query := regexp.QuoteMeta(`INSERT INTO user_settings (uid, time_zone, email) VALUES ($1, $2, NULLIF($3, ''))`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(settings.UID, settings.TimeZone, settings.Email).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		assert.NoError(t, cli.SetUserSettings(context.Background(), settings))
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(settings.UID, settings.TimeZone, settings.Email).
+			WillReturnError(errors.New("db error"))
+		assert.Error(t, cli.SetUserSettings(context.Background(), settings))
+	})
+}
@@ -0,0 +1,170 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"testcase/internal/errvalues"
+	"testcase/models"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// Saves new webhook endpoint with its secret, sets endpoint's ID and CreatedAt
+func (cli *Client) AddWebhook(ctx context.Context, e *models.WebhookEndpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	err := cli.conn.QueryRow(ctx, `INSERT INTO webhook_endpoints (url, secret, events, uid) VALUES
+($1, $2, COALESCE($3::text[], '{}'), $4) RETURNING id, created_at;`, e.URL, e.Secret, e.Events, e.UID).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return errors.New("error inserting webhook: " + err.Error())
+	}
+	return nil
+}
+
+// Returns all webhook endpoints without secrets
+func (cli *Client) ListWebhooks(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, `SELECT id, url, events, uid, created_at FROM webhook_endpoints ORDER BY id;`)
+	if err != nil {
+		return nil, errors.New("getting webhooks error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.WebhookEndpoint, 0)
+	for rows.Next() {
+		var e models.WebhookEndpoint
+		if err = rows.Scan(&e.ID, &e.URL, &e.Events, &e.UID, &e.CreatedAt); err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &e)
+	}
+	return result, rows.Err()
+}
+
+// Deletes webhook endpoint with provided id together with its
+// delivery log, if there is no any returns ErrNoSuchRow
+func (cli *Client) DeleteWebhook(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1;`, id)
+	if err != nil {
+		return errors.New("deleting webhook error: " + err.Error())
+	} else if tag.RowsAffected() == 0 {
+		return errvalues.ErrNoSuchRow
+	}
+	return nil
+}
+
+// Returns deliveries to webhook endpoint with provided id matching
+// opts, newest first. If there is no such endpoint returns ErrNoSuchRow
+func (cli *Client) ListDeliveries(ctx context.Context, id int, opts *models.DeliveryOpts) ([]*models.Delivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	var exists bool
+	err := cli.conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_endpoints WHERE id = $1);`, id).Scan(&exists)
+	if err != nil {
+		return nil, errors.New("getting webhook error: " + err.Error())
+	} else if !exists {
+		return nil, errvalues.ErrNoSuchRow
+	}
+	filter := squirrel.Eq{"endpoint_id": id}
+	if opts.Status != "" {
+		filter["status"] = opts.Status
+	}
+	query := squirrel.Select("id, endpoint_id, event_id, status, attempts, response_status, last_error, next_attempt_at, created_at, updated_at").
+		From("webhook_deliveries").
+		Where(filter).
+		OrderBy("id DESC").
+		Offset(uint64(opts.Offset))
+	if opts.Limit != 0 {
+		query = query.Limit(uint64(opts.Limit))
+	}
+	sql, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.New("building query error: " + err.Error())
+	}
+	rows, err := cli.conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.New("getting deliveries error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.Delivery, 0)
+	for rows.Next() {
+		var d models.Delivery
+		err = rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		result = append(result, &d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading deliveries error: " + err.Error())
+	}
+	return result, nil
+}
+
+// Takes up to limit changes from webhook outbox and schedules their
+// deliveries to matching endpoints, returns number of scheduled ones
+func (cli *Client) FanOutEvents(ctx context.Context, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	tag, err := cli.conn.Exec(ctx, `WITH events AS (DELETE FROM webhook_outbox WHERE audit_id IN
+(SELECT audit_id FROM webhook_outbox ORDER BY audit_id LIMIT $1 FOR UPDATE SKIP LOCKED) RETURNING audit_id)
+INSERT INTO webhook_deliveries (endpoint_id, event_id)
+SELECT e.id, a.id FROM events JOIN audit_log a ON a.id = events.audit_id
+JOIN webhook_endpoints e ON (e.uid IS NULL OR e.uid = a.uid) AND (cardinality(e.events) = 0 OR a.op = ANY(e.events))
+ORDER BY a.id, e.id;`, limit)
+	if err != nil {
+		return 0, errors.New("fanning out events error: " + err.Error())
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Claims up to limit pending deliveries which are due and counts their
+// attempts. Claimed deliveries aren't claimed again for lease, so they
+// are retried if the claimer fails to complete them
+func (cli *Client) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.Dispatch, error) {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	rows, err := cli.conn.Query(ctx, `UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+FROM webhook_endpoints e, audit_log a
+WHERE d.id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= now()
+ORDER BY next_attempt_at, id LIMIT $1 FOR UPDATE SKIP LOCKED)
+AND e.id = d.endpoint_id AND a.id = d.event_id
+RETURNING d.id, d.attempts, e.url, e.secret, a.id, a.sub_id, a.uid, a.actor, a.request_id, a.op, a.before, a.after, a.created_at;`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, errors.New("claiming deliveries error: " + err.Error())
+	}
+	defer rows.Close()
+	result := make([]*models.Dispatch, 0)
+	for rows.Next() {
+		var d models.Dispatch
+		var before, after []byte
+		err = rows.Scan(&d.DeliveryID, &d.Attempt, &d.URL, &d.Secret, &d.Event.ID, &d.Event.SubID, &d.Event.UID,
+			&d.Event.Actor, &d.Event.RequestID, &d.Event.Op, &before, &after, &d.Event.CreatedAt)
+		if err != nil {
+			return nil, errors.New("error converting rows error: " + err.Error())
+		}
+		d.Event.Before, d.Event.After = before, after
+		result = append(result, &d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("reading claimed deliveries error: " + err.Error())
+	}
+	return result, nil
+}
+
+// Saves result of delivery with provided id
+func (cli *Client) CompleteDelivery(ctx context.Context, id int64, res *models.DispatchResult) error {
+	ctx, cancel := context.WithTimeout(ctx, cli.timeouts.Load().query)
+	defer cancel()
+	_, err := cli.conn.Exec(ctx, `UPDATE webhook_deliveries SET status = $2, response_status = $3, last_error = $4,
+next_attempt_at = $5, updated_at = now() WHERE id = $1;`, id, res.Status, res.ResponseStatus, res.Error, res.NextAttemptAt)
+	if err != nil {
+		return errors.New("completing delivery error: " + err.Error())
+	}
+	return nil
+}
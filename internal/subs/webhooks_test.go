@@ -0,0 +1,177 @@
+package subs_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testcase/internal/errvalues"
+	"testcase/internal/subs"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddWebhook(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	now := time.Now()
+	query := regexp.QuoteMeta(`INSERT INTO webhook_endpoints (url, secret, events, uid) VALUES
+($1, $2, COALESCE($3::text[], '{}'), $4) RETURNING id, created_at;`)
+	t.Run("successful", func(t *testing.T) {
+		e := &models.WebhookEndpoint{URL: "https://example.com/hook", Events: []string{models.OpCreate}, UID: &uid, Secret: "whsec_test"}
+		pool.ExpectQuery(query).
+			WithArgs(e.URL, e.Secret, e.Events, e.UID).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+		assert.NoError(t, cli.AddWebhook(context.Background(), e))
+		assert.Equal(t, 1, e.ID)
+		assert.Equal(t, now, e.CreatedAt)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery(query).
+			WillReturnError(errors.New("db error"))
+		assert.Error(t, cli.AddWebhook(context.Background(), &models.WebhookEndpoint{}))
+	})
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	query := regexp.QuoteMeta(`DELETE FROM webhook_endpoints WHERE id = $1;`)
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(1).
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		assert.NoError(t, cli.DeleteWebhook(context.Background(), 1))
+	})
+	t.Run("no such webhook", func(t *testing.T) {
+		pool.ExpectExec(query).
+			WithArgs(2).
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+		assert.ErrorIs(t, cli.DeleteWebhook(context.Background(), 2), errvalues.ErrNoSuchRow)
+	})
+}
+
+func TestListDeliveries(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	now := time.Now()
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM webhook_endpoints WHERE id = $1);`)
+	t.Run("successful", func(t *testing.T) {
+		status, msg := 502, "endpoint responded with status 502"
+		pool.ExpectQuery(existsQuery).
+			WithArgs(1).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+		pool.ExpectQuery(regexp.QuoteMeta(`SELECT id, endpoint_id, event_id, status, attempts, response_status, last_error, next_attempt_at, created_at, updated_at FROM webhook_deliveries WHERE endpoint_id = $1 AND status = $2 ORDER BY id DESC LIMIT 10 OFFSET 5`)).
+			WithArgs(1, models.DeliveryPending).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "endpoint_id", "event_id", "status", "attempts", "response_status", "last_error", "next_attempt_at", "created_at", "updated_at"}).
+				AddRow(int64(3), 1, int64(7), models.DeliveryPending, 2, &status, &msg, &now, now, now))
+		result, err := cli.ListDeliveries(context.Background(), 1, &models.DeliveryOpts{Status: models.DeliveryPending, Limit: 10, Offset: 5})
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Delivery{{
+			ID: 3, EndpointID: 1, EventID: 7, Status: models.DeliveryPending, Attempts: 2,
+			ResponseStatus: &status, LastError: &msg, NextAttemptAt: &now, CreatedAt: now, UpdatedAt: now,
+		}}, result)
+	})
+	t.Run("no such webhook", func(t *testing.T) {
+		pool.ExpectQuery(existsQuery).
+			WithArgs(2).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+		_, err := cli.ListDeliveries(context.Background(), 2, &models.DeliveryOpts{})
+		assert.ErrorIs(t, err, errvalues.ErrNoSuchRow)
+	})
+}
+
+func TestFanOutEvents(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	pool.ExpectExec(`WITH events AS \(DELETE FROM webhook_outbox (.+) FOR UPDATE SKIP LOCKED\) RETURNING audit_id\) INSERT INTO webhook_deliveries \(endpoint_id, event_id\) SELECT e.id, a.id FROM events (.+)a.op = ANY\(e.events\)\)`).
+		WithArgs(100).
+		WillReturnResult(pgxmock.NewResult("INSERT", 4))
+	n, err := cli.FanOutEvents(context.Background(), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+}
+
+func TestClaimDeliveries(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	uid := uuid.New()
+	now := time.Now()
+	t.Run("successful", func(t *testing.T) {
+		pool.ExpectQuery(`UPDATE webhook_deliveries d SET attempts = d.attempts \+ 1, next_attempt_at = now\(\) \+ make_interval\(secs => \$2\)(.+)FOR UPDATE SKIP LOCKED\)`).
+			WithArgs(100, 20.0).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "attempts", "url", "secret", "event_id", "sub_id", "uid", "actor", "request_id", "op", "before", "after", "created_at"}).
+				AddRow(int64(3), 1, "https://example.com/hook", "whsec_test", int64(7), 1, uid, "apikey:1", nil, models.OpDelete, []byte(`{"id":1}`), nil, now))
+		result, err := cli.ClaimDeliveries(context.Background(), 100, 20*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, []*models.Dispatch{{
+			DeliveryID: 3, Attempt: 1, URL: "https://example.com/hook", Secret: "whsec_test",
+			Event: models.AuditRecord{ID: 7, SubID: 1, UID: uid, Actor: "apikey:1", Op: models.OpDelete, Before: []byte(`{"id":1}`), CreatedAt: now},
+		}}, result)
+	})
+	t.Run("db error", func(t *testing.T) {
+		pool.ExpectQuery("UPDATE webhook_deliveries").
+			WillReturnError(errors.New("db error"))
+		_, err := cli.ClaimDeliveries(context.Background(), 100, 20*time.Second)
+		assert.Error(t, err)
+	})
+}
+
+func TestCompleteDelivery(t *testing.T) {
+	t.Parallel()
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+	})
+	cli := subs.NewWithConn(pool)
+	status := 200
+	res := &models.DispatchResult{Status: models.DeliveryDelivered, ResponseStatus: &status}
+	pool.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status = $2, response_status = $3, last_error = $4,
+next_attempt_at = $5, updated_at = now() WHERE id = $1;`)).
+		WithArgs(int64(3), models.DeliveryDelivered, &status, (*string)(nil), (*time.Time)(nil)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	assert.NoError(t, cli.CompleteDelivery(context.Background(), 3, res))
+}
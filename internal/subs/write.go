@@ -0,0 +1,303 @@
+package subs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testcase/internal/errvalues"
+	"testcase/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Every change of subscriptions goes through functions below, which take
+// transaction (or savepoint) and record the change in audit log within it
+
+// Runs fn in transaction with provided timeout, commits if fn succeeded
+func (cli *Client) inTx(ctx context.Context, timeout time.Duration, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tx, err := cli.conn.Begin(ctx)
+	if err != nil {
+		return errors.New("beginning transaction error: " + err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback(context.WithoutCancel(ctx))
+	}()
+	if err = fn(ctx, tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return errors.New("committing transaction error: " + err.Error())
+	}
+	return nil
+}
+
+// Selects row for update with the price effective on the current day of
+// its user, deleted rows are selected only if deleted is true.
+// If there is no such row (or it belongs to another user) returns ErrNoSuchRow
+func lockSub(ctx context.Context, tx pgx.Tx, id int, deleted bool) (*models.Subscription, error) {
+	query := `SELECT id, uid, name, ` + subPrice + `, created_at, expires, billing_period, currency, trial_end, trial_price, ` + subStatus + `, deleted_at FROM subscriptions ` + subPriceJoin + ` WHERE id = $1`
+	if deleted {
+		query += ` AND deleted_at IS NOT NULL`
+	} else {
+		query += ` AND deleted_at IS NULL`
+	}
+	args := []any{id}
+	if uid, scoped := ownerScope(ctx); scoped {
+		query += ` AND uid = $2`
+		args = append(args, uid)
+	}
+	var s models.Subscription
+	err := tx.QueryRow(ctx, query+` FOR UPDATE OF subscriptions;`, args...).
+		Scan(&s.ID, &s.UID, &s.Name, &s.Price, &s.Start, &s.Expires, &s.BillingPeriod, &s.Currency, &s.TrialEnd, &s.TrialPrice, &s.Status, &s.DeletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errvalues.ErrNoSuchRow
+		}
+		return nil, errors.New("locking sub error: " + err.Error())
+	}
+	return &s, nil
+}
+
+// Fills defaults of written subscription, uid of
+// non-admin principal is forced into s
+func prepareSub(ctx context.Context, s *models.Subscription) {
+	if uid, scoped := ownerScope(ctx); scoped {
+		s.UID = uid
+	}
+	if s.BillingPeriod == "" {
+		s.BillingPeriod = models.BillingMonthly
+	}
+	if s.Currency == "" {
+		s.Currency = models.BaseCurrency
+	}
+}
+
+// Inserts subscription and sets its ID. For non-admin principal
+// subscription is always created for the principal's user
+func insertSub(ctx context.Context, tx pgx.Tx, s *models.Subscription) error {
+	prepareSub(ctx, s)
+	err := tx.QueryRow(ctx, `INSERT INTO subscriptions (uid, name, cost, created_at, expires, billing_period, currency, trial_end, trial_price) VALUES
+($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`,
+		s.UID, s.Name, s.Price, s.Start, s.Expires, s.BillingPeriod, s.Currency, s.TrialEnd, s.TrialPrice).Scan(&s.ID)
+	if err != nil {
+		return errors.New("error inserting sub: " + err.Error())
+	}
+	if err = setPrice(ctx, tx, s.ID, &models.PriceChange{Price: s.Price, EffectiveFrom: monthOf(s.Start)}); err != nil {
+		return err
+	}
+	return recordChange(ctx, tx, models.OpCreate, nil, s)
+}
+
+func updateSub(ctx context.Context, tx pgx.Tx, id int, s *models.Subscription) error {
+	before, err := lockSub(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+	prepareSub(ctx, s)
+	_, err = tx.Exec(ctx, `UPDATE subscriptions SET uid = $1, name = $2, cost = $3, created_at = $4, expires = $5, billing_period = $6, currency = $7, trial_end = $8, trial_price = $9 WHERE id = $10;`,
+		s.UID, s.Name, s.Price, s.Start, s.Expires, s.BillingPeriod, s.Currency, s.TrialEnd, s.TrialPrice, id)
+	if err != nil {
+		return errors.New("error updating subscription: " + err.Error())
+	}
+	// Months already passed keep the price they were charged with.
+	// before.Price is the effective one, so scheduled prices in force
+	// are replaced too
+	if s.Price.Cmp(before.Price) != 0 {
+		month, err := localMonth(ctx, tx, s.UID)
+		if err != nil {
+			return err
+		}
+		change := &models.PriceChange{Price: s.Price, EffectiveFrom: month}
+		if start := monthOf(s.Start); start.After(month) {
+			change.EffectiveFrom = start
+		}
+		if err = setPrice(ctx, tx, id, change); err != nil {
+			return err
+		}
+	}
+	after := *s
+	after.ID, after.Status = id, before.Status
+	return recordChange(ctx, tx, models.OpUpdate, before, &after)
+}
+
+func deleteSub(ctx context.Context, tx pgx.Tx, id int) error {
+	before, err := lockSub(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+	after := *before
+	err = tx.QueryRow(ctx, `UPDATE subscriptions SET deleted_at = now() WHERE id = $1 RETURNING deleted_at;`, id).
+		Scan(&after.DeletedAt)
+	if err != nil {
+		return errors.New("deleting sub error: " + err.Error())
+	}
+	return recordChange(ctx, tx, models.OpDelete, before, &after)
+}
+
+func restoreSub(ctx context.Context, tx pgx.Tx, id int) error {
+	before, err := lockSub(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE subscriptions SET deleted_at = NULL WHERE id = $1;`, id); err != nil {
+		return errors.New("restoring sub error: " + err.Error())
+	}
+	after := *before
+	after.DeletedAt = nil
+	return recordChange(ctx, tx, models.OpRestore, before, &after)
+}
+
+// Opens pause of subscription from the current day of its user
+func pauseSub(ctx context.Context, tx pgx.Tx, id int) (*models.Pause, error) {
+	before, err := lockSub(ctx, tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	switch before.Status {
+	case models.StatusPaused:
+		return nil, errvalues.ErrAlreadyPaused
+	case models.StatusCancelled, models.StatusExpired:
+		return nil, errvalues.ErrCancelled
+	}
+	day, err := localDay(ctx, tx, before.UID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(ctx, `INSERT INTO sub_pauses (sub_id, starts) VALUES ($1, $2);`, id, day); err != nil {
+		return nil, errors.New("pausing sub error: " + err.Error())
+	}
+	after := *before
+	after.Status = models.StatusPaused
+	return &models.Pause{Start: day}, recordChange(ctx, tx, models.OpPause, before, &after)
+}
+
+// Closes open pause of subscription on the current day of its user
+func resumeSub(ctx context.Context, tx pgx.Tx, id int) (*models.Pause, error) {
+	before, err := lockSub(ctx, tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != models.StatusPaused {
+		return nil, errvalues.ErrNotPaused
+	}
+	day, err := localDay(ctx, tx, before.UID)
+	if err != nil {
+		return nil, err
+	}
+	var pause models.Pause
+	err = tx.QueryRow(ctx, `UPDATE sub_pauses SET ends = GREATEST(starts, $2) WHERE sub_id = $1 AND ends IS NULL RETURNING starts, ends;`, id, day).
+		Scan(&pause.Start, &pause.End)
+	if err != nil {
+		return nil, errors.New("resuming sub error: " + err.Error())
+	}
+	after := *before
+	after.Status = models.StatusActive
+	return &pause, recordChange(ctx, tx, models.OpResume, before, &after)
+}
+
+// Sets expiration of subscription to the end of its current billing
+// period and closes open pause on the current day of its user
+func cancelSub(ctx context.Context, tx pgx.Tx, id int) (*models.Subscription, error) {
+	before, err := lockSub(ctx, tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status == models.StatusCancelled || before.Status == models.StatusExpired {
+		return nil, errvalues.ErrCancelled
+	}
+	day, err := localDay(ctx, tx, before.UID)
+	if err != nil {
+		return nil, err
+	}
+	after := *before
+	if end := before.PeriodEnd(day); before.Expires == nil || end.Before(*before.Expires) {
+		after.Expires = &end
+	}
+	if _, err = tx.Exec(ctx, `UPDATE subscriptions SET expires = $1, cancelled_at = $2 WHERE id = $3;`, after.Expires, day, id); err != nil {
+		return nil, errors.New("cancelling sub error: " + err.Error())
+	}
+	if before.Status == models.StatusPaused {
+		_, err = tx.Exec(ctx, `UPDATE sub_pauses SET ends = GREATEST(starts, $2) WHERE sub_id = $1 AND ends IS NULL;`, id, day)
+		if err != nil {
+			return nil, errors.New("resuming sub error: " + err.Error())
+		}
+	}
+	after.Status = models.StatusCancelled
+	if err = recordChange(ctx, tx, models.OpCancel, before, &after); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// Columns of staging table filled on COPY
+var copyColumns = []string{"uid", "name", "cost", "created_at", "expires", "billing_period", "currency", "trial_end", "trial_price", "snapshot"}
+
+// Inserts subscriptions from src with COPY through staging table, so IDs
+// of new rows are known and audit records are written at once.
+// src values must be in copyColumns order. Returns IDs of inserted rows
+// in src order
+func copySubs(ctx context.Context, tx pgx.Tx, src pgx.CopyFromSource) ([]int, error) {
+	_, err := tx.Exec(ctx, `CREATE TEMP TABLE subs_staging (pos INTEGER GENERATED ALWAYS AS IDENTITY, id INTEGER, uid UUID, name TEXT, cost NUMERIC,
+created_at DATE, expires DATE, billing_period TEXT, currency TEXT, trial_end DATE, trial_price NUMERIC, snapshot JSONB) ON COMMIT DROP;`)
+	if err != nil {
+		return nil, errors.New("creating staging table error: " + err.Error())
+	}
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{"subs_staging"}, copyColumns, src)
+	if err != nil {
+		return nil, errors.New("copying new subs error: " + err.Error())
+	}
+	// pos follows COPY order, so ids are matched with src rows by it
+	rows, err := tx.Query(ctx, `UPDATE subs_staging SET id = nextval(pg_get_serial_sequence('subscriptions', 'id')) RETURNING pos, id;`)
+	if err != nil {
+		return nil, errors.New("allocating sub ids error: " + err.Error())
+	}
+	ids := make([]int, n)
+	for rows.Next() {
+		var pos, id int
+		if err = rows.Scan(&pos, &id); err != nil {
+			rows.Close()
+			return nil, errors.New("allocating sub ids error: " + err.Error())
+		}
+		if pos < 1 || pos > len(ids) {
+			rows.Close()
+			return nil, errors.New("allocating sub ids error: unexpected position " + strconv.Itoa(pos))
+		}
+		ids[pos-1] = id
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("allocating sub ids error: " + err.Error())
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO subscriptions (id, uid, name, cost, created_at, expires, billing_period, currency, trial_end, trial_price)
+SELECT id, uid, name, cost, created_at, expires, billing_period, currency, trial_end, trial_price FROM subs_staging;`)
+	if err != nil {
+		return nil, errors.New("inserting staged subs error: " + err.Error())
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO sub_prices (sub_id, effective_from, cost)
+SELECT id, date_trunc('month', created_at), cost FROM subs_staging;`)
+	if err != nil {
+		return nil, errors.New("setting staged subs prices error: " + err.Error())
+	}
+	_, err = tx.Exec(ctx, `WITH changes AS (INSERT INTO audit_log (sub_id, uid, actor, request_id, op, after)
+SELECT id, uid, $1, $2, $3, snapshot || jsonb_build_object('id', id) FROM subs_staging RETURNING id)
+INSERT INTO webhook_outbox (audit_id) SELECT id FROM changes;`,
+		actor(ctx), requestID(ctx), models.OpCreate)
+	if err != nil {
+		return nil, errors.New("recording staged subs error: " + err.Error())
+	}
+	return ids, nil
+}
+
+// Returns values of new subscription for copySubs,
+// defaults are filled the same way as on insert
+func copyValues(ctx context.Context, s *models.Subscription) ([]any, error) {
+	prepareSub(ctx, s)
+	after, err := snapshot(s)
+	if err != nil {
+		return nil, errors.New("encoding snapshot error: " + err.Error())
+	}
+	return []any{s.UID, s.Name, s.Price, s.Start, s.Expires, s.BillingPeriod, s.Currency, s.TrialEnd, s.TrialPrice, after}, nil
+}
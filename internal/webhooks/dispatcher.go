@@ -0,0 +1,167 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testcase/internal/settings"
+	"testcase/models"
+	"time"
+)
+
+type Repository interface {
+	FanOutEvents(ctx context.Context, limit int) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.Dispatch, error)
+	CompleteDelivery(ctx context.Context, id int64, res *models.DispatchResult) error
+}
+
+// Time claimed delivery is completed within after its request, added to lease
+const completeMargin = 30 * time.Second
+
+type Dispatcher struct {
+	repo   Repository
+	client *http.Client
+	cfg    atomic.Pointer[settings.Webhooks]
+}
+
+func NewDispatcher(repo Repository) *Dispatcher {
+	d := &Dispatcher{
+		repo:   repo,
+		client: &http.Client{},
+	}
+	d.Reconfigure(settings.DefaultRuntime())
+	return d
+}
+
+// Applies runtime configuration, used as settings subscriber
+func (d *Dispatcher) Reconfigure(rt *settings.Runtime) {
+	cfg := rt.Webhooks
+	d.cfg.Store(&cfg)
+}
+
+// Schedules deliveries of changes from the outbox, then sends
+// deliveries which are due and returns number of delivered ones
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	cfg := d.cfg.Load()
+	if _, err := d.repo.FanOutEvents(ctx, cfg.BatchSize); err != nil {
+		return 0, err
+	}
+	// Claimed deliveries are sent concurrently and each is completed right
+	// after its request, so the lease covers a single request. Delivery is
+	// claimed again only if it isn't completed in time (e.g. on crash), then
+	// it is sent once more: endpoints get at least once delivery
+	dispatches, err := d.repo.ClaimDeliveries(ctx, cfg.BatchSize, cfg.Timeout+completeMargin)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		delivered int
+		errs      []error
+	)
+	for _, dispatch := range dispatches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := d.send(ctx, cfg, dispatch)
+			if res.Status == models.DeliveryDead {
+				slog.Warn("webhook delivery is dead",
+					slog.Int64("delivery_id", dispatch.DeliveryID),
+					slog.Int("attempts", dispatch.Attempt),
+					slog.String("error", *res.Error))
+			}
+			err := d.repo.CompleteDelivery(ctx, dispatch.DeliveryID, res)
+			mu.Lock()
+			defer mu.Unlock()
+			if res.Status == models.DeliveryDelivered {
+				delivered++
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return delivered, errors.Join(errs...)
+}
+
+// Posts event of dispatch and returns result of the attempt
+func (d *Dispatcher) send(ctx context.Context, cfg *settings.Webhooks, dispatch *models.Dispatch) *models.DispatchResult {
+	res := &models.DispatchResult{Status: models.DeliveryDelivered}
+	status, err := d.post(ctx, cfg.Timeout, dispatch)
+	if status != 0 {
+		res.ResponseStatus = &status
+	}
+	if err == nil {
+		return res
+	}
+	msg := err.Error()
+	res.Error = &msg
+	if dispatch.Attempt >= cfg.MaxAttempts {
+		res.Status = models.DeliveryDead
+		return res
+	}
+	next := time.Now().Add(Backoff(dispatch.Attempt, cfg.BackoffBase, cfg.BackoffMax))
+	res.Status, res.NextAttemptAt = models.DeliveryPending, &next
+	return res
+}
+
+func (d *Dispatcher) post(ctx context.Context, timeout time.Duration, dispatch *models.Dispatch) (int, error) {
+	body, err := dispatch.Event.MarshalFormat(models.FullFormat)
+	if err != nil {
+		return 0, errors.New("marshalling event error: " + err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.New("building request error: " + err.Error())
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(dispatch.Event.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(dispatch.Secret, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, errors.New("sending request error: " + err.Error())
+	}
+	defer resp.Body.Close()
+	// Body is drained, so the connection is reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.New("endpoint responded with status " + strconv.Itoa(resp.StatusCode))
+	}
+	return resp.StatusCode, nil
+}
+
+// Starts background delivery of webhooks, which runs until ctx is done.
+// Interval and limits are taken from the current runtime configuration
+func (d *Dispatcher) Run(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.cfg.Load().Interval):
+			}
+			n, err := d.RunOnce(ctx)
+			if err != nil {
+				slog.Error("webhook dispatch failed",
+					slog.Int("delivered", n),
+					slog.String("error", err.Error()))
+				continue
+			}
+			if n != 0 {
+				slog.Info("delivered webhooks", slog.Int("delivered", n))
+			}
+		}
+	}()
+}
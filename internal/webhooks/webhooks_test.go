@@ -0,0 +1,153 @@
+package webhooks_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testcase/internal/settings"
+	"testcase/internal/webhooks"
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	t.Parallel()
+	a, err := webhooks.GenerateSecret()
+	assert.NoError(t, err)
+	b, err := webhooks.GenerateSecret()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(a, "whsec_"))
+	assert.NotEqual(t, a, b)
+}
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(`1758067200.{"id":1}`))
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), webhooks.Sign("whsec_test", 1758067200, []byte(`{"id":1}`)))
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	base, max := 30*time.Second, 5*time.Minute
+	assert.Equal(t, 30*time.Second, webhooks.Backoff(1, base, max))
+	assert.Equal(t, time.Minute, webhooks.Backoff(2, base, max))
+	assert.Equal(t, 4*time.Minute, webhooks.Backoff(4, base, max))
+	assert.Equal(t, max, webhooks.Backoff(5, base, max))
+	assert.Equal(t, max, webhooks.Backoff(100, base, max))
+}
+
+type fakeRepo struct {
+	mu         sync.Mutex
+	dispatches []*models.Dispatch
+	results    map[int64]*models.DispatchResult
+	lease      time.Duration
+}
+
+func (f *fakeRepo) FanOutEvents(ctx context.Context, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.Dispatch, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	claimed := f.dispatches
+	f.dispatches, f.lease = nil, lease
+	return claimed, nil
+}
+
+func (f *fakeRepo) CompleteDelivery(ctx context.Context, id int64, res *models.DispatchResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[id] = res
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, webhooks.Sign("whsec_test", timestamp, body), r.Header.Get("X-Webhook-Signature"))
+		assert.Equal(t, "7", r.Header.Get("X-Webhook-ID"))
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		assert.Contains(t, string(body), `"op":"create"`)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	event := models.AuditRecord{ID: 7, SubID: 1, UID: uuid.New(), Actor: "apikey:1", Op: models.OpCreate, CreatedAt: time.Now()}
+	repo := &fakeRepo{
+		dispatches: []*models.Dispatch{
+			{DeliveryID: 1, Attempt: 1, URL: srv.URL + "/ok", Secret: "whsec_test", Event: event},
+			{DeliveryID: 2, Attempt: 2, URL: srv.URL + "/failing", Secret: "whsec_test", Event: event},
+			{DeliveryID: 3, Attempt: 3, URL: srv.URL + "/failing", Secret: "whsec_test", Event: event},
+		},
+		results: make(map[int64]*models.DispatchResult),
+	}
+	d := webhooks.NewDispatcher(repo)
+	rt := settings.DefaultRuntime()
+	rt.Webhooks.MaxAttempts = 3
+	rt.Webhooks.BackoffBase, rt.Webhooks.BackoffMax = time.Minute, time.Hour
+	d.Reconfigure(rt)
+	start := time.Now()
+	n, err := d.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.Equal(t, models.DeliveryDelivered, repo.results[1].Status)
+	assert.Equal(t, http.StatusNoContent, *repo.results[1].ResponseStatus)
+	assert.Nil(t, repo.results[1].Error)
+
+	retried := repo.results[2]
+	assert.Equal(t, models.DeliveryPending, retried.Status)
+	assert.Equal(t, http.StatusBadGateway, *retried.ResponseStatus)
+	assert.NotNil(t, retried.Error)
+	if assert.NotNil(t, retried.NextAttemptAt) {
+		assert.WithinDuration(t, start.Add(2*time.Minute), *retried.NextAttemptAt, 5*time.Second)
+	}
+
+	dead := repo.results[3]
+	assert.Equal(t, models.DeliveryDead, dead.Status)
+	assert.Nil(t, dead.NextAttemptAt)
+}
+
+func TestDispatcherBatchWithinLease(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	event := models.AuditRecord{ID: 7, SubID: 1, UID: uuid.New(), Actor: "apikey:1", Op: models.OpCreate, CreatedAt: time.Now()}
+	repo := &fakeRepo{results: make(map[int64]*models.DispatchResult)}
+	for id := range int64(5) {
+		repo.dispatches = append(repo.dispatches, &models.Dispatch{DeliveryID: id, Attempt: 1, URL: srv.URL, Secret: "whsec_test", Event: event})
+	}
+	d := webhooks.NewDispatcher(repo)
+	rt := settings.DefaultRuntime()
+	rt.Webhooks.Timeout = 500 * time.Millisecond
+	d.Reconfigure(rt)
+	start := time.Now()
+	n, err := d.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	// Sequential sends would take 1.5s, past the timeout of a single request
+	assert.Less(t, time.Since(start), repo.lease)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Greater(t, repo.lease, rt.Webhooks.Timeout)
+}
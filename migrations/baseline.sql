@@ -5,4 +5,197 @@ CREATE TABLE IF NOT EXISTS subscriptions (
     cost INTEGER NOT NULL,
     created_at DATE CHECK (EXTRACT(DAY FROM created_at) = 1) NOT NULL,
     expires DATE CHECK (EXTRACT(DAY FROM created_at) = 1)
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    uid UUID NOT NULL,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status INTEGER,
+    response BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS subscriptions_deleted_at_idx ON subscriptions (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    sub_id INTEGER NOT NULL,
+    uid UUID NOT NULL,
+    actor TEXT NOT NULL,
+    request_id TEXT,
+    op TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_sub_id_idx ON audit_log (sub_id, id);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, created_at);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+
+CREATE TABLE IF NOT EXISTS sub_prices (
+    sub_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    effective_from DATE CHECK (EXTRACT(DAY FROM effective_from) = 1) NOT NULL,
+    cost INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (sub_id, effective_from)
+);
+
+INSERT INTO sub_prices (sub_id, effective_from, cost)
+SELECT id, date_trunc('month', created_at)::date, cost FROM subscriptions
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_period TEXT NOT NULL DEFAULT 'monthly'
+    CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly', 'one-time'));
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) NOT NULL,
+    effective_from DATE CHECK (EXTRACT(DAY FROM effective_from) = 1) NOT NULL,
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (currency, effective_from)
+);
+
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'subscriptions' AND column_name = 'cost') = 'integer' THEN
+        ALTER TABLE subscriptions ALTER COLUMN cost TYPE NUMERIC USING cost::numeric(20, 2);
+    END IF;
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'sub_prices' AND column_name = 'cost') = 'integer' THEN
+        ALTER TABLE sub_prices ALTER COLUMN cost TYPE NUMERIC USING cost::numeric(20, 2);
+    END IF;
+END $$;
+
+DO $$
+DECLARE
+    c RECORD;
+BEGIN
+    FOR c IN SELECT conname FROM pg_constraint
+        WHERE conrelid = 'subscriptions'::regclass AND contype = 'c'
+        AND pg_get_constraintdef(oid) LIKE '%EXTRACT(day FROM created_at)%'
+    LOOP
+        EXECUTE format('ALTER TABLE subscriptions DROP CONSTRAINT %I', c.conname);
+    END LOOP;
+END $$;
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    uid UUID PRIMARY KEY,
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_end DATE;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_price NUMERIC CHECK (trial_price >= 0);
+
+CREATE TABLE IF NOT EXISTS sub_promos (
+    id SERIAL PRIMARY KEY,
+    sub_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value NUMERIC NOT NULL CHECK (value > 0),
+    starts DATE CHECK (EXTRACT(DAY FROM starts) = 1) NOT NULL,
+    months INTEGER NOT NULL CHECK (months > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS sub_promos_sub_id_idx ON sub_promos (sub_id, starts);
+
+CREATE TABLE IF NOT EXISTS sub_pauses (
+    id SERIAL PRIMARY KEY,
+    sub_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    starts DATE NOT NULL,
+    ends DATE CHECK (ends >= starts),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS sub_pauses_sub_id_idx ON sub_pauses (sub_id, starts);
+
+CREATE UNIQUE INDEX IF NOT EXISTS sub_pauses_open_idx ON sub_pauses (sub_id) WHERE ends IS NULL;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cancelled_at DATE;
+
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS email TEXT;
+
+CREATE TABLE IF NOT EXISTS sub_reminders (
+    sub_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('renewal', 'expiry')),
+    due DATE NOT NULL,
+    channel TEXT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (sub_id, kind, due, channel)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    uid UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+    audit_id BIGINT PRIMARY KEY REFERENCES audit_log (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES audit_log (id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, id);
+
+-- Ids are taken before commit, so changes are streamed ordered by
+-- transaction and only once all older transactions are finished
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS audit_log_txid_idx ON audit_log (txid, id);
+
+CREATE OR REPLACE FUNCTION notify_sub_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('sub_changes', NEW.uid::text);
+    RETURN NULL;
+END $$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER audit_log_notify AFTER INSERT ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION notify_sub_change();
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
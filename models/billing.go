@@ -0,0 +1,107 @@
+package models
+
+import "time"
+
+// Returns n-th billing date of subscription started on start. Months are
+// added the way Postgres adds intervals, so the day is clamped to the
+// last day of shorter months instead of overflowing into the next one
+func BillingDate(start time.Time, period string, n int) time.Time {
+	switch period {
+	case BillingWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case BillingQuarterly:
+		return addMonths(start, 3*n)
+	case BillingYearly:
+		return addMonths(start, 12*n)
+	case BillingOneTime:
+		return start
+	}
+	return addMonths(start, n)
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+// Returns index of the first billing date of subscription started on
+// start which isn't before from. The index is estimated from the
+// distance between the dates and corrected for clamped days
+func billingIndex(start time.Time, period string, from time.Time) int {
+	if !start.Before(from) {
+		return 0
+	}
+	var n int
+	switch period {
+	case BillingOneTime:
+		return 1
+	case BillingWeekly:
+		n = int(from.Sub(start) / (7 * 24 * time.Hour))
+	default:
+		months := (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+		switch period {
+		case BillingQuarterly:
+			n = months / 3
+		case BillingYearly:
+			n = months / 12
+		default:
+			n = months
+		}
+	}
+	for n > 0 && !BillingDate(start, period, n-1).Before(from) {
+		n--
+	}
+	for BillingDate(start, period, n).Before(from) {
+		n++
+	}
+	return n
+}
+
+// Returns the first day after billing period of s containing day: the
+// next billing date, or the day after the charge for one-time billing.
+// Start date is returned if s isn't started by day
+func (s *Subscription) PeriodEnd(day time.Time) time.Time {
+	if s.Start.After(day) {
+		return s.Start
+	}
+	if s.BillingPeriod == BillingOneTime {
+		return s.Start.AddDate(0, 0, 1)
+	}
+	n := billingIndex(s.Start, s.BillingPeriod, day)
+	if !BillingDate(s.Start, s.BillingPeriod, n).After(day) {
+		n++
+	}
+	return BillingDate(s.Start, s.BillingPeriod, n)
+}
+
+// Fills computed schedule of s for day, which is the current day of its
+// user. Subscriptions without expiration date renew automatically, so
+// their remaining charges are unbounded and left nil. Paused, cancelled
+// and expired subscriptions have no next charge until status changes
+func (s *Subscription) Schedule(day time.Time) {
+	autoRenew := s.Expires == nil && s.BillingPeriod != BillingOneTime
+	s.AutoRenew, s.NextChargeDate, s.RemainingCharges = &autoRenew, nil, nil
+	if s.Status == StatusPaused {
+		return
+	}
+	next := billingIndex(s.Start, s.BillingPeriod, day)
+	// Index of the first charge which isn't made, unbounded if negative
+	end := -1
+	if s.BillingPeriod == BillingOneTime {
+		end = 1
+	}
+	if s.Expires != nil {
+		if last := billingIndex(s.Start, s.BillingPeriod, *s.Expires); end < 0 || last < end {
+			end = last
+		}
+	}
+	remaining := max(end-next, 0)
+	if end < 0 || remaining > 0 {
+		charge := BillingDate(s.Start, s.BillingPeriod, next)
+		s.NextChargeDate = &charge
+	}
+	if !autoRenew {
+		s.RemainingCharges = &remaining
+	}
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Representation of dates and amounts in output. Zero value is the
+// legacy one of v1, where dates are written as MM-YYYY and amounts
+// as JSON numbers
+type Format struct {
+	// Dates are written as YYYY-MM-DD instead of MM-YYYY
+	FullDates bool
+	// Amounts are written as decimal strings instead of JSON numbers
+	MoneyStrings bool
+}
+
+// Format of v2. Being lossless, audit snapshots and
+// notifications are written in it too
+var FullFormat = Format{FullDates: true, MoneyStrings: true}
+
+// Formats date as YYYY-MM-DD with FullDates, otherwise as MM-YYYY
+func (f Format) Date(t time.Time) string {
+	if f.FullDates {
+		return t.Format(dateLayout)
+	}
+	return t.Format(layout)
+}
+
+// Writes amount as JSON number, or as decimal string with MoneyStrings
+func (f Format) money(m Money) json.RawMessage {
+	if f.MoneyStrings {
+		return json.RawMessage(`"` + m.String() + `"`)
+	}
+	return json.RawMessage(m.String())
+}
+
+// Implemented by models which JSON depends on format,
+// their MarshalJSON writes them in zero Format
+type FormatMarshaler interface {
+	MarshalFormat(f Format) ([]byte, error)
+}
+
+var formatMarshalerType = reflect.TypeFor[FormatMarshaler]()
+
+// Marshals v to JSON in format f. Slices of FormatMarshaler
+// are written element-wise, other values as is
+func MarshalFormat(v any, f Format) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return []byte("null"), nil
+	}
+	if m, ok := v.(FormatMarshaler); ok {
+		return m.MarshalFormat(f)
+	}
+	if rv.Kind() != reflect.Slice || rv.IsNil() || !rv.Type().Elem().Implements(formatMarshalerType) {
+		return sonic.Marshal(v)
+	}
+	buf := []byte{'['}
+	for i := range rv.Len() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		data, err := MarshalFormat(rv.Index(i).Interface(), f)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, data...)
+	}
+	return append(buf, ']'), nil
+}
+
+// Fields of subscription snapshots written depending on format
+var (
+	snapshotDates   = []string{"start_date", "expires", "trial_end", "next_charge_date"}
+	snapshotAmounts = []string{"price", "trial_price"}
+	// Amounts of nested price change and promo
+	snapshotNested = map[string][]string{"price_change": {"price"}, "promo": {"value"}}
+)
+
+// Rewrites subscription snapshot to format f. Snapshots are stored in
+// FullFormat, older ones may have amounts as numbers and MM-YYYY dates
+func formatSnapshot(data json.RawMessage, f Format) (json.RawMessage, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return data, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := sonic.Unmarshal(data, &fields); err != nil {
+		return nil, errors.New("decoding snapshot error: " + err.Error())
+	}
+	if err := formatFields(fields, snapshotDates, snapshotAmounts, f); err != nil {
+		return nil, err
+	}
+	for key, amounts := range snapshotNested {
+		raw, ok := fields[key]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var nested map[string]json.RawMessage
+		if err := sonic.Unmarshal(raw, &nested); err != nil {
+			return nil, errors.New("decoding snapshot " + key + " error: " + err.Error())
+		}
+		if err := formatFields(nested, nil, amounts, f); err != nil {
+			return nil, err
+		}
+		var err error
+		if fields[key], err = sonic.Marshal(nested); err != nil {
+			return nil, err
+		}
+	}
+	return sonic.Marshal(fields)
+}
+
+// Rewrites dates and amounts present in fields to format f
+func formatFields(fields map[string]json.RawMessage, dates, amounts []string, f Format) error {
+	for _, key := range dates {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		var s string
+		if err := sonic.Unmarshal(raw, &s); err != nil {
+			return errors.New("decoding snapshot " + key + " error: " + err.Error())
+		}
+		date, err := ParseDate(s)
+		if err != nil {
+			return errors.New("decoding snapshot " + key + " error: " + err.Error())
+		}
+		if fields[key], err = sonic.Marshal(f.Date(date)); err != nil {
+			return err
+		}
+	}
+	for _, key := range amounts {
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		var m Money
+		if err := m.UnmarshalJSON(raw); err != nil {
+			return errors.New("decoding snapshot " + key + " error: " + err.Error())
+		}
+		fields[key] = f.money(m)
+	}
+	return nil
+}
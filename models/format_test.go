@@ -0,0 +1,69 @@
+package models_test
+
+import (
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDate(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2025, time.July, 17, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "07-2025", models.Format{}.Date(day))
+	assert.Equal(t, "2025-07-17", models.FullFormat.Date(day))
+	month := time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "07-2025", models.Format{}.Date(month))
+	assert.Equal(t, "2025-07-01", models.FullFormat.Date(month))
+}
+
+func TestMarshalFormat(t *testing.T) {
+	t.Parallel()
+	expires := time.Date(2025, time.December, 17, 0, 0, 0, 0, time.UTC)
+	sub := &models.Subscription{
+		Name:          "okko",
+		Price:         models.NewMoney(400, 0),
+		UID:           uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		Start:         time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+		Expires:       &expires,
+		BillingPeriod: models.BillingMonthly,
+		Currency:      "RUB",
+	}
+	t.Run("legacy by default", func(t *testing.T) {
+		data, err := models.MarshalFormat([]*models.Subscription{sub, nil}, models.Format{})
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"start_date":"07-2025","expires":"12-2025"`)
+		assert.Contains(t, string(data), `"price":400,`)
+		assert.Contains(t, string(data), `,null]`)
+		legacy, err := sub.MarshalJSON()
+		assert.NoError(t, err)
+		assert.Contains(t, string(legacy), `"start_date":"07-2025","expires":"12-2025"`)
+	})
+	t.Run("full dates", func(t *testing.T) {
+		data, err := models.MarshalFormat([]*models.Subscription{sub}, models.FullFormat)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"start_date":"2025-07-01","expires":"2025-12-17"`)
+		assert.Contains(t, string(data), `"price":"400",`)
+	})
+	t.Run("audit snapshots", func(t *testing.T) {
+		after := *sub
+		after.Promo = &models.Promo{ID: 4, Kind: models.PromoFixed, Value: models.NewMoney(10050, 2), Start: sub.Start, Months: 3}
+		snapshot, err := models.MarshalFormat(&after, models.FullFormat)
+		assert.NoError(t, err)
+		rec := models.AuditRecord{ID: 1, Op: models.OpUpdate, After: snapshot}
+		data, err := models.MarshalFormat(rec, models.Format{})
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"start_date":"07-2025"`)
+		assert.Contains(t, string(data), `"expires":"12-2025"`)
+		assert.Contains(t, string(data), `"price":400`)
+		assert.Contains(t, string(data), `"value":100.50`)
+		assert.NotContains(t, string(data), `"before"`)
+		data, err = models.MarshalFormat(rec, models.FullFormat)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"expires":"2025-12-17"`)
+		assert.Contains(t, string(data), `"price":"400"`)
+		assert.Contains(t, string(data), `"value":"100.50"`)
+	})
+}
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -9,38 +14,183 @@ import (
 )
 
 type Subscription struct {
-	ID      int        `json:"id,omitempty" example:"1"`
-	Name    string     `json:"name" example:"yandex"`
-	Price   int        `json:"price" example:"400"`
-	UID     uuid.UUID  `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
-	Start   time.Time  `json:"start_date" example:"01-2025"`
-	Expires *time.Time `json:"expires,omitempty" example:"02-2026"`
+	ID    int       `json:"id,omitempty" example:"1"`
+	Name  string    `json:"name" example:"yandex"`
+	Price Money     `json:"price" swaggertype:"number" example:"399.99"`
+	UID   uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	// Dates are accepted as MM-YYYY (first day of the month) or
+	// YYYY-MM-DD, returned as MM-YYYY unless full dates are requested
+	Start   time.Time  `json:"start_date" swaggertype:"string" example:"01-2025"`
+	Expires *time.Time `json:"expires,omitempty" swaggertype:"string" example:"2026-02-17"`
+	// How often Price is charged, monthly by default
+	BillingPeriod string `json:"billing_period" enums:"weekly,monthly,quarterly,yearly,one-time" example:"monthly"`
+	// ISO 4217 code of Price currency, BaseCurrency by default
+	Currency string `json:"currency" example:"RUB"`
+	// Charges before the date are made with TrialPrice, free if it is empty
+	TrialEnd   *time.Time `json:"trial_end,omitempty" swaggertype:"string" example:"02-2025"`
+	TrialPrice *Money     `json:"trial_price,omitempty" swaggertype:"number" example:"99.00"`
+	// Computed on reads for the current day of the user, ignored on writes
+	Status string `json:"status,omitempty" enums:"upcoming,active,trial,paused,cancelled,expired" example:"active"`
+	// Computed on reads, see Schedule
+	AutoRenew        *bool      `json:"auto_renew,omitempty" example:"true"`
+	NextChargeDate   *time.Time `json:"next_charge_date,omitempty" swaggertype:"string" example:"2025-09-17"`
+	RemainingCharges *int       `json:"remaining_charges,omitempty" example:"3"`
+	// Set for deleted subscriptions, listed to admins only
+	DeletedAt *time.Time `json:"deleted_at,omitempty" swaggerignore:"true"`
+	// Set in audit snapshots of scheduled price changes only
+	PriceChange *PriceChange `json:"price_change,omitempty" swaggerignore:"true"`
+	// Set in audit snapshots of added promos only
+	Promo *Promo `json:"promo,omitempty" swaggerignore:"true"`
 }
 
-const layout = "01-2006"
+const (
+	layout     = "01-2006"
+	dateLayout = "2006-01-02"
+)
+
+// Parses date in MM-YYYY format as the first day of the month,
+// or in YYYY-MM-DD format
+func ParseDate(s string) (time.Time, error) {
+	if len(s) == len(dateLayout) {
+		return time.Parse(dateLayout, s)
+	}
+	return time.Parse(layout, s)
+}
+
+// Reports whether s is a date in YYYY-MM-DD format
+func IsFullDate(s string) bool {
+	return len(s) == len(dateLayout)
+}
+
+// Billing periods of subscriptions
+const (
+	BillingWeekly    = "weekly"
+	BillingMonthly   = "monthly"
+	BillingQuarterly = "quarterly"
+	BillingYearly    = "yearly"
+	BillingOneTime   = "one-time"
+)
+
+// Currency exchange rates are expressed in
+const BaseCurrency = "RUB"
+
+// Reports whether code looks like ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Statuses of subscriptions, in order of precedence: expired after
+// expiration date, cancelled until it, paused, upcoming before start
+// date, trial before trial end, and active otherwise
+const (
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+	StatusPaused    = "paused"
+	StatusUpcoming  = "upcoming"
+	StatusTrial     = "trial"
+	StatusActive    = "active"
+)
+
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusExpired, StatusCancelled, StatusPaused, StatusUpcoming, StatusTrial, StatusActive:
+		return true
+	}
+	return false
+}
+
+// Fields subscriptions lists can be ordered by
+var OrderFields = []string{"id", "name", "uid", "price", "created_at", "expires"}
+
+// Parses order like "price" or "price desc" into field and
+// direction, field must be one of OrderFields
+func ParseOrder(order string) (field string, desc bool, err error) {
+	field, dir, _ := strings.Cut(strings.TrimSpace(order), " ")
+	if !slices.Contains(OrderFields, field) {
+		return "", false, errors.New("invalid order field: " + field)
+	}
+	switch strings.ToLower(strings.TrimSpace(dir)) {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return "", false, errors.New("invalid order direction: " + dir)
+	}
+	return field, desc, nil
+}
+
+func ValidBillingPeriod(period string) bool {
+	switch period {
+	case BillingWeekly, BillingMonthly, BillingQuarterly, BillingYearly, BillingOneTime:
+		return true
+	}
+	return false
+}
 
 func (s Subscription) MarshalJSON() ([]byte, error) {
+	return s.MarshalFormat(Format{})
+}
+
+func (s Subscription) MarshalFormat(f Format) ([]byte, error) {
 	type Alias Subscription
 	dst := &struct {
-		Start   string  `json:"start_date"`
-		Expires *string `json:"expires,omitempty"`
+		Start          string          `json:"start_date"`
+		Expires        *string         `json:"expires,omitempty"`
+		TrialEnd       *string         `json:"trial_end,omitempty"`
+		NextChargeDate *string         `json:"next_charge_date,omitempty"`
+		Price          json.RawMessage `json:"price"`
+		TrialPrice     json.RawMessage `json:"trial_price,omitempty"`
+		PriceChange    json.RawMessage `json:"price_change,omitempty"`
+		Promo          json.RawMessage `json:"promo,omitempty"`
 		*Alias
 	}{
-		Start: s.Start.Format(layout),
+		Start: f.Date(s.Start),
+		Price: f.money(s.Price),
 		Alias: (*Alias)(&s),
 	}
+	if s.TrialPrice != nil {
+		dst.TrialPrice = f.money(*s.TrialPrice)
+	}
+	var err error
+	if s.PriceChange != nil {
+		if dst.PriceChange, err = s.PriceChange.MarshalFormat(f); err != nil {
+			return nil, err
+		}
+	}
+	if s.Promo != nil {
+		if dst.Promo, err = s.Promo.MarshalFormat(f); err != nil {
+			return nil, err
+		}
+	}
 	if s.Expires != nil {
-		expiresStr := s.Expires.Format(layout)
+		expiresStr := f.Date(*s.Expires)
 		dst.Expires = &expiresStr
 	}
+	if s.NextChargeDate != nil {
+		nextStr := f.Date(*s.NextChargeDate)
+		dst.NextChargeDate = &nextStr
+	}
+	if s.TrialEnd != nil {
+		trialEndStr := f.Date(*s.TrialEnd)
+		dst.TrialEnd = &trialEndStr
+	}
 	return sonic.Marshal(dst)
 }
 
 func (s *Subscription) UnmarshalJSON(data []byte) error {
 	type Alias Subscription
 	dst := &struct {
-		Start   string  `json:"start_date"`
-		Expires *string `json:"expires,omitempty"`
+		Start    string  `json:"start_date"`
+		Expires  *string `json:"expires,omitempty"`
+		TrialEnd *string `json:"trial_end,omitempty"`
 		*Alias
 	}{
 		Alias: (*Alias)(s),
@@ -48,28 +198,560 @@ func (s *Subscription) UnmarshalJSON(data []byte) error {
 	if err := sonic.Unmarshal(data, &dst); err != nil {
 		return err
 	}
+	s.Status, s.AutoRenew, s.NextChargeDate, s.RemainingCharges = "", nil, nil, nil
+	s.PriceChange, s.Promo = nil, nil
 	var err error
-	if s.Start, err = time.Parse(layout, dst.Start); err != nil {
+	if s.Start, err = ParseDate(dst.Start); err != nil {
 		return errors.New("invalid start_date format: " + err.Error())
 	}
 	if dst.Expires != nil {
-		parsed, err := time.Parse(layout, *dst.Expires)
+		parsed, err := ParseDate(*dst.Expires)
 		if err != nil {
 			return errors.New("invalid expires format: " + err.Error())
 		}
 		s.Expires = &parsed
 	}
+	if dst.TrialEnd != nil {
+		parsed, err := ParseDate(*dst.TrialEnd)
+		if err != nil {
+			return errors.New("invalid trial_end format: " + err.Error())
+		}
+		s.TrialEnd = &parsed
+	}
+	if s.BillingPeriod == "" {
+		s.BillingPeriod = BillingMonthly
+	}
+	if s.Currency == "" {
+		s.Currency = BaseCurrency
+	} else if s.Currency = strings.ToUpper(s.Currency); !ValidCurrency(s.Currency) {
+		return errors.New("invalid currency: " + s.Currency)
+	}
+	if s.Price, err = s.Price.ForCurrency(s.Currency); err != nil {
+		return errors.New("invalid price: " + err.Error())
+	}
+	if s.TrialPrice != nil {
+		trialPrice, err := s.TrialPrice.ForCurrency(s.Currency)
+		if err != nil {
+			return errors.New("invalid trial_price: " + err.Error())
+		}
+		s.TrialPrice = &trialPrice
+	}
+	return s.Validate()
+}
+
+func (s *Subscription) Validate() error {
+	switch {
+	case s.Name == "":
+		return errors.New("empty name")
+	case s.Price.Sign() < 0:
+		return errors.New("negative price")
+	case s.Start.IsZero():
+		return errors.New("missing start_date")
+	case s.Expires != nil && s.Expires.Before(s.Start):
+		return errors.New("expires is before start_date")
+	case s.TrialEnd != nil && s.TrialEnd.Before(s.Start):
+		return errors.New("trial_end is before start_date")
+	case s.TrialEnd == nil && s.TrialPrice != nil:
+		return errors.New("trial_price is set without trial_end")
+	case s.TrialPrice != nil && s.TrialPrice.Sign() < 0:
+		return errors.New("negative trial_price")
+	case !ValidBillingPeriod(s.BillingPeriod):
+		return errors.New("invalid billing_period")
+	case !ValidCurrency(s.Currency):
+		return errors.New("invalid currency")
+	}
+	return nil
+}
+
+// Price of subscription effective from the month, until the next change
+type PriceChange struct {
+	Price         Money     `json:"price" swaggertype:"number" example:"499.99"`
+	EffectiveFrom time.Time `json:"effective_from" swaggertype:"string" example:"09-2025"`
+}
+
+func (p PriceChange) MarshalJSON() ([]byte, error) {
+	return p.MarshalFormat(Format{})
+}
+
+func (p PriceChange) MarshalFormat(f Format) ([]byte, error) {
+	return sonic.Marshal(&struct {
+		Price         json.RawMessage `json:"price"`
+		EffectiveFrom string          `json:"effective_from"`
+	}{
+		Price:         f.money(p.Price),
+		EffectiveFrom: p.EffectiveFrom.Format(layout),
+	})
+}
+
+func (p *PriceChange) UnmarshalJSON(data []byte) error {
+	dst := &struct {
+		Price         *Money `json:"price"`
+		EffectiveFrom string `json:"effective_from"`
+	}{}
+	if err := sonic.Unmarshal(data, dst); err != nil {
+		return err
+	}
+	if dst.Price == nil {
+		return errors.New("price is required")
+	}
+	effective, err := time.Parse(layout, dst.EffectiveFrom)
+	if err != nil {
+		return errors.New("invalid effective_from format: " + err.Error())
+	}
+	p.Price, p.EffectiveFrom = *dst.Price, effective
 	return nil
 }
 
+// Kinds of promo discounts
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+// Discount of subscription price for Months billing months starting from
+// Start month. Value is percent off the price or amount off it in currency
+// of the subscription. Of overlapping promos the last added one is applied
+type Promo struct {
+	ID     int       `json:"id,omitempty" example:"1"`
+	Kind   string    `json:"kind" enums:"percent,fixed" example:"percent"`
+	Value  Money     `json:"value" swaggertype:"number" example:"50"`
+	Start  time.Time `json:"start" swaggertype:"string" example:"09-2025"`
+	Months int       `json:"months" example:"3"`
+}
+
+func (p Promo) MarshalJSON() ([]byte, error) {
+	return p.MarshalFormat(Format{})
+}
+
+func (p Promo) MarshalFormat(f Format) ([]byte, error) {
+	type Alias Promo
+	return sonic.Marshal(&struct {
+		Start string          `json:"start"`
+		Value json.RawMessage `json:"value"`
+		*Alias
+	}{
+		Start: p.Start.Format(layout),
+		Value: f.money(p.Value),
+		Alias: (*Alias)(&p),
+	})
+}
+
+func (p *Promo) UnmarshalJSON(data []byte) error {
+	type Alias Promo
+	dst := &struct {
+		Start string `json:"start"`
+		*Alias
+	}{
+		Alias: (*Alias)(p),
+	}
+	if err := sonic.Unmarshal(data, dst); err != nil {
+		return err
+	}
+	start, err := time.Parse(layout, dst.Start)
+	if err != nil {
+		return errors.New("invalid start format: " + err.Error())
+	}
+	p.Start = start
+	return p.Validate()
+}
+
+func (p *Promo) Validate() error {
+	switch {
+	case p.Kind != PromoPercent && p.Kind != PromoFixed:
+		return errors.New("invalid promo kind: " + p.Kind)
+	case p.Value.Sign() <= 0:
+		return errors.New("promo value must be positive")
+	case p.Kind == PromoPercent && p.Value.Cmp(NewMoney(100, 0)) > 0:
+		return errors.New("promo percent can't exceed 100")
+	case p.Months <= 0:
+		return errors.New("promo months must be positive")
+	}
+	return nil
+}
+
+// Interval of days when subscription is paused, charges falling on
+// them are skipped. End is the day of resume, nil while paused
+type Pause struct {
+	Start time.Time  `json:"start" swaggertype:"string" example:"2025-09-17"`
+	End   *time.Time `json:"end,omitempty" swaggertype:"string" example:"12-2025"`
+}
+
+func (p Pause) MarshalJSON() ([]byte, error) {
+	return p.MarshalFormat(Format{})
+}
+
+func (p Pause) MarshalFormat(f Format) ([]byte, error) {
+	dst := &struct {
+		Start string  `json:"start"`
+		End   *string `json:"end,omitempty"`
+	}{
+		Start: f.Date(p.Start),
+	}
+	if p.End != nil {
+		endStr := f.Date(*p.End)
+		dst.End = &endStr
+	}
+	return sonic.Marshal(dst)
+}
+
+// Charge due to be made on a billing date of subscription
+type Charge struct {
+	SubID    int       `json:"sub_id" example:"1"`
+	UID      uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Name     string    `json:"name" example:"Yandex Plus"`
+	Date     time.Time `json:"date" swaggertype:"string" example:"2025-09-17"`
+	Amount   Money     `json:"amount" swaggertype:"number" example:"399.99"`
+	Currency string    `json:"currency" example:"RUB"`
+}
+
+func (c Charge) MarshalJSON() ([]byte, error) {
+	return c.MarshalFormat(Format{})
+}
+
+func (c Charge) MarshalFormat(f Format) ([]byte, error) {
+	type Alias Charge
+	dst := &struct {
+		Date   string          `json:"date"`
+		Amount json.RawMessage `json:"amount"`
+		*Alias
+	}{
+		Date:   f.Date(c.Date),
+		Amount: f.money(c.Amount),
+		Alias:  (*Alias)(&c),
+	}
+	return sonic.Marshal(dst)
+}
+
+const (
+	ReminderRenewal = "renewal"
+	ReminderExpiry  = "expiry"
+)
+
+// Reminder about subscription being charged or expiring on Date,
+// Amount is set for renewals only
+type Reminder struct {
+	Kind     string    `json:"kind" enums:"renewal,expiry" example:"renewal"`
+	SubID    int       `json:"sub_id" example:"1"`
+	UID      uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Email    string    `json:"email,omitempty" example:"user@example.com"`
+	Name     string    `json:"name" example:"Yandex Plus"`
+	Date     time.Time `json:"date" swaggertype:"string" example:"2025-09-17"`
+	Amount   *Money    `json:"amount,omitempty" swaggertype:"number" example:"399.99"`
+	Currency string    `json:"currency" example:"RUB"`
+}
+
+func (r Reminder) MarshalJSON() ([]byte, error) {
+	return r.MarshalFormat(Format{})
+}
+
+func (r Reminder) MarshalFormat(f Format) ([]byte, error) {
+	type Alias Reminder
+	dst := &struct {
+		Date   string          `json:"date"`
+		Amount json.RawMessage `json:"amount,omitempty"`
+		*Alias
+	}{
+		Date:  f.Date(r.Date),
+		Alias: (*Alias)(&r),
+	}
+	if r.Amount != nil {
+		dst.Amount = f.money(*r.Amount)
+	}
+	return sonic.Marshal(dst)
+}
+
 type ListOpts struct {
 	Limit  int
 	Offset int
 	Filter map[string]interface{}
 	Order  string
+	// Include soft deleted rows, honoured for admins only
+	IncludeDeleted bool
+	// Rows with any of the statuses, all rows if empty
+	Status []string
 }
 
 type RangeOpts struct {
 	Start time.Time
-	End   time.Time
+	// Last month of the range, or the last day with DayPrecision
+	End          time.Time
+	DayPrecision bool
+}
+
+// Returns the first day after the range
+func (r *RangeOpts) Until() time.Time {
+	if r.DayPrecision {
+		return r.End.AddDate(0, 0, 1)
+	}
+	return r.End.AddDate(0, 1, 0)
+}
+
+type SumOpts struct {
+	// Charge every active month with monthly-equivalent price instead
+	// of charging on billing dates, one-time price is charged as is
+	MonthlyEquivalent bool
+	// Convert every charge to the currency at the rate effective in the
+	// month of the charge, prices are summed as is if empty
+	Currency string
+	// Spread every charge over days of its billing cycle and count
+	// only days within the period and before expiration
+	Prorate bool
+}
+
+// Settings of subscriptions user
+type UserSettings struct {
+	UID uuid.UUID `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	// IANA time zone, current month of the user is determined in it
+	TimeZone string `json:"time_zone" example:"Europe/Moscow"`
+	// Address reminders are mailed to, not mailed if empty
+	Email string `json:"email,omitempty" example:"user@example.com"`
+}
+
+// Time zone of users without settings
+const DefaultTimeZone = "UTC"
+
+func (u *UserSettings) Validate() error {
+	if u.TimeZone == "" || u.TimeZone == "Local" {
+		return errors.New("invalid time_zone: " + u.TimeZone)
+	}
+	if _, err := time.LoadLocation(u.TimeZone); err != nil {
+		return errors.New("invalid time_zone: " + err.Error())
+	}
+	if u.Email != "" {
+		if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+			return errors.New("invalid email: " + u.Email)
+		}
+	}
+	return nil
+}
+
+// Units of BaseCurrency per one unit of Currency, effective
+// from the month until the next rate of the currency
+type ExchangeRate struct {
+	Currency      string    `json:"currency" example:"USD"`
+	EffectiveFrom time.Time `json:"effective_from" swaggertype:"string" example:"07-2025"`
+	Rate          float64   `json:"rate" example:"78.5"`
+}
+
+func (r ExchangeRate) MarshalJSON() ([]byte, error) {
+	return sonic.Marshal(&struct {
+		Currency      string  `json:"currency"`
+		EffectiveFrom string  `json:"effective_from"`
+		Rate          float64 `json:"rate"`
+	}{
+		Currency:      r.Currency,
+		EffectiveFrom: r.EffectiveFrom.Format(layout),
+		Rate:          r.Rate,
+	})
+}
+
+func (r *ExchangeRate) UnmarshalJSON(data []byte) error {
+	dst := &struct {
+		Currency      string  `json:"currency"`
+		EffectiveFrom string  `json:"effective_from"`
+		Rate          float64 `json:"rate"`
+	}{}
+	if err := sonic.Unmarshal(data, dst); err != nil {
+		return err
+	}
+	effective, err := time.Parse(layout, dst.EffectiveFrom)
+	if err != nil {
+		return errors.New("invalid effective_from format: " + err.Error())
+	}
+	r.Currency, r.EffectiveFrom, r.Rate = strings.ToUpper(dst.Currency), effective, dst.Rate
+	return r.Validate()
+}
+
+func (r *ExchangeRate) Validate() error {
+	switch {
+	case !ValidCurrency(r.Currency):
+		return errors.New("invalid currency: " + r.Currency)
+	case r.Currency == BaseCurrency:
+		return errors.New("rate of base currency is always 1")
+	case r.Rate <= 0:
+		return errors.New("rate must be positive")
+	}
+	return nil
+}
+
+type APIKey struct {
+	ID        int        `json:"id" example:"1"`
+	Name      string     `json:"name" example:"billing-service"`
+	UID       uuid.UUID  `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Admin     bool       `json:"admin" example:"false"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// Plain key, returned only once on creation
+	Key string `json:"key,omitempty" example:"sk_..."`
+}
+
+// Stored result of request made with Idempotency-Key header
+type IdempotencyRecord struct {
+	RequestHash string
+	// Nil while the first request is still in progress
+	Status   *int
+	Response []byte
+}
+
+// Operations supported by subscriptions batch
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// Single operation of subscriptions batch. ID is required for
+// update and delete, Sub for create and update
+type BatchOp struct {
+	Op  string        `json:"op" enums:"create,update,delete" example:"create"`
+	ID  int           `json:"id,omitempty" example:"1"`
+	Sub *Subscription `json:"subscription,omitempty"`
+}
+
+// Statuses of batch operations
+const (
+	BatchOK         = "ok"
+	BatchError      = "error"
+	BatchRolledBack = "rolled_back"
+)
+
+type BatchResult struct {
+	Index  int    `json:"index" example:"0"`
+	Op     string `json:"op" example:"create"`
+	ID     int    `json:"id,omitempty" example:"1"`
+	Status string `json:"status" enums:"ok,error,rolled_back" example:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Operations recorded in audit log
+const (
+	OpCreate  = "create"
+	OpUpdate  = "update"
+	OpDelete  = "delete"
+	OpRestore = "restore"
+	OpPause   = "pause"
+	OpResume  = "resume"
+	OpCancel  = "cancel"
+)
+
+func ValidOp(op string) bool {
+	switch op {
+	case OpCreate, OpUpdate, OpDelete, OpRestore, OpPause, OpResume, OpCancel:
+		return true
+	}
+	return false
+}
+
+// Change of subscription with its state before and after,
+// snapshots are in Subscription format
+type AuditRecord struct {
+	ID        int64           `json:"id" example:"1"`
+	SubID     int             `json:"sub_id" example:"1"`
+	UID       uuid.UUID       `json:"uid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Actor     string          `json:"actor" example:"apikey:1"`
+	RequestID *string         `json:"request_id,omitempty"`
+	Op        string          `json:"op" enums:"create,update,delete,restore,pause,resume,cancel" example:"update"`
+	Before    json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After     json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (a AuditRecord) MarshalJSON() ([]byte, error) {
+	return a.MarshalFormat(Format{})
+}
+
+// Snapshots are stored in FullFormat and rewritten to f
+func (a AuditRecord) MarshalFormat(f Format) ([]byte, error) {
+	type Alias AuditRecord
+	dst := Alias(a)
+	var err error
+	if dst.Before, err = formatSnapshot(a.Before, f); err != nil {
+		return nil, err
+	}
+	if dst.After, err = formatSnapshot(a.After, f); err != nil {
+		return nil, err
+	}
+	return sonic.Marshal(dst)
+}
+
+type AuditOpts struct {
+	SubID  int
+	Actor  string
+	Op     string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// Endpoint subscription changes are posted to as audit records signed
+// with HMAC-SHA256 of Secret. Endpoint of non-admin user gets changes
+// of the user's subscriptions only
+type WebhookEndpoint struct {
+	ID  int    `json:"id" example:"1"`
+	URL string `json:"url" example:"https://billing.example.com/hooks/subs"`
+	// Operations changes are posted on, all if empty
+	Events    []string   `json:"events,omitempty" example:"create,cancel"`
+	UID       *uuid.UUID `json:"uid,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Signing key, returned only once on creation
+	Secret string `json:"secret,omitempty" example:"whsec_..."`
+}
+
+func (e *WebhookEndpoint) Validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("invalid url: " + e.URL)
+	}
+	for _, op := range e.Events {
+		if !ValidOp(op) {
+			return errors.New("invalid event: " + op)
+		}
+	}
+	return nil
+}
+
+// Statuses of webhook deliveries, dead ones ran out of attempts
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Attempts of posting audit record EventID to endpoint
+type Delivery struct {
+	ID             int64      `json:"id" example:"1"`
+	EndpointID     int        `json:"endpoint_id" example:"1"`
+	EventID        int64      `json:"event_id" example:"1"`
+	Status         string     `json:"status" enums:"pending,delivered,dead" example:"delivered"`
+	Attempts       int        `json:"attempts" example:"1"`
+	ResponseStatus *int       `json:"response_status,omitempty" example:"200"`
+	LastError      *string    `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type DeliveryOpts struct {
+	// Deliveries with the status, all if empty
+	Status string
+	Limit  int
+	Offset int
+}
+
+// Delivery claimed for sending with its endpoint and event
+type Dispatch struct {
+	DeliveryID int64
+	Attempt    int
+	URL        string
+	Secret     string
+	Event      AuditRecord
+}
+
+// Outcome of dispatch, NextAttemptAt is set for retried ones
+type DispatchResult struct {
+	Status         string
+	ResponseStatus *int
+	Error          *string
+	NextAttemptAt  *time.Time
 }
@@ -0,0 +1,223 @@
+package models_test
+
+import (
+	"testcase/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDate(t *testing.T) {
+	t.Parallel()
+	month, err := models.ParseDate("07-2025")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "07-2025", models.Format{}.Date(month))
+	day, err := models.ParseDate("2025-07-17")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, time.July, 17, 0, 0, 0, 0, time.UTC), day)
+	assert.Equal(t, "2025-07-17", models.FullFormat.Date(day))
+	for _, input := range []string{"", "7-2025", "2025-07", "17-07-2025", "2025-02-30"} {
+		_, err = models.ParseDate(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	t.Parallel()
+	field, desc, err := models.ParseOrder("price")
+	assert.NoError(t, err)
+	assert.Equal(t, "price", field)
+	assert.False(t, desc)
+	field, desc, err = models.ParseOrder("created_at DESC")
+	assert.NoError(t, err)
+	assert.Equal(t, "created_at", field)
+	assert.True(t, desc)
+	for _, order := range []string{"cost", "name sideways", "id desc, name", "(SELECT 1)", "name; DROP TABLE subscriptions"} {
+		_, _, err = models.ParseOrder(order)
+		assert.Error(t, err, order)
+	}
+}
+
+func TestRangeUntil(t *testing.T) {
+	t.Parallel()
+	end := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), (&models.RangeOpts{End: end}).Until())
+	assert.Equal(t, time.Date(2025, time.February, 2, 0, 0, 0, 0, time.UTC), (&models.RangeOpts{End: end, DayPrecision: true}).Until())
+}
+
+func TestUserSettingsValidate(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, (&models.UserSettings{TimeZone: "Europe/Moscow"}).Validate())
+	assert.Error(t, (&models.UserSettings{TimeZone: "Mars/Olympus"}).Validate())
+	assert.Error(t, (&models.UserSettings{}).Validate())
+	assert.NoError(t, (&models.UserSettings{TimeZone: "UTC", Email: "user@example.com"}).Validate())
+	assert.Error(t, (&models.UserSettings{TimeZone: "UTC", Email: "User <user@example.com>"}).Validate())
+}
+
+func TestPromoValidate(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, (&models.Promo{Kind: models.PromoPercent, Value: models.NewMoney(100, 0), Months: 1}).Validate())
+	assert.NoError(t, (&models.Promo{Kind: models.PromoFixed, Value: models.NewMoney(500, 0), Months: 3}).Validate())
+	assert.Error(t, (&models.Promo{Kind: "free", Value: models.NewMoney(10, 0), Months: 1}).Validate())
+	assert.Error(t, (&models.Promo{Kind: models.PromoPercent, Value: models.NewMoney(101, 0), Months: 1}).Validate())
+	assert.Error(t, (&models.Promo{Kind: models.PromoFixed, Value: models.NewMoney(0, 0), Months: 1}).Validate())
+	assert.Error(t, (&models.Promo{Kind: models.PromoFixed, Value: models.NewMoney(10, 0)}).Validate())
+}
+
+func TestSubscriptionTrial(t *testing.T) {
+	t.Parallel()
+	var s models.Subscription
+	assert.NoError(t, s.UnmarshalJSON([]byte(`{"name":"okko","price":"400","uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"2025-07-01","trial_end":"2025-07-15","trial_price":"1"}`)))
+	assert.Equal(t, time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC), *s.TrialEnd)
+	assert.Equal(t, "1.00", s.TrialPrice.String())
+	assert.Error(t, s.UnmarshalJSON([]byte(`{"name":"okko","price":"400","uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"2025-07-01","trial_end":"2025-06-15"}`)))
+	assert.Error(t, s.UnmarshalJSON([]byte(`{"name":"okko","price":"400","uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"2025-07-01","trial_price":"1"}`)))
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	t.Parallel()
+	var s models.Subscription
+	assert.NoError(t, s.UnmarshalJSON([]byte(`{"name":"okko","price":"400","start_date":"07-2025","expires":"2025-07-01"}`)))
+	for input, reason := range map[string]string{
+		`{"name":"","price":"400","start_date":"07-2025"}`:                                              "empty name",
+		`{"name":"okko","price":"-1","start_date":"07-2025"}`:                                           "negative price",
+		`{"name":"okko","price":"400","start_date":"07-2025","expires":"06-2025"}`:                      "expires is before start_date",
+		`{"name":"okko","price":"400","start_date":"07-2025","trial_end":"08-2025","trial_price":"-1"}`: "negative trial_price",
+		`{"name":"okko","price":"400","start_date":"07-2025","billing_period":"daily"}`:                 "invalid billing_period",
+	} {
+		var s models.Subscription
+		assert.EqualError(t, s.UnmarshalJSON([]byte(input)), reason)
+	}
+}
+
+func TestPauseJSON(t *testing.T) {
+	t.Parallel()
+	start, _ := models.ParseDate("2025-09-17")
+	end, _ := models.ParseDate("12-2025")
+	data, err := models.Pause{Start: start}.MarshalJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"start":"09-2025"}`, string(data))
+	data, err = models.Pause{Start: start, End: &end}.MarshalFormat(models.FullFormat)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"start":"2025-09-17","end":"2025-12-01"}`, string(data))
+}
+
+func TestBillingDate(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), models.BillingDate(start, models.BillingMonthly, 1))
+	assert.Equal(t, time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), models.BillingDate(start, models.BillingMonthly, 2))
+	assert.Equal(t, time.Date(2025, time.April, 30, 0, 0, 0, 0, time.UTC), models.BillingDate(start, models.BillingQuarterly, 1))
+	assert.Equal(t, time.Date(2025, time.February, 14, 0, 0, 0, 0, time.UTC), models.BillingDate(start, models.BillingWeekly, 2))
+	leap := time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), models.BillingDate(leap, models.BillingYearly, 1))
+}
+
+func TestPeriodEnd(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)
+	sub := &models.Subscription{Start: start, BillingPeriod: models.BillingMonthly}
+	assert.Equal(t, time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), sub.PeriodEnd(time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), sub.PeriodEnd(start))
+	assert.Equal(t, start, sub.PeriodEnd(start.AddDate(0, 0, -1)))
+	sub.BillingPeriod = models.BillingOneTime
+	assert.Equal(t, start.AddDate(0, 0, 1), sub.PeriodEnd(start.AddDate(0, 1, 0)))
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2025, time.March, 5, 0, 0, 0, 0, time.UTC)
+	sub := &models.Subscription{Start: start, BillingPeriod: models.BillingMonthly, Status: models.StatusActive}
+	sub.Schedule(day)
+	assert.True(t, *sub.AutoRenew)
+	assert.Equal(t, time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), *sub.NextChargeDate)
+	assert.Nil(t, sub.RemainingCharges)
+	expires := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	sub.Expires = &expires
+	sub.Schedule(day)
+	assert.False(t, *sub.AutoRenew)
+	assert.Equal(t, 3, *sub.RemainingCharges)
+	sub.Schedule(expires)
+	assert.Nil(t, sub.NextChargeDate)
+	assert.Equal(t, 0, *sub.RemainingCharges)
+	sub.Status = models.StatusPaused
+	sub.Schedule(day)
+	assert.Nil(t, sub.NextChargeDate)
+	assert.Nil(t, sub.RemainingCharges)
+}
+
+func TestScheduleMatchesBillingDates(t *testing.T) {
+	t.Parallel()
+	starts := []time.Time{
+		time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(1990, time.May, 17, 0, 0, 0, 0, time.UTC),
+	}
+	periods := []string{models.BillingWeekly, models.BillingMonthly, models.BillingQuarterly, models.BillingYearly, models.BillingOneTime}
+	for _, start := range starts {
+		for _, period := range periods {
+			expires := time.Date(2026, time.March, 30, 0, 0, 0, 0, time.UTC)
+			for day := time.Date(2024, time.December, 25, 0, 0, 0, 0, time.UTC); day.Before(expires.AddDate(0, 0, 10)); day = day.AddDate(0, 0, 3) {
+				// Expected values by walking billing dates one by one
+				var next *time.Time
+				remaining := 0
+				for n := 0; ; n++ {
+					charge := billingDateBefore(start, period, n, expires)
+					if charge == nil {
+						break
+					}
+					if charge.Before(day) {
+						continue
+					}
+					if next == nil {
+						next = charge
+					}
+					remaining++
+				}
+				sub := &models.Subscription{Start: start, Expires: &expires, BillingPeriod: period, Status: models.StatusActive}
+				sub.Schedule(day)
+				assert.Equal(t, next, sub.NextChargeDate, "%s %s %s", start, period, day)
+				assert.Equal(t, remaining, *sub.RemainingCharges, "%s %s %s", start, period, day)
+				if period == models.BillingOneTime || day.Before(start) {
+					continue
+				}
+				n := 1
+				for !models.BillingDate(start, period, n).After(day) {
+					n++
+				}
+				assert.Equal(t, models.BillingDate(start, period, n), sub.PeriodEnd(day), "%s %s %s", start, period, day)
+			}
+		}
+	}
+}
+
+// Returns n-th billing date, or nil if there is no such charge before expires
+func billingDateBefore(start time.Time, period string, n int, expires time.Time) *time.Time {
+	charge := models.BillingDate(start, period, n)
+	if !charge.Before(expires) || period == models.BillingOneTime && n > 0 {
+		return nil
+	}
+	return &charge
+}
+
+func TestChargeJSON(t *testing.T) {
+	t.Parallel()
+	date, _ := models.ParseDate("2025-09-17")
+	uid := uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba")
+	data, err := models.Charge{SubID: 1, UID: uid, Name: "yandex", Date: date, Amount: models.MustParseMoney("399.99"), Currency: "RUB"}.MarshalFormat(models.FullFormat)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"sub_id":1,"uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba","name":"yandex","date":"2025-09-17","amount":"399.99","currency":"RUB"}`, string(data))
+}
+
+func TestWebhookEndpointValidate(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, (&models.WebhookEndpoint{URL: "https://example.com/hook"}).Validate())
+	assert.NoError(t, (&models.WebhookEndpoint{URL: "http://localhost:8080", Events: []string{models.OpCreate, models.OpCancel}}).Validate())
+	assert.Error(t, (&models.WebhookEndpoint{URL: "ftp://example.com"}).Validate())
+	assert.Error(t, (&models.WebhookEndpoint{URL: "/hook"}).Validate())
+	assert.Error(t, (&models.WebhookEndpoint{URL: "https://example.com", Events: []string{"archive"}}).Validate())
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Exact decimal amount of money, encoded in JSON as decimal number
+// (or string, see Format) and stored in NUMERIC columns. Zero value is 0
+type Money struct {
+	// Amount in 10^-scale units, nil for zero
+	units *big.Int
+	scale int32
+}
+
+// Digits after decimal point of currencies, which
+// minor unit isn't a hundredth of major one
+var minorUnits = map[string]int32{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3,
+	"PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
+
+// Returns number of digits after decimal point in prices of the currency
+func MinorUnits(currency string) int32 {
+	if scale, ok := minorUnits[currency]; ok {
+		return scale
+	}
+	return 2
+}
+
+// Creates money amount of units of 10^-scale
+func NewMoney(units int64, scale int32) Money {
+	return Money{units: big.NewInt(units), scale: scale}
+}
+
+// Parses decimal string like "299.99" or "-5"
+func ParseMoney(s string) (Money, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	digits := strings.TrimPrefix(strings.TrimPrefix(whole, "-"), "+")
+	if digits == "" && frac == "" || strings.ContainsAny(digits+frac, "+-") {
+		return Money{}, errors.New("invalid amount: " + s)
+	}
+	units, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return Money{}, errors.New("invalid amount: " + s)
+	}
+	return Money{units: units, scale: int32(len(frac))}, nil
+}
+
+// Parses amount, panics on error. For constants and tests
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m Money) int() *big.Int {
+	if m.units == nil {
+		return new(big.Int)
+	}
+	return m.units
+}
+
+// Returns number of digits after decimal point
+func (m Money) Scale() int32 {
+	return m.scale
+}
+
+func (m Money) Sign() int {
+	return m.int().Sign()
+}
+
+// Compares amounts regardless of their scales
+func (m Money) Cmp(o Money) int {
+	scale := max(m.scale, o.scale)
+	a, _ := m.Rescale(scale)
+	b, _ := o.Rescale(scale)
+	return a.int().Cmp(b.int())
+}
+
+// Returns the amount with provided number of digits after decimal
+// point, ok is false if nonzero digits were cut off
+func (m Money) Rescale(scale int32) (Money, bool) {
+	units := new(big.Int).Set(m.int())
+	if scale >= m.scale {
+		pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-m.scale)), nil)
+		return Money{units: units.Mul(units, pow), scale: scale}, true
+	}
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.scale-scale)), nil)
+	rem := new(big.Int)
+	units.QuoRem(units, pow, rem)
+	return Money{units: units, scale: scale}, rem.Sign() == 0
+}
+
+// Returns the amount rounded half away from zero
+// to provided number of digits after decimal point
+func (m Money) Round(scale int32) Money {
+	if scale >= m.scale {
+		rounded, _ := m.Rescale(scale)
+		return rounded
+	}
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.scale-scale)), nil)
+	units, rem := new(big.Int).QuoRem(m.int(), pow, new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(pow) >= 0 {
+		units.Add(units, big.NewInt(int64(m.Sign())))
+	}
+	return Money{units: units, scale: scale}
+}
+
+// Returns the amount with scale of the currency, error
+// if it has more digits than the currency allows
+func (m Money) ForCurrency(currency string) (Money, error) {
+	scaled, ok := m.Rescale(MinorUnits(currency))
+	if !ok {
+		return Money{}, errors.New("amount has more decimal places than " + currency + " allows")
+	}
+	return scaled, nil
+}
+
+func (m Money) String() string {
+	digits := new(big.Int).Abs(m.int()).String()
+	sign := ""
+	if m.Sign() < 0 {
+		sign = "-"
+	}
+	if m.scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-m.scale))
+	}
+	if pad := int(m.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(m.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return m.MarshalFormat(Format{})
+}
+
+func (m Money) MarshalFormat(f Format) ([]byte, error) {
+	return f.money(m), nil
+}
+
+// Accepts decimal string as well as JSON number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if strings.ContainsAny(s, "eE") {
+		return errors.New("invalid amount: exponent isn't supported")
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m *Money) ScanNumeric(v pgtype.Numeric) error {
+	switch {
+	case !v.Valid:
+		*m = Money{}
+	case v.NaN || v.InfinityModifier != pgtype.Finite:
+		return errors.New("amount isn't finite")
+	case v.Exp > 0:
+		pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(v.Exp)), nil)
+		*m = Money{units: pow.Mul(pow, v.Int)}
+	default:
+		*m = Money{units: v.Int, scale: -v.Exp}
+	}
+	return nil
+}
+
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: m.int(), Exp: -m.scale, Valid: true}, nil
+}
@@ -0,0 +1,68 @@
+package models_test
+
+import (
+	"testcase/models"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	t.Parallel()
+	for input, expected := range map[string]string{
+		"299.99": "299.99",
+		"-5":     "-5",
+		"0.05":   "0.05",
+		".5":     "0.5",
+		"+12.":   "12",
+	} {
+		m, err := models.ParseMoney(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, m.String(), input)
+	}
+	for _, input := range []string{"", "-", "1.2.3", "--1", "1e3", "12,5", " 1"} {
+		_, err := models.ParseMoney(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestMoneyRound(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "0.13", models.MustParseMoney("0.125").Round(2).String())
+	assert.Equal(t, "-0.13", models.MustParseMoney("-0.125").Round(2).String())
+	assert.Equal(t, "0.12", models.MustParseMoney("0.1249").Round(2).String())
+	assert.Equal(t, "7.00", models.NewMoney(7, 0).Round(2).String())
+	assert.Equal(t, 0, models.MustParseMoney("7.00").Cmp(models.NewMoney(7, 0)))
+	assert.Equal(t, -1, models.MustParseMoney("6.99").Cmp(models.NewMoney(7, 0)))
+}
+
+func TestMoneyForCurrency(t *testing.T) {
+	t.Parallel()
+	m, err := models.MustParseMoney("400").ForCurrency("USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "400.00", m.String())
+	_, err = models.MustParseMoney("400.5").ForCurrency("JPY")
+	assert.Error(t, err)
+	m, err = models.MustParseMoney("1.234").ForCurrency("KWD")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.234", m.String())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Parallel()
+	var sub models.Subscription
+	err := sonic.UnmarshalString(`{"name":"yandex","price":299.99,"start_date":"07-2025","uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba"}`, &sub)
+	assert.NoError(t, err)
+	assert.Equal(t, "299.99", sub.Price.String())
+	err = sonic.UnmarshalString(`{"name":"yandex","price":"400","start_date":"07-2025","uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba"}`, &sub)
+	assert.NoError(t, err)
+	data, err := sonic.Marshal(sub.Price)
+	assert.NoError(t, err)
+	assert.Equal(t, `400.00`, string(data))
+	data, err = sub.Price.MarshalFormat(models.FullFormat)
+	assert.NoError(t, err)
+	assert.Equal(t, `"400.00"`, string(data))
+	err = sonic.UnmarshalString(`{"name":"yandex","price":"0.001","start_date":"07-2025","uid":"60601fee-2bf1-4721-ae6f-7636e79a0cba"}`, &sub)
+	assert.Error(t, err)
+}